@@ -3,8 +3,8 @@ module github.com/vallieres/mx-creative-console-bg-maker
 go 1.24.5
 
 require (
-	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
 	github.com/stretchr/testify v1.10.0
+	golang.org/x/image v0.25.0
 )
 
 require (
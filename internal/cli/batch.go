@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// batchResult is the outcome of processing one input of a batch.
+type batchResult struct {
+	path string
+	err  error
+}
+
+// runBatch calls process for every path using at most jobs goroutines. Each
+// call writes to its own buffer, which is copied to out in input order,
+// after a status line, as soon as the calls before it have finished. Once
+// ctx is cancelled, the paths not started yet fail with the context error.
+func runBatch(
+	ctx context.Context, paths []string, jobs int, out io.Writer, process func(path string, out io.Writer) error,
+) []batchResult {
+	results := make([]batchResult, len(paths))
+	outputs := make([]bytes.Buffer, len(paths))
+	done := make([]chan struct{}, len(paths))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	indexes := make(chan int)
+	var workers sync.WaitGroup
+	for range min(jobs, len(paths)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range indexes {
+				processErr := ctx.Err()
+				if processErr == nil {
+					processErr = process(paths[i], &outputs[i])
+				}
+				results[i] = batchResult{path: paths[i], err: processErr}
+				close(done[i])
+			}
+		}()
+	}
+	go func() {
+		for i := range paths {
+			indexes <- i
+		}
+		close(indexes)
+	}()
+
+	for i := range paths {
+		<-done[i]
+		status := "ok"
+		if results[i].err != nil {
+			status = "failed: " + results[i].err.Error()
+		}
+		_, _ = fmt.Fprintf(out, "%s: %s\n", paths[i], status)
+		_, _ = outputs[i].WriteTo(out)
+	}
+	workers.Wait()
+
+	return results
+}
+
+// summarizeBatch prints how many inputs succeeded and failed, and returns an
+// error listing the failures if there were any.
+func summarizeBatch(out io.Writer, results []batchResult) error {
+	var failed []batchResult
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result)
+		}
+	}
+
+	_, _ = fmt.Fprintf(out, "Split %d images: %d succeeded, %d failed\n",
+		len(results), len(results)-len(failed), len(failed))
+	if len(failed) == 0 {
+		return nil
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d of %d images failed:", len(failed), len(results))
+	for _, result := range failed {
+		fmt.Fprintf(&b, "\n  %s", result.path)
+	}
+	return fmt.Errorf("%s", b.String())
+}
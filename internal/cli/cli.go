@@ -1,21 +1,33 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
 )
 
+const progName = "ccbm"
+
 // App represents the CLI application.
 type App struct {
 	processor *processor.Service
+	stdout    io.Writer
+	stderr    io.Writer
 }
 
 // NewApp creates a new CLI application.
 func NewApp() *App {
 	return &App{
 		processor: processor.NewService(),
+		stdout:    os.Stdout,
+		stderr:    os.Stderr,
 	}
 }
 
@@ -23,33 +35,97 @@ func NewApp() *App {
 func NewAppWithProcessor(proc *processor.Service) *App {
 	return &App{
 		processor: proc,
+		stdout:    os.Stdout,
+		stderr:    os.Stderr,
 	}
 }
 
-const minRequiredArgs = 2
+// SetOutput redirects the standard and error output of the application.
+func (a *App) SetOutput(stdout, stderr io.Writer) {
+	a.stdout = stdout
+	a.stderr = stderr
+}
+
+const (
+	minRequiredArgs = 2
+	// interruptedExitCode is the exit status of a run stopped by SIGINT,
+	// following the shell convention of 128 plus the signal number.
+	interruptedExitCode = 130
+)
 
 // Run executes the CLI application.
 func (a *App) Run(args []string) error {
-	progName := "ccbm"
+	return a.RunContext(context.Background(), args)
+}
+
+// RunContext executes the CLI application until ctx is cancelled, in which
+// case the running command stops, removes the files it was writing and
+// returns an error wrapping the context error.
+func (a *App) RunContext(ctx context.Context, args []string) error {
+	if len(args) < minRequiredArgs {
+		return fmt.Errorf("usage: %s <image_path> or %s <command> [flags] (see %s --help)",
+			progName, progName, progName)
+	}
 
-	if len(args) >= 2 && (args[1] == "--help" || args[1] == "-h") {
-		_, _ = fmt.Fprintf(os.Stdout, "Usage: ccbm <image_path>\n")
+	name, cmdArgs := args[1], args[2:]
+	switch name {
+	case "-h", "--help", "help":
+		return a.runHelp(cmdArgs)
+	case "-v", "--version":
+		name = "version"
+	}
+
+	if cmd, found := findCommand(name); found {
+		return cmd.run(ctx, a, cmd, cmdArgs)
+	}
+
+	// Anything that is not a command is treated as `ccbm split <args>` so that
+	// the original `ccbm <image_path>` invocation keeps working.
+	cmd, _ := findCommand("split")
+	return cmd.run(ctx, a, cmd, args[1:])
+}
+
+func (a *App) runHelp(args []string) error {
+	if len(args) > 0 {
+		cmd, found := findCommand(args[0])
+		if !found {
+			return fmt.Errorf("unknown command %q", args[0])
+		}
+		cmd.printUsage(a.stdout, cmd.flagSet(a.processor.Config()))
 		return nil
 	}
 
-	if len(args) < minRequiredArgs {
-		return fmt.Errorf("usage: %s <image_path>", progName)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Usage: %s <image_path>\n", progName)
+	fmt.Fprintf(&b, "       %s <command> [flags] [arguments]\n\n", progName)
+	b.WriteString("Split images into key tiles for the Logitech MX Creative Console.\n\n")
+	b.WriteString("Commands:\n")
+	for _, cmd := range commands() {
+		fmt.Fprintf(&b, "  %-9s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintf(&b, "\nRun '%s help <command>' for the flags of a command.\n", progName)
 
-	imagePath := args[1]
-	return a.processor.ProcessImage(imagePath)
+	_, _ = io.WriteString(a.stdout, b.String())
+	return nil
 }
 
-// Main is the main entry point that can be tested.
+// Main is the main entry point that can be tested. SIGINT and SIGTERM
+// cancel the run; a second signal terminates the process immediately.
 func Main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	app := NewApp()
-	if err := app.Run(os.Args); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	runErr := app.RunContext(ctx, os.Args)
+	stop()
+	if runErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", runErr)
+		if errors.Is(runErr, context.Canceled) {
+			os.Exit(interruptedExitCode)
+		}
 		os.Exit(1)
 	}
 }
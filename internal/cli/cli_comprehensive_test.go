@@ -39,7 +39,8 @@ func TestApp_Run_ValidArgs(t *testing.T) {
 	resizer := processor.NewTestMockImageResizer()
 	config := processor.DefaultConfig()
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 	app := cli.NewAppWithProcessor(service)
 	fs.AddFile("/test/image.jpg", []byte("fake image data"))
 
@@ -90,7 +91,8 @@ func TestApp_Run_ProcessorFileError(t *testing.T) {
 	resizer := processor.NewTestMockImageResizer()
 	config := processor.DefaultConfig()
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 	app := cli.NewAppWithProcessor(service)
 
 	args := []string{"ccbm", "/restricted/image.jpg"}
@@ -124,17 +126,24 @@ func TestApp_Run_MultipleImagePaths(t *testing.T) {
 	resizer := processor.NewTestMockImageResizer()
 	config := processor.DefaultConfig()
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 	app := cli.NewAppWithProcessor(service)
+	app.SetOutput(io.Discard, io.Discard)
 	fs.AddFile("/test/image1.jpg", []byte("fake image data"))
+	fs.AddFile("/test/image2.jpg", []byte("fake image data"))
 
 	args := []string{"ccbm", "/test/image1.jpg", "/test/image2.jpg"}
 
-	// Execute - should only process first image path
+	// Execute - every image path is split
 	runErr := app.Run(args)
 
 	// Assert
 	require.NoError(t, runErr)
+	for _, name := range []string{"/test/image1_9.png", "/test/image2_9.png"} {
+		_, exists := fs.GetWrittenFile(name)
+		assert.True(t, exists, "expected %s to be written", name)
+	}
 }
 
 func TestApp_Run_SpecialCharactersInPath(t *testing.T) {
@@ -145,7 +154,8 @@ func TestApp_Run_SpecialCharactersInPath(t *testing.T) {
 	resizer := processor.NewTestMockImageResizer()
 	config := processor.DefaultConfig()
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 	app := cli.NewAppWithProcessor(service)
 
 	specialPath := "/test/image with spaces & symbols!.jpg"
@@ -168,7 +178,8 @@ func TestApp_Run_VeryLongPath(t *testing.T) {
 	resizer := processor.NewTestMockImageResizer()
 	config := processor.DefaultConfig()
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 	app := cli.NewAppWithProcessor(service)
 
 	// Create a very long path
@@ -192,7 +203,8 @@ func TestApp_Run_RelativePath(t *testing.T) {
 	resizer := processor.NewTestMockImageResizer()
 	config := processor.DefaultConfig()
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 	app := cli.NewAppWithProcessor(service)
 
 	relativePath := "./images/test.jpg"
@@ -231,7 +243,8 @@ func TestApp_Run_ArgumentsWithExtraSpaces(t *testing.T) {
 	resizer := processor.NewTestMockImageResizer()
 	config := processor.DefaultConfig()
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 	app := cli.NewAppWithProcessor(service)
 
 	imagePath := "/test/normal-image.jpg"
@@ -268,7 +281,8 @@ func TestApp_Run_DifferentImageFormats(t *testing.T) {
 			resizer := processor.NewTestMockImageResizer()
 			config := processor.DefaultConfig()
 
-			service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+			service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+			require.NoError(t, serviceErr)
 			app := cli.NewAppWithProcessor(service)
 
 			fs.AddFile(tc.filename, []byte("fake image data"))
@@ -292,7 +306,8 @@ func BenchmarkApp_Run(b *testing.B) {
 	resizer := processor.NewTestMockImageResizer()
 	config := processor.DefaultConfig()
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(b, serviceErr)
 	app := cli.NewAppWithProcessor(service)
 
 	fs.AddFile("/test/image.jpg", []byte("fake image data"))
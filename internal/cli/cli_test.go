@@ -49,7 +49,8 @@ func TestApp_Run_Success(t *testing.T) {
 	resizer := processor.NewTestMockImageResizer()
 	config := processor.DefaultConfig()
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 	app := cli.NewAppWithProcessor(service)
 
 	args := []string{"ccbm", "/test/image.jpg"}
@@ -100,7 +101,8 @@ func TestApp_Run_ProcessorError(t *testing.T) {
 	resizer := processor.NewTestMockImageResizer()
 	config := processor.DefaultConfig()
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 	app := cli.NewAppWithProcessor(service)
 
 	args := []string{"ccbm", "/nonexistent/image.jpg"}
@@ -0,0 +1,1076 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+const (
+	defaultOutputFormat = "png"
+	tablePadding        = 2
+	percent             = 100
+)
+
+// command describes a ccbm subcommand.
+type command struct {
+	name    string
+	args    string
+	summary string
+	setup   func(fs *flag.FlagSet, opts *options)
+	run     func(ctx context.Context, a *App, cmd command, args []string) error
+}
+
+// options holds the values of every flag a command may register.
+type options struct {
+	// flags holds the configuration values bound to flags; config is the
+	// effective configuration once the device profile and explicitly set
+	// flags have been applied.
+	flags       processor.Config
+	config      processor.Config
+	set         map[string]bool
+	device      string
+	devicesFile string
+	format      string
+	quality     int
+	compression string
+	colors      int
+	maxBytes    int
+	output      string
+	background  string
+	fit         string
+	fill        string
+	spacingMode string
+	crop        string
+	gravity     string
+	focalPoint  string
+	sourceRect  string
+	adjust      []string
+	flip        string
+	pan         string
+	cropDebug   bool
+	force       bool
+	dryRun      bool
+	jobs        int
+	recursive   bool
+	timeout     time.Duration
+	resample    string
+}
+
+func commands() []command {
+	return []command{
+		{
+			name:    "split",
+			args:    "<image_path>...",
+			summary: "split images into key tiles (default command)",
+			setup:   setupSplitCommandFlags,
+			run:     runSplit,
+		},
+		{
+			name:    "preview",
+			args:    "<image_path>",
+			summary: "render the tiles laid out as they appear on the device",
+			setup:   setupPreviewFlags,
+			run:     runPreview,
+		},
+		{
+			name:    "info",
+			args:    "<image_path>",
+			summary: "show the image format, dimensions and the tiles that would be written",
+			setup:   setupSplitFlags,
+			run:     runInfo,
+		},
+		{
+			name:    "join",
+			args:    "<tile_path>...",
+			summary: "assemble tiles back into a single image",
+			setup:   setupJoinFlags,
+			run:     runJoin,
+		},
+		{
+			name:    "devices",
+			summary: "list the device profiles and their key geometry",
+			setup:   setupDeviceFlags,
+			run:     runDevices,
+		},
+		{
+			name:    "version",
+			summary: "print the version",
+			setup:   func(*flag.FlagSet, *options) {},
+			run:     runVersion,
+		},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func (c command) flagSet(config processor.Config) *flag.FlagSet {
+	fs, _ := c.newFlagSet(config)
+	return fs
+}
+
+func (c command) newFlagSet(config processor.Config) (*flag.FlagSet, *options) {
+	opts := &options{flags: config, config: config, set: map[string]bool{}, format: defaultOutputFormat}
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	c.setup(fs, opts)
+	return fs, opts
+}
+
+// parse parses flags and positional arguments in any order. It prints the
+// command usage and returns flag.ErrHelp when help was requested.
+func (c command) parse(a *App, args []string) (*options, []string, error) {
+	fs, opts := c.newFlagSet(a.processor.Config())
+
+	var positional []string
+	for {
+		if parseErr := fs.Parse(args); parseErr != nil {
+			if errors.Is(parseErr, flag.ErrHelp) {
+				c.printUsage(a.stdout, fs)
+				return nil, nil, parseErr
+			}
+			return nil, nil, fmt.Errorf("%w (see %s %s --help)", parseErr, progName, c.name)
+		}
+
+		consumed := len(args) - fs.NArg()
+		rest := fs.Args()
+		if consumed > 0 && args[consumed-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+
+	fs.Visit(func(f *flag.Flag) { opts.set[f.Name] = true })
+	if resolveErr := a.resolveConfig(opts); resolveErr != nil {
+		return nil, nil, resolveErr
+	}
+	return opts, positional, nil
+}
+
+// resolveConfig builds the effective configuration: the service configuration,
+// replaced by the selected device profile, overridden by explicitly set flags.
+func (a *App) resolveConfig(opts *options) error {
+	config := a.processor.Config()
+
+	if opts.device != "" {
+		if deviceErr := a.resolveDevice(&config, opts); deviceErr != nil {
+			return deviceErr
+		}
+	}
+	geometryChanged, geometryErr := resolveGeometry(&config, opts)
+	if geometryErr != nil {
+		return geometryErr
+	}
+	if outputErr := resolveOutput(&config, opts); outputErr != nil {
+		return outputErr
+	}
+	if fitErr := resolveFit(&config, opts); fitErr != nil {
+		return fitErr
+	}
+	if opts.set["ignore-exif"] {
+		config.IgnoreEXIF = opts.flags.IgnoreEXIF
+	}
+	if transformErr := resolveTransform(&config.Transform, opts); transformErr != nil {
+		return transformErr
+	}
+	if adjustErr := resolveAdjustments(&config.Adjustments, opts); adjustErr != nil {
+		return adjustErr
+	}
+
+	// A changed grid no longer matches the configured canvas, so derive it
+	// unless it was given explicitly.
+	if opts.set["target-size"] {
+		config.TargetSize = opts.flags.TargetSize
+	} else if geometryChanged {
+		config.TargetSize = processor.AutoTargetSize
+	}
+
+	if validateErr := config.Validate(); validateErr != nil {
+		return fmt.Errorf("invalid configuration: %w", validateErr)
+	}
+
+	opts.config = config.Normalized()
+	return nil
+}
+
+// resolveDevice replaces the geometry and the default adjustments of config
+// with those of the device profile selected with --device.
+func (a *App) resolveDevice(config *processor.Config, opts *options) error {
+	catalog, catalogErr := a.deviceCatalog(opts.devicesFile)
+	if catalogErr != nil {
+		return catalogErr
+	}
+	profile, lookupErr := catalog.Lookup(opts.device)
+	if lookupErr != nil {
+		return lookupErr
+	}
+	deviceConfig, configErr := profile.Config()
+	if configErr != nil {
+		return configErr
+	}
+	// Only the geometry and the default adjustments come from the profile;
+	// the canvas is derived from the geometry.
+	config.TargetSize = processor.AutoTargetSize
+	config.GridSize, config.Columns, config.Rows = deviceConfig.GridSize, deviceConfig.Columns, deviceConfig.Rows
+	config.TileSize, config.Spacing = deviceConfig.TileSize, deviceConfig.Spacing
+	config.HorizontalSpacing, config.VerticalSpacing = deviceConfig.HorizontalSpacing, deviceConfig.VerticalSpacing
+	config.Device = deviceConfig.Device
+	config.Adjustments = deviceConfig.Adjustments
+	return nil
+}
+
+// resolveGeometry applies the grid, tile size and spacing flags to config and
+// reports whether any of them was set.
+func resolveGeometry(config *processor.Config, opts *options) (bool, error) {
+	var spacingMode processor.SpacingMode
+	if opts.set["spacing-mode"] {
+		mode, modeErr := processor.ParseSpacingMode(opts.spacingMode)
+		if modeErr != nil {
+			return false, modeErr
+		}
+		spacingMode = mode
+	}
+
+	// --grid and --spacing reset the per-axis values, so they are applied
+	// before --columns, --rows and the per-axis spacing flags.
+	geometry := []struct {
+		flag  string
+		apply func()
+	}{
+		{"grid", func() { config.GridSize, config.Columns, config.Rows = opts.flags.GridSize, 0, 0 }},
+		{"spacing", func() { config.Spacing, config.HorizontalSpacing, config.VerticalSpacing = opts.flags.Spacing, 0, 0 }},
+		{"columns", func() { config.Columns = opts.flags.Columns }},
+		{"rows", func() { config.Rows = opts.flags.Rows }},
+		{"tile-size", func() { config.TileSize = opts.flags.TileSize }},
+		{"horizontal-spacing", func() { config.HorizontalSpacing = opts.flags.HorizontalSpacing }},
+		{"vertical-spacing", func() { config.VerticalSpacing = opts.flags.VerticalSpacing }},
+		{"spacing-mode", func() { config.SpacingMode = spacingMode }},
+	}
+	changed := false
+	for _, override := range geometry {
+		if opts.set[override.flag] {
+			override.apply()
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// resolveOutput applies the flags that select where and how files are
+// written to config.
+func resolveOutput(config *processor.Config, opts *options) error {
+	if opts.set["out-dir"] {
+		config.OutputDir = opts.flags.OutputDir
+	}
+	if opts.set["name-template"] {
+		config.FileNameTemplate = opts.flags.FileNameTemplate
+	}
+	if opts.set["no-clobber"] && opts.set["force"] {
+		return errors.New("--no-clobber and --force cannot be used together")
+	}
+	if opts.set["no-clobber"] {
+		config.NoClobber = opts.flags.NoClobber
+	}
+	if opts.set["force"] {
+		config.NoClobber = !opts.force
+	}
+	if opts.timeout < 0 {
+		return fmt.Errorf("--timeout must not be negative, got %s", opts.timeout)
+	}
+	return nil
+}
+
+// resolveFit applies the flags that select how the image is resized, cropped
+// or padded to the canvas to config.
+func resolveFit(config *processor.Config, opts *options) error {
+	if opts.set["fit"] {
+		mode, fitErr := processor.ParseFitMode(opts.fit)
+		if fitErr != nil {
+			return fitErr
+		}
+		config.FitMode = mode
+	}
+	if opts.set["fill"] {
+		fill, colorErr := processor.ParseHexColor(opts.fill)
+		if colorErr != nil {
+			return colorErr
+		}
+		config.Fill = fill
+	}
+	if opts.set["linear-light"] {
+		config.LinearLight = opts.flags.LinearLight
+	}
+	if opts.set["optimize-gutters"] {
+		config.OptimizeGutters = opts.flags.OptimizeGutters
+	}
+	if opts.set["crop"] {
+		mode, cropErr := processor.ParseCropMode(opts.crop)
+		if cropErr != nil {
+			return cropErr
+		}
+		config.CropMode = mode
+	}
+	if opts.set["gravity"] {
+		gravity, gravityErr := processor.ParseGravity(opts.gravity)
+		if gravityErr != nil {
+			return gravityErr
+		}
+		config.Gravity = gravity
+	}
+	if opts.set["focal-point"] {
+		point, pointErr := processor.ParseFocalPoint(opts.focalPoint)
+		if pointErr != nil {
+			return pointErr
+		}
+		config.FocalPoint = &point
+	}
+	if opts.set["source-rect"] {
+		rect, rectErr := processor.ParseSourceRect(opts.sourceRect)
+		if rectErr != nil {
+			return rectErr
+		}
+		config.SourceRect = &rect
+	}
+	return nil
+}
+
+// deviceCatalog returns the built-in device profiles extended with the
+// profiles from path, or from the default devices file when path is empty.
+func (a *App) deviceCatalog(path string) (*processor.DeviceCatalog, error) {
+	catalog := processor.NewDefaultDeviceCatalog()
+
+	explicit := path != ""
+	if !explicit {
+		path = defaultDevicesFile()
+		if path == "" {
+			return catalog, nil
+		}
+	}
+
+	profiles, loadErr := a.processor.LoadDeviceProfiles(path)
+	if loadErr != nil {
+		if !explicit && errors.Is(loadErr, fs.ErrNotExist) {
+			return catalog, nil
+		}
+		return nil, loadErr
+	}
+	for _, profile := range profiles {
+		if addErr := catalog.Add(profile); addErr != nil {
+			return nil, addErr
+		}
+	}
+
+	return catalog, nil
+}
+
+// defaultDevicesFile returns the path of the user device profiles file,
+// usually ~/.config/ccbm/devices.json.
+func defaultDevicesFile() string {
+	configDir, dirErr := os.UserConfigDir()
+	if dirErr != nil {
+		return ""
+	}
+	return filepath.Join(configDir, progName, "devices.json")
+}
+
+func (c command) usageError() error {
+	return fmt.Errorf("usage: %s %s [flags] %s", progName, c.name, c.args)
+}
+
+func (c command) printUsage(w io.Writer, fs *flag.FlagSet) {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(fmt.Sprintf("Usage: %s %s [flags] %s", progName, c.name, c.args)))
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "%s%s.\n", strings.ToUpper(c.summary[:1]), c.summary[1:])
+
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		b.WriteString("\nFlags:\n")
+		fs.SetOutput(&b)
+		fs.PrintDefaults()
+		fs.SetOutput(io.Discard)
+	}
+
+	_, _ = io.WriteString(w, b.String())
+}
+
+func setupDeviceFlags(fs *flag.FlagSet, opts *options) {
+	fs.StringVar(&opts.devicesFile, "devices-file", "",
+		"JSON file with additional device profiles (default: "+defaultDevicesFile()+")")
+}
+
+func setupGeometryFlags(fs *flag.FlagSet, opts *options) {
+	fs.StringVar(&opts.device, "device", "", "device profile providing the key geometry (see 'ccbm devices')")
+	setupDeviceFlags(fs, opts)
+	fs.IntVar(&opts.flags.GridSize, "grid", opts.flags.GridSize, "number of keys per row and per column")
+	fs.IntVar(&opts.flags.Columns, "columns", opts.flags.Columns, "number of key columns (overrides --grid)")
+	fs.IntVar(&opts.flags.Rows, "rows", opts.flags.Rows, "number of key rows (overrides --grid)")
+	fs.IntVar(&opts.flags.TileSize, "tile-size", opts.flags.TileSize, "edge length of a key in pixels")
+	fs.IntVar(&opts.flags.Spacing, "spacing", opts.flags.Spacing, "gap between keys in pixels")
+	fs.IntVar(&opts.flags.HorizontalSpacing, "horizontal-spacing", opts.flags.HorizontalSpacing,
+		"gap between key columns in pixels (overrides --spacing)")
+	fs.IntVar(&opts.flags.VerticalSpacing, "vertical-spacing", opts.flags.VerticalSpacing,
+		"gap between key rows in pixels (overrides --spacing)")
+	spacingMode := processor.SpacingPhysical
+	if opts.flags.SpacingMode != "" {
+		spacingMode = opts.flags.SpacingMode
+	}
+	fs.StringVar(&opts.spacingMode, "spacing-mode", string(spacingMode),
+		"physical discards the image under the spacing, contiguous makes the tiles abut")
+}
+
+// resolveTransform applies the transform flags to transform.
+func resolveTransform(transform *processor.Transform, opts *options) error {
+	if opts.set["rotate"] {
+		transform.Rotate = opts.flags.Transform.Rotate
+	}
+	if opts.set["flip"] {
+		flip, flipErr := processor.ParseFlip(opts.flip)
+		if flipErr != nil {
+			return flipErr
+		}
+		transform.Flip = flip
+	}
+	if opts.set["zoom"] {
+		transform.Zoom = opts.flags.Transform.Zoom
+	}
+	if opts.set["pan"] {
+		point, pointErr := processor.ParseFocalPoint(opts.pan)
+		if pointErr != nil {
+			return fmt.Errorf("invalid --pan: %w", pointErr)
+		}
+		transform.Pan = &point
+	}
+	return nil
+}
+
+// resolveAdjustments applies the --adjust flags to adjustments, replacing
+// those of the device profile.
+func resolveAdjustments(adjustments *processor.Adjustments, opts *options) error {
+	if !opts.set["adjust"] {
+		return nil
+	}
+	*adjustments = processor.Adjustments{}
+	for _, value := range opts.adjust {
+		parsed, adjustErr := processor.ParseAdjustments(value)
+		if adjustErr != nil {
+			return adjustErr
+		}
+		if len(parsed) == 0 {
+			*adjustments = processor.Adjustments{}
+		}
+		*adjustments = append(*adjustments, parsed...)
+	}
+	return nil
+}
+
+func setupSplitFlags(fs *flag.FlagSet, opts *options) {
+	fs.IntVar(&opts.flags.TargetSize, "target-size", opts.flags.TargetSize,
+		"edge length in pixels the image is resized and cropped to (0 derives it from the grid)")
+	setupGeometryFlags(fs, opts)
+	fs.StringVar(&opts.flags.OutputDir, "out-dir", opts.flags.OutputDir,
+		"directory the tiles are written to (default: next to the input image)")
+	template := opts.flags.FileNameTemplate
+	if template == "" {
+		template = processor.DefaultFileNameTemplate
+	}
+	fs.StringVar(&opts.flags.FileNameTemplate, "name-template", template,
+		"tile file names relative to --out-dir, with {name}, {n}, {row}, {col}, {device} and {ext}")
+	setupFormatFlags(fs, opts)
+	setupOverwriteFlags(fs, opts)
+	setupTimeoutFlag(fs, opts)
+	fs.BoolVar(&opts.flags.IgnoreEXIF, "ignore-exif", opts.flags.IgnoreEXIF,
+		"keep images as stored instead of turning them upright according to their EXIF orientation")
+	setupTransformFlags(fs, opts)
+	setupFitFlags(fs, opts)
+	fs.Func("adjust", "color adjustment kind=amount applied to the canvas, repeatable and applied in order: "+
+		"brightness (-1 to 1), contrast, saturation, gamma or hue (degrees); none drops the device defaults",
+		func(value string) error {
+			opts.adjust = append(opts.adjust, value)
+			return nil
+		})
+	fs.StringVar(&opts.resample, "resample", string(processor.ResampleLanczos),
+		"resizing algorithm: lanczos, mitchell, bicubic, bilinear, nearest, or nearest-integer for pixel art")
+	setupLinearLightFlag(fs, opts)
+	setupCropFlags(fs, opts)
+	fs.BoolVar(&opts.flags.OptimizeGutters, "optimize-gutters", opts.flags.OptimizeGutters,
+		"shift and zoom the crop slightly to keep detail out of the spacing between keys")
+}
+
+func setupTransformFlags(fs *flag.FlagSet, opts *options) {
+	fs.Float64Var(&opts.flags.Transform.Rotate, "rotate", opts.flags.Transform.Rotate,
+		"degrees the image is rotated clockwise; angles other than multiples of 90 expose corners painted with --fill")
+	fs.StringVar(&opts.flip, "flip", "", "mirror the image: horizontal, vertical or both")
+	fs.Float64Var(&opts.flags.Transform.Zoom, "zoom", opts.flags.Transform.Zoom,
+		"magnify the image by keeping 1/zoom of its width and height (0 or 1 keeps all of it)")
+	fs.StringVar(&opts.pan, "pan", "",
+		"point the --zoom area is centered on, in pixels (1200,800) or percent (50%,30%) of the rotated image")
+}
+
+func setupFitFlags(fs *flag.FlagSet, opts *options) {
+	fit := processor.FitCover
+	if opts.flags.FitMode != "" {
+		fit = opts.flags.FitMode
+	}
+	fs.StringVar(&opts.fit, "fit", string(fit),
+		"how the image is fitted to the canvas: cover crops, contain pads, stretch distorts")
+	fs.StringVar(&opts.fill, "fill", "transparent", "color the image is padded with by --fit contain")
+}
+
+func setupCropFlags(fs *flag.FlagSet, opts *options) {
+	crop := processor.CropGravity
+	if opts.flags.CropMode != "" {
+		crop = opts.flags.CropMode
+	}
+	fs.StringVar(&opts.crop, "crop", string(crop),
+		"how the crop window is chosen: gravity uses --gravity or --focal-point, smart finds the most detailed area")
+	gravity := processor.GravityCenter
+	if opts.flags.Gravity != "" {
+		gravity = opts.flags.Gravity
+	}
+	fs.StringVar(&opts.gravity, "gravity", string(gravity),
+		"part of the image kept when cropping: center, north, south, east, west, northeast, ...")
+	fs.StringVar(&opts.focalPoint, "focal-point", "",
+		"point of the original image to crop around, in pixels (1200,800) or percent (50%,30%); overrides --gravity")
+	fs.StringVar(&opts.sourceRect, "source-rect", "",
+		"exact area x,y,w,h of the image, in pixels, resized to the canvas instead of --fit and the crop flags")
+}
+
+func setupSplitCommandFlags(fs *flag.FlagSet, opts *options) {
+	setupSplitFlags(fs, opts)
+	fs.BoolVar(&opts.cropDebug, "crop-debug", false,
+		"also write <name>_crop.<ext> showing the crop window on the original image")
+	fs.BoolVar(&opts.dryRun, "dry-run", false,
+		"process the image and print the files that would be written, without writing anything")
+	fs.IntVar(&opts.jobs, "jobs", runtime.NumCPU(), "number of images processed at the same time")
+	fs.BoolVar(&opts.recursive, "recursive", false, "also split the images in subdirectories of directory arguments")
+}
+
+func setupPreviewFlags(fs *flag.FlagSet, opts *options) {
+	setupSplitFlags(fs, opts)
+	fs.StringVar(&opts.output, "output", "", "preview file path (default: <name>_preview.<ext> in the output directory)")
+	fs.StringVar(&opts.background, "background", "#000000", "color of the spacing between keys")
+}
+
+func setupJoinFlags(fs *flag.FlagSet, opts *options) {
+	setupGeometryFlags(fs, opts)
+	fs.StringVar(&opts.output, "output", "", "path of the joined image (required)")
+	setupFormatFlags(fs, opts)
+	setupOverwriteFlags(fs, opts)
+	setupTimeoutFlag(fs, opts)
+	setupLinearLightFlag(fs, opts)
+	fs.StringVar(&opts.background, "background", "#000000", "color of the spacing between keys")
+}
+
+func setupFormatFlags(fs *flag.FlagSet, opts *options) {
+	formats := processor.NewDefaultEncoderRegistry().Formats()
+	fs.StringVar(&opts.format, "format", opts.format, "output image format: "+strings.Join(formats, ", "))
+	fs.IntVar(&opts.quality, "quality", processor.DefaultJPEGQuality, "JPEG quality from 1 to 100")
+	fs.StringVar(&opts.compression, "compression", string(processor.PNGCompressionDefault),
+		"PNG compression level: default, none, fast or best")
+	fs.IntVar(&opts.colors, "colors", 0, "quantize PNG output to at most this many colors, from 2 to 256 (0 keeps full color)")
+	fs.IntVar(&opts.maxBytes, "max-bytes", 0,
+		"largest PNG tile size in bytes; colors are reduced until each tile fits (0 means no limit)")
+}
+
+func setupOverwriteFlags(fs *flag.FlagSet, opts *options) {
+	fs.BoolVar(&opts.flags.NoClobber, "no-clobber", opts.flags.NoClobber,
+		"fail instead of replacing existing files; nothing is written if any tile exists")
+	fs.BoolVar(&opts.force, "force", false, "replace existing files (overrides a configured --no-clobber)")
+}
+
+func setupLinearLightFlag(fs *flag.FlagSet, opts *options) {
+	fs.BoolVar(&opts.flags.LinearLight, "linear-light", opts.flags.LinearLight,
+		"resize and blend transparent pixels in linear light, which keeps fine bright detail from darkening")
+}
+
+func setupTimeoutFlag(fs *flag.FlagSet, opts *options) {
+	fs.DurationVar(&opts.timeout, "timeout", 0,
+		"give up after this long, e.g. 30s or 2m, removing any partial output (0 means no limit)")
+}
+
+// withTimeout returns a context that is also cancelled after --timeout, if given.
+func withTimeout(ctx context.Context, opts *options) (context.Context, context.CancelFunc) {
+	if opts.timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, opts.timeout)
+}
+
+// outputService returns the service for the effective configuration, resizing
+// images with the algorithm selected by --resample and writing them with the
+// encoder selected by --format and the encoding flags when given.
+func (a *App) outputService(opts *options) (*processor.Service, error) {
+	service, configErr := a.processor.WithConfig(opts.config)
+	if configErr != nil {
+		return nil, configErr
+	}
+	if opts.set["resample"] {
+		resample, resampleErr := processor.ParseResample(opts.resample)
+		if resampleErr != nil {
+			return nil, resampleErr
+		}
+		resizer, resizerErr := processor.NewResizer(resample)
+		if resizerErr != nil {
+			return nil, resizerErr
+		}
+		service = service.WithResizer(resizer)
+	}
+	if !opts.set["format"] && !opts.set["quality"] && !opts.set["compression"] &&
+		!opts.set["colors"] && !opts.set["max-bytes"] {
+		return service, nil
+	}
+
+	compression, compressionErr := processor.ParsePNGCompression(opts.compression)
+	if compressionErr != nil {
+		return nil, compressionErr
+	}
+	encoder, encoderErr := processor.NewDefaultEncoderRegistry().Encoder(opts.format, processor.EncodeOptions{
+		Quality:     opts.quality,
+		Compression: compression,
+		Colors:      opts.colors,
+		MaxBytes:    opts.maxBytes,
+	})
+	if encoderErr != nil {
+		return nil, encoderErr
+	}
+	if _, isPNG := encoder.(*processor.PNGEncoder); !isPNG {
+		for _, name := range []string{"compression", "colors", "max-bytes"} {
+			if opts.set[name] {
+				return nil, fmt.Errorf("--%s applies to PNG output only, not to --format %s", name, opts.format)
+			}
+		}
+	}
+	return service.WithEncoder(encoder), nil
+}
+
+// ignoreHelp turns a help request into a successful run.
+func ignoreHelp(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+func runSplit(ctx context.Context, a *App, cmd command, args []string) error {
+	opts, paths, parseErr := cmd.parse(a, args)
+	if parseErr != nil {
+		return ignoreHelp(parseErr)
+	}
+	if len(paths) == 0 {
+		return cmd.usageError()
+	}
+	if opts.jobs < 1 {
+		return fmt.Errorf("--jobs must be at least 1, got %d", opts.jobs)
+	}
+
+	service, serviceErr := a.outputService(opts)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	inputs, expandErr := service.ExpandInputs(paths, opts.recursive)
+	if expandErr != nil {
+		return expandErr
+	}
+	if collisionErr := service.CheckOutputCollisions(inputs); collisionErr != nil {
+		return collisionErr
+	}
+
+	ctx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+	if len(inputs) == 1 {
+		return splitImage(ctx, service, opts, inputs[0], a.stdout)
+	}
+
+	results := runBatch(ctx, inputs, opts.jobs, a.stdout, func(path string, out io.Writer) error {
+		return splitImage(ctx, service, opts, path, out)
+	})
+	summaryErr := summarizeBatch(a.stdout, results)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("batch stopped: %w", ctxErr)
+	}
+	return summaryErr
+}
+
+// splitImage splits one image and writes what it did to out.
+func splitImage(
+	ctx context.Context, service *processor.Service, opts *options, imagePath string, out io.Writer,
+) error {
+	procImg, loadErr := loadAndProcess(ctx, service, imagePath)
+	if loadErr != nil {
+		return loadErr
+	}
+	if opts.dryRun {
+		return printPlan(out, service, procImg, imagePath)
+	}
+	if saveErr := service.SaveTiles(ctx, procImg, imagePath); saveErr != nil {
+		return fmt.Errorf("failed to save tiles: %w", saveErr)
+	}
+	if opts.set["max-bytes"] {
+		for _, saved := range procImg.Saved {
+			_, _ = fmt.Fprintf(out, "Tile %d: %s (%d bytes)\n", saved.Coord.Number, saved.Path, saved.Size)
+		}
+	}
+	if procImg.Gutters != nil {
+		_, _ = fmt.Fprintf(out, "Gutters: %s\n", formatGutters(*procImg.Gutters))
+	}
+	if !opts.cropDebug {
+		return nil
+	}
+
+	// The crop window is outlined in magenta, which photos rarely contain.
+	outline := color.NRGBA{R: 255, B: 255, A: 255}
+	debugPath := siblingPath(opts.config, imagePath, "_crop."+service.OutputExtension())
+	if saveErr := service.SaveImage(ctx, processor.CropDebugImage(procImg, outline), debugPath); saveErr != nil {
+		return fmt.Errorf("failed to save crop window: %w", saveErr)
+	}
+	_, _ = fmt.Fprintf(out, "Crop window written to %s\n", debugPath)
+	return nil
+}
+
+// loadAndProcess loads an image and runs it through the processing pipeline.
+func loadAndProcess(
+	ctx context.Context, service *processor.Service, imagePath string,
+) (*processor.ProcessedImage, error) {
+	procImg, loadErr := service.LoadImage(ctx, imagePath)
+	if loadErr != nil {
+		return nil, fmt.Errorf("failed to load image: %w", loadErr)
+	}
+	procImg, processErr := service.ProcessImageData(ctx, procImg)
+	if processErr != nil {
+		return nil, fmt.Errorf("failed to process image: %w", processErr)
+	}
+	return procImg, nil
+}
+
+// printPlan prints what split would do with a processed image.
+func printPlan(w io.Writer, service *processor.Service, procImg *processor.ProcessedImage, imagePath string) error {
+	plan, planErr := service.PlanTiles(procImg, imagePath)
+	if planErr != nil {
+		return planErr
+	}
+
+	crop := procImg.Crop
+	existing := 0
+	var b strings.Builder
+	fmt.Fprintf(&b, "Dry run, no files written for %s\n", imagePath)
+	if procImg.Transform != nil {
+		fmt.Fprintf(&b, "Transform:  %s\n", formatTransform(*procImg.Transform))
+	}
+	fmt.Fprintf(&b, "Resized:    %s\n", formatSize(procImg.Resized.Bounds()))
+	fmt.Fprintf(&b, "Crop:       %s at %d,%d\n", formatSize(crop), crop.Min.X, crop.Min.Y)
+	if procImg.Gutters != nil {
+		fmt.Fprintf(&b, "Gutters:    %s\n", formatGutters(*procImg.Gutters))
+	}
+	b.WriteString("Tiles:\n")
+	for _, tile := range plan {
+		action := "create"
+		if tile.Exists {
+			action = "overwrite"
+			existing++
+		}
+		fmt.Fprintf(&b, "  %d (row %d, col %d) -> %s (%s)\n",
+			tile.Coord.Number, tile.Coord.Row+1, tile.Coord.Col+1, tile.Path, action)
+	}
+	if existing > 0 && service.Config().NoClobber {
+		fmt.Fprintf(&b, "--no-clobber: %d of %d tiles exist, nothing would be written\n", existing, len(plan))
+	}
+
+	_, _ = io.WriteString(w, b.String())
+	return nil
+}
+
+func runPreview(ctx context.Context, a *App, cmd command, args []string) error {
+	opts, paths, parseErr := cmd.parse(a, args)
+	if parseErr != nil {
+		return ignoreHelp(parseErr)
+	}
+	if len(paths) == 0 {
+		return cmd.usageError()
+	}
+	background, colorErr := processor.ParseHexColor(opts.background)
+	if colorErr != nil {
+		return colorErr
+	}
+
+	service, serviceErr := a.outputService(opts)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	ctx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+	procImg, loadErr := loadAndProcess(ctx, service, paths[0])
+	if loadErr != nil {
+		return loadErr
+	}
+
+	// The preview shows the keys as they sit on the device, with their gaps.
+	deviceLayout := opts.config
+	deviceLayout.SpacingMode = processor.SpacingPhysical
+	preview, joinErr := processor.JoinTiles(procImg.Result.Tiles, deviceLayout, background)
+	if joinErr != nil {
+		return joinErr
+	}
+
+	outputPath := opts.output
+	if outputPath == "" {
+		outputPath = siblingPath(opts.config, paths[0], "_preview."+service.OutputExtension())
+	}
+	if saveErr := service.SaveImage(ctx, preview, outputPath); saveErr != nil {
+		return fmt.Errorf("failed to save preview: %w", saveErr)
+	}
+
+	_, _ = fmt.Fprintf(a.stdout, "Preview written to %s\n", outputPath)
+	return nil
+}
+
+func runInfo(ctx context.Context, a *App, cmd command, args []string) error {
+	opts, paths, parseErr := cmd.parse(a, args)
+	if parseErr != nil {
+		return ignoreHelp(parseErr)
+	}
+	if len(paths) == 0 {
+		return cmd.usageError()
+	}
+
+	service, serviceErr := a.outputService(opts)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	ctx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+	procImg, loadErr := loadAndProcess(ctx, service, paths[0])
+	if loadErr != nil {
+		return loadErr
+	}
+
+	config := opts.config
+	var b strings.Builder
+	fmt.Fprintf(&b, "File:       %s\n", paths[0])
+	fmt.Fprintf(&b, "Format:     %s\n", procImg.Format)
+	fmt.Fprintf(&b, "Dimensions: %s\n", formatSize(procImg.Original.Bounds()))
+	if procImg.ColorProfile != "" {
+		fmt.Fprintf(&b, "Profile:    %s, converted to sRGB\n", procImg.ColorProfile)
+	}
+	if procImg.Orientation.Transforms() {
+		fmt.Fprintf(&b, "EXIF:       %s to be upright\n", procImg.Orientation)
+	}
+	if procImg.Transform != nil {
+		fmt.Fprintf(&b, "Transform:  %s\n", formatTransform(*procImg.Transform))
+	}
+	fmt.Fprintf(&b, "Resized:    %s\n", formatSize(procImg.Resized.Bounds()))
+	fmt.Fprintf(&b, "Canvas:     %s\n", formatSize(procImg.Squared.Bounds()))
+	fmt.Fprintf(&b, "Fit:        %s\n", formatFit(config))
+	fmt.Fprintf(&b, "Crop:       %s\n", formatCrop(config))
+	if len(config.Adjustments) > 0 {
+		fmt.Fprintf(&b, "Adjust:     %s\n", config.Adjustments)
+	}
+	if procImg.Gutters != nil {
+		fmt.Fprintf(&b, "Gutters:    %s\n", formatGutters(*procImg.Gutters))
+	}
+	fmt.Fprintf(&b, "Grid:       %dx%d keys of %dpx with %s\n",
+		config.GridColumns(), config.GridRows(), config.TileSize, formatSpacing(config))
+	b.WriteString("Tiles:\n")
+	for _, coord := range procImg.Result.TileCoords {
+		fmt.Fprintf(&b, "  %d (row %d, col %d) -> %s\n",
+			coord.Number, coord.Row+1, coord.Col+1, service.TileOutputPath(paths[0], coord))
+	}
+
+	_, _ = io.WriteString(a.stdout, b.String())
+	return nil
+}
+
+func runJoin(ctx context.Context, a *App, cmd command, args []string) error {
+	opts, paths, parseErr := cmd.parse(a, args)
+	if parseErr != nil {
+		return ignoreHelp(parseErr)
+	}
+	if len(paths) == 0 {
+		return cmd.usageError()
+	}
+	if opts.output == "" {
+		return errors.New("join requires --output")
+	}
+	background, colorErr := processor.ParseHexColor(opts.background)
+	if colorErr != nil {
+		return colorErr
+	}
+
+	service, serviceErr := a.outputService(opts)
+	if serviceErr != nil {
+		return serviceErr
+	}
+	ctx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+	tiles := make([]image.Image, 0, len(paths))
+	for _, path := range paths {
+		tile, loadErr := service.LoadImage(ctx, path)
+		if loadErr != nil {
+			return fmt.Errorf("failed to load tile %s: %w", path, loadErr)
+		}
+		tiles = append(tiles, tile.Original)
+	}
+
+	joined, joinErr := processor.JoinTiles(tiles, opts.config, background)
+	if joinErr != nil {
+		return joinErr
+	}
+	if saveErr := service.SaveImage(ctx, joined, opts.output); saveErr != nil {
+		return fmt.Errorf("failed to save joined image: %w", saveErr)
+	}
+
+	_, _ = fmt.Fprintf(a.stdout, "Joined %d tiles into %s\n", len(tiles), opts.output)
+	return nil
+}
+
+func runDevices(_ context.Context, a *App, cmd command, args []string) error {
+	opts, _, parseErr := cmd.parse(a, args)
+	if parseErr != nil {
+		return ignoreHelp(parseErr)
+	}
+
+	catalog, catalogErr := a.deviceCatalog(opts.devicesFile)
+	if catalogErr != nil {
+		return catalogErr
+	}
+
+	tw := tabwriter.NewWriter(a.stdout, 0, 0, tablePadding, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tLAYOUT\tKEY\tSPACING\tCANVAS\tDESCRIPTION")
+	for _, profile := range catalog.Profiles() {
+		config, configErr := profile.Config()
+		if configErr != nil {
+			return configErr
+		}
+		spacing := fmt.Sprintf("%dpx", config.SpacingX())
+		if config.SpacingX() != config.SpacingY() {
+			spacing = fmt.Sprintf("%dx%dpx", config.SpacingX(), config.SpacingY())
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%dx%d\t%dpx\t%s\t%dx%d\t%s\n",
+			profile.Name, profile.Columns, profile.Rows, profile.TileSize, spacing,
+			profile.Width(), profile.Height(), profile.Description)
+	}
+	return tw.Flush()
+}
+
+func runVersion(_ context.Context, a *App, cmd command, args []string) error {
+	if _, _, parseErr := cmd.parse(a, args); parseErr != nil {
+		return ignoreHelp(parseErr)
+	}
+
+	_, _ = fmt.Fprintf(a.stdout, "%s %s\n", progName, version())
+	return nil
+}
+
+// version reports the module version embedded by `go install` or `go build`.
+func version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// formatFit describes the fit mode, with the padding color for contain.
+func formatFit(config processor.Config) string {
+	if config.SourceRect != nil {
+		return "source rectangle resized to the canvas"
+	}
+	switch config.FitMode {
+	case "", processor.FitCover:
+		return string(processor.FitCover)
+	case processor.FitContain:
+		if config.Fill.A == 0 {
+			return "contain on transparent"
+		}
+		return fmt.Sprintf("contain on #%02x%02x%02x%02x", config.Fill.R, config.Fill.G, config.Fill.B, config.Fill.A)
+	default:
+		return string(config.FitMode)
+	}
+}
+
+// formatCrop describes what the crop is centered on.
+func formatCrop(config processor.Config) string {
+	if rect := config.SourceRect; rect != nil {
+		return fmt.Sprintf("source rectangle %d,%d,%d,%d", rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy())
+	}
+	if config.CropMode == processor.CropSmart {
+		return string(processor.CropSmart)
+	}
+	if config.FocalPoint != nil {
+		return "around " + config.FocalPoint.String()
+	}
+	if config.Gravity == "" {
+		return string(processor.GravityCenter)
+	}
+	return string(config.Gravity)
+}
+
+// formatTransform describes the transform and the area kept by its zoom.
+func formatTransform(report processor.TransformReport) string {
+	rotated := formatSize(image.Rectangle{Max: report.Rotated})
+	if report.Region.Size() == report.Rotated {
+		return fmt.Sprintf("%s, %s", report.Transform, rotated)
+	}
+	return fmt.Sprintf("%s, %s at %d,%d of %s",
+		report.Transform, formatSize(report.Region), report.Region.Min.X, report.Region.Min.Y, rotated)
+}
+
+// formatGutters describes the crop adjustment made by the gutter optimizer.
+func formatGutters(report processor.GutterReport) string {
+	loss := fmt.Sprintf("%.1f%% of the detail lost in the spacing", report.DetailLost*percent)
+	if !report.Moved() {
+		return "crop kept, " + loss
+	}
+	return fmt.Sprintf("crop moved by %+d,%+d at %.3fx, %s (was %.1f%%)",
+		report.Offset.X, report.Offset.Y, report.Scale, loss, report.BaselineLost*percent)
+}
+
+// siblingPath places a file derived from inputPath in the output directory.
+func siblingPath(config processor.Config, inputPath, suffix string) string {
+	dir := filepath.Dir(inputPath)
+	if config.OutputDir != "" {
+		dir = config.OutputDir
+	}
+	name := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+	return filepath.Join(dir, name+suffix)
+}
+
+func formatSpacing(config processor.Config) string {
+	if config.SpacingMode == processor.SpacingContiguous {
+		return "contiguous tiles"
+	}
+	if config.SpacingX() == config.SpacingY() {
+		return fmt.Sprintf("%dpx spacing", config.SpacingX())
+	}
+	return fmt.Sprintf("%dpx horizontal and %dpx vertical spacing", config.SpacingX(), config.SpacingY())
+}
+
+func formatSize(bounds image.Rectangle) string {
+	return fmt.Sprintf("%dx%d", bounds.Dx(), bounds.Dy())
+}
@@ -0,0 +1,862 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	iofs "io/fs"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/cli"
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func newTestApp(t *testing.T) (*cli.App, *processor.TestMockFileSystem, *bytes.Buffer) {
+	t.Helper()
+
+	fs := processor.NewTestMockFileSystem()
+	decoder := processor.NewTestMockImageDecoder(processor.CreateTestImage(400, 300), "jpeg", nil)
+	encoder := processor.NewTestMockImageEncoder(nil)
+	resizer := processor.NewTestMockImageResizer()
+
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+	app := cli.NewAppWithProcessor(service)
+
+	var stdout bytes.Buffer
+	app.SetOutput(&stdout, &bytes.Buffer{})
+	fs.AddFile("/test/image.jpg", []byte("fake image data"))
+
+	return app, fs, &stdout
+}
+
+func TestApp_Run_Help(t *testing.T) {
+	for _, flag := range []string{"--help", "-h", "help"} {
+		t.Run(flag, func(t *testing.T) {
+			app, _, stdout := newTestApp(t)
+
+			require.NoError(t, app.Run([]string{"ccbm", flag}))
+
+			for _, name := range []string{"split", "preview", "info", "join", "version"} {
+				assert.Contains(t, stdout.String(), name)
+			}
+		})
+	}
+}
+
+func TestApp_Run_CommandHelp(t *testing.T) {
+	app, fs, stdout := newTestApp(t)
+
+	require.NoError(t, app.Run([]string{"ccbm", "split", "--help"}))
+
+	assert.Contains(t, stdout.String(), "Usage: ccbm split [flags] <image_path>")
+	assert.Contains(t, stdout.String(), "-tile-size")
+	_, written := fs.GetWrittenFile("/test/image_1.png")
+	assert.False(t, written)
+}
+
+func TestApp_Run_HelpForCommand(t *testing.T) {
+	app, _, stdout := newTestApp(t)
+
+	require.NoError(t, app.Run([]string{"ccbm", "help", "join"}))
+
+	assert.Contains(t, stdout.String(), "Usage: ccbm join [flags] <tile_path>...")
+	assert.Contains(t, stdout.String(), "-output")
+}
+
+func TestApp_Run_HelpUnknownCommand(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "help", "bogus"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "unknown command")
+}
+
+func TestApp_Run_SplitWithFlags(t *testing.T) {
+	app, fs, _ := newTestApp(t)
+
+	runErr := app.Run([]string{
+		"ccbm", "split", "--grid", "2", "--tile-size", "90", "--spacing", "10", "--target-size", "200",
+		"--out-dir", "/out", "/test/image.jpg",
+	})
+
+	require.NoError(t, runErr)
+	for _, name := range []string{"/out/image_1.png", "/out/image_4.png"} {
+		_, exists := fs.GetWrittenFile(name)
+		assert.True(t, exists, "expected %s to be written", name)
+	}
+	_, exists := fs.GetWrittenFile("/out/image_5.png")
+	assert.False(t, exists)
+}
+
+func TestApp_Run_BareInvocationAcceptsFlags(t *testing.T) {
+	app, fs, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "/test/image.jpg", "--out-dir", "/keys"})
+
+	require.NoError(t, runErr)
+	_, exists := fs.GetWrittenFile("/keys/image_9.png")
+	assert.True(t, exists)
+}
+
+func TestApp_Run_SplitMissingImage(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--grid", "3"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "usage: ccbm split [flags] <image_path>")
+}
+
+func TestApp_Run_SplitInvalidFlag(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--grid", "three", "/test/image.jpg"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "ccbm split --help")
+}
+
+func TestApp_Run_SplitUnsupportedFormat(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--format", "tga", "/test/image.jpg"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "unsupported output format")
+}
+
+func TestApp_Run_Preview(t *testing.T) {
+	app, fs, stdout := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "preview", "--background", "#202020", "/test/image.jpg"})
+
+	require.NoError(t, runErr)
+	_, exists := fs.GetWrittenFile("/test/image_preview.png")
+	assert.True(t, exists)
+	assert.Contains(t, stdout.String(), "/test/image_preview.png")
+}
+
+func TestApp_Run_PreviewInvalidBackground(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "preview", "--background", "nope", "/test/image.jpg"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "invalid color")
+}
+
+func TestApp_Run_Info(t *testing.T) {
+	app, fs, stdout := newTestApp(t)
+
+	require.NoError(t, app.Run([]string{"ccbm", "info", "/test/image.jpg"}))
+
+	output := stdout.String()
+	assert.Contains(t, output, "Format:     jpeg")
+	assert.Contains(t, output, "Dimensions: 400x300")
+	assert.Contains(t, output, "Canvas:     378x378")
+	assert.Contains(t, output, "9 (row 3, col 3) -> /test/image_9.png")
+	_, exists := fs.GetWrittenFile("/test/image_1.png")
+	assert.False(t, exists, "info must not write tiles")
+}
+
+func TestApp_Run_Join(t *testing.T) {
+	app, fs, stdout := newTestApp(t)
+	args := []string{"ccbm", "join", "--grid", "2", "--output", "/test/joined.png"}
+	for _, name := range []string{"/t/1.png", "/t/2.png", "/t/3.png", "/t/4.png"} {
+		fs.AddFile(name, []byte("tile"))
+		args = append(args, name)
+	}
+
+	require.NoError(t, app.Run(args))
+
+	_, exists := fs.GetWrittenFile("/test/joined.png")
+	assert.True(t, exists)
+	assert.Contains(t, stdout.String(), "Joined 4 tiles")
+}
+
+func TestApp_Run_JoinWrongTileCount(t *testing.T) {
+	app, fs, _ := newTestApp(t)
+	fs.AddFile("/t/1.png", []byte("tile"))
+
+	runErr := app.Run([]string{"ccbm", "join", "--output", "/test/joined.png", "/t/1.png"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "expected 9 tiles")
+}
+
+func TestApp_Run_JoinRequiresOutput(t *testing.T) {
+	app, fs, _ := newTestApp(t)
+	fs.AddFile("/t/1.png", []byte("tile"))
+
+	runErr := app.Run([]string{"ccbm", "join", "/t/1.png"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "--output")
+}
+
+func TestApp_Run_Version(t *testing.T) {
+	for _, arg := range []string{"version", "--version"} {
+		t.Run(arg, func(t *testing.T) {
+			app, _, stdout := newTestApp(t)
+
+			require.NoError(t, app.Run([]string{"ccbm", arg}))
+
+			assert.Contains(t, stdout.String(), "ccbm ")
+		})
+	}
+}
+
+func TestApp_Run_Devices(t *testing.T) {
+	app, fs, stdout := newTestApp(t)
+	fs.AddFile("/cfg/devices.json", []byte(`{"devices": [
+		{"name": "desk-pad", "columns": 4, "rows": 4, "tile_size": 80, "spacing": 10},
+		{"name": "wide-pad", "columns": 3, "rows": 2, "tile_size": 80, "spacing": 10, "vertical_spacing": 20},
+		{"name": "flat-pad", "columns": 3, "rows": 1, "tile_size": 80, "horizontal_spacing": 12, "vertical_spacing": 12}
+	]}`))
+
+	require.NoError(t, app.Run([]string{"ccbm", "devices", "--devices-file", "/cfg/devices.json"}))
+
+	output := stdout.String()
+	assert.Contains(t, output, "mx-creative-console")
+	assert.Regexp(t, `desk-pad\s+4x4\s+80px\s+10px\s+350x350`, output)
+	assert.Regexp(t, `wide-pad\s+3x2\s+80px\s+10x20px\s+260x180`, output)
+	assert.Regexp(t, `flat-pad\s+3x1\s+80px\s+12px\s+264x80`, output)
+}
+
+func TestApp_Run_SplitWithCustomDevice(t *testing.T) {
+	app, fs, _ := newTestApp(t)
+	fs.AddFile("/cfg/devices.json", []byte(`{"devices": [{"name": "desk-pad", "columns": 2, "rows": 2, "tile_size": 80, "spacing": 10}]}`))
+
+	runErr := app.Run([]string{"ccbm", "split", "--devices-file", "/cfg/devices.json", "--device", "desk-pad", "/test/image.jpg"})
+
+	require.NoError(t, runErr)
+	_, exists := fs.GetWrittenFile("/test/image_4.png")
+	assert.True(t, exists)
+	_, exists = fs.GetWrittenFile("/test/image_5.png")
+	assert.False(t, exists)
+}
+
+func TestApp_Run_DeviceFlagsOverrideProfile(t *testing.T) {
+	app, fs, stdout := newTestApp(t)
+	fs.AddFile("/cfg/devices.json", []byte(`{"devices": [{"name": "desk-pad", "columns": 2, "rows": 2, "tile_size": 80, "spacing": 10}]}`))
+
+	runErr := app.Run([]string{
+		"ccbm", "info", "--spacing", "20", "--target-size", "180",
+		"--devices-file", "/cfg/devices.json", "--device", "desk-pad", "/test/image.jpg",
+	})
+
+	require.NoError(t, runErr)
+	assert.Contains(t, stdout.String(), "2x2 keys of 80px with 20px spacing")
+}
+
+func TestApp_Run_UnknownDevice(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--device", "toaster", "/test/image.jpg"})
+
+	require.ErrorIs(t, runErr, processor.ErrUnknownDevice)
+}
+
+func TestApp_Run_MissingDevicesFile(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "devices", "--devices-file", "/nope.json"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "error opening device profiles")
+}
+
+func TestApp_Run_InvalidConfig(t *testing.T) {
+	app, fs, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--target-size", "300", "/test/image.jpg"})
+
+	var configErr *processor.ConfigError
+	require.ErrorAs(t, runErr, &configErr)
+	assert.Equal(t, "TargetSize", configErr.Field)
+	_, exists := fs.GetWrittenFile("/test/image_1.png")
+	assert.False(t, exists, "nothing should be written for an invalid configuration")
+}
+
+func TestApp_Run_GeometryFlagsDeriveTargetSize(t *testing.T) {
+	app, _, stdout := newTestApp(t)
+
+	require.NoError(t, app.Run([]string{"ccbm", "info", "--grid", "2", "--tile-size", "90", "--spacing", "10", "/test/image.jpg"}))
+
+	assert.Contains(t, stdout.String(), "Canvas:     190x190")
+}
+
+func TestApp_Run_SplitRectangularGrid(t *testing.T) {
+	app, fs, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--columns", "5", "--rows", "3", "--tile-size", "72", "/test/image.jpg"})
+
+	require.NoError(t, runErr)
+	_, exists := fs.GetWrittenFile("/test/image_15.png")
+	assert.True(t, exists)
+	_, exists = fs.GetWrittenFile("/test/image_16.png")
+	assert.False(t, exists)
+}
+
+func TestApp_Run_InfoRectangularDevice(t *testing.T) {
+	app, fs, stdout := newTestApp(t)
+	fs.AddFile("/cfg/devices.json", []byte(`{"devices": [{"name": "wide-pad", "columns": 5, "rows": 3, "tile_size": 72, "spacing": 24}]}`))
+
+	require.NoError(t, app.Run([]string{
+		"ccbm", "info", "--devices-file", "/cfg/devices.json", "--device", "wide-pad",
+		"--vertical-spacing", "12", "/test/image.jpg",
+	}))
+
+	output := stdout.String()
+	assert.Contains(t, output, "Grid:       5x3 keys of 72px with 24px horizontal and 12px vertical spacing")
+	assert.Contains(t, output, "Canvas:     456x240")
+	assert.Contains(t, output, "15 (row 3, col 5)")
+}
+
+func TestApp_Run_InfoFitContain(t *testing.T) {
+	app, _, stdout := newTestApp(t)
+
+	require.NoError(t, app.Run([]string{"ccbm", "info", "--fit", "contain", "--fill", "#fff", "/test/image.jpg"}))
+
+	assert.Contains(t, stdout.String(), "Fit:        contain on #ffffffff")
+}
+
+func TestApp_Run_InvalidFit(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--fit", "zoom", "/test/image.jpg"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), `invalid fit mode "zoom"`)
+}
+
+func TestApp_Run_InfoCrop(t *testing.T) {
+	app, _, stdout := newTestApp(t)
+
+	require.NoError(t, app.Run([]string{"ccbm", "info", "--gravity", "north-east", "/test/image.jpg"}))
+	assert.Contains(t, stdout.String(), "Crop:       northeast")
+
+	stdout.Reset()
+	require.NoError(t, app.Run([]string{"ccbm", "info", "--gravity", "south", "--focal-point", "25%,75%", "/test/image.jpg"}))
+	assert.Contains(t, stdout.String(), "Crop:       around 25%,75%")
+}
+
+func TestApp_Run_InvalidFocalPoint(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--focal-point", "middle", "/test/image.jpg"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), `invalid focal point "middle"`)
+}
+
+func TestApp_Run_SplitSmartCropDebug(t *testing.T) {
+	app, fs, stdout := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--crop", "smart", "--crop-debug", "/test/image.jpg"})
+
+	require.NoError(t, runErr)
+	_, exists := fs.GetWrittenFile("/test/image_9.png")
+	assert.True(t, exists)
+	_, exists = fs.GetWrittenFile("/test/image_crop.png")
+	assert.True(t, exists)
+	assert.Contains(t, stdout.String(), "Crop window written to /test/image_crop.png")
+}
+
+func TestApp_Run_InfoOptimizeGutters(t *testing.T) {
+	app, _, stdout := newTestApp(t)
+
+	require.NoError(t, app.Run([]string{"ccbm", "info", "--optimize-gutters", "/test/image.jpg"}))
+
+	assert.Contains(t, stdout.String(), "Gutters:    crop kept, 0.0% of the detail lost in the spacing")
+}
+
+func TestApp_Run_InfoContiguousSpacing(t *testing.T) {
+	app, _, stdout := newTestApp(t)
+
+	require.NoError(t, app.Run([]string{"ccbm", "info", "--spacing-mode", "contiguous", "/test/image.jpg"}))
+
+	output := stdout.String()
+	assert.Contains(t, output, "Canvas:     348x348")
+	assert.Contains(t, output, "Grid:       3x3 keys of 116px with contiguous tiles")
+}
+
+func TestApp_Run_PreviewContiguousKeepsDeviceGaps(t *testing.T) {
+	_, fs, _ := newTestApp(t)
+	var previewSize image.Point
+	service, serviceErr := processor.NewServiceWithDeps(fs,
+		processor.NewTestMockImageDecoder(processor.CreateTestImage(400, 300), "jpeg", nil),
+		&processor.TestMockImageEncoder{EncodeFunc: func(_ io.Writer, img image.Image) error {
+			previewSize = img.Bounds().Size()
+			return nil
+		}},
+		processor.NewTestMockImageResizer(), processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+	app := cli.NewAppWithProcessor(service)
+	app.SetOutput(io.Discard, io.Discard)
+
+	require.NoError(t, app.Run([]string{"ccbm", "preview", "--spacing-mode", "contiguous", "/test/image.jpg"}))
+
+	assert.Equal(t, image.Pt(378, 378), previewSize)
+}
+
+func TestApp_Run_SplitNameTemplate(t *testing.T) {
+	app, fs, _ := newTestApp(t)
+	fs.AddFile("/cfg/devices.json", []byte(`{"devices": [{"name": "wide-pad", "columns": 5, "rows": 3, "tile_size": 72, "spacing": 24}]}`))
+
+	runErr := app.Run([]string{
+		"ccbm", "split", "--devices-file", "/cfg/devices.json", "--device", "wide-pad", "--out-dir", "/out",
+		"--name-template", "{device}/{name}-r{row}c{col}.{ext}", "/test/image.jpg",
+	})
+
+	require.NoError(t, runErr)
+	assert.True(t, fs.HasDir("/out/wide-pad"))
+	_, exists := fs.GetWrittenFile("/out/wide-pad/image-r3c5.png")
+	assert.True(t, exists)
+}
+
+func TestApp_Run_InvalidNameTemplate(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--name-template", "{name}.png", "/test/image.jpg"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "invalid FileNameTemplate")
+}
+
+func TestApp_Run_SplitFormat(t *testing.T) {
+	testCases := []struct {
+		format string
+		file   string
+		magic  string
+	}{
+		{"jpeg", "/test/image_1.jpg", "\xff\xd8"},
+		{"jpg", "/test/image_1.jpg", "\xff\xd8"},
+		{"bmp", "/test/image_1.bmp", "BM"},
+		{"gif", "/test/image_1.gif", "GIF8"},
+		{"webp", "/test/image_1.webp", "RIFF"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			app, fs, _ := newTestApp(t)
+
+			runErr := app.Run([]string{"ccbm", "split", "--format", tc.format, "/test/image.jpg"})
+
+			require.NoError(t, runErr)
+			content, exists := fs.GetWrittenFile(tc.file)
+			require.True(t, exists, "expected %s to be written", tc.file)
+			assert.Equal(t, tc.magic, string(content[:len(tc.magic)]))
+			_, pngExists := fs.GetWrittenFile("/test/image_1.png")
+			assert.False(t, pngExists)
+		})
+	}
+}
+
+func TestApp_Run_InvalidQuality(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--format", "jpeg", "--quality", "101", "/test/image.jpg"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "invalid JPEG quality")
+}
+
+func TestApp_Run_PNGOptionsRejectedForOtherFormats(t *testing.T) {
+	testCases := []struct {
+		format string
+		flag   string
+		value  string
+	}{
+		{"jpeg", "--colors", "16"},
+		{"webp", "--max-bytes", "4096"},
+		{"jpg", "--compression", "best"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format+tc.flag, func(t *testing.T) {
+			app, fs, _ := newTestApp(t)
+
+			runErr := app.Run([]string{"ccbm", "split", "--format", tc.format, tc.flag, tc.value, "/test/image.jpg"})
+
+			require.Error(t, runErr)
+			assert.Contains(t, runErr.Error(), tc.flag+" applies to PNG output only, not to --format "+tc.format)
+			assert.Empty(t, fs.WrittenFiles())
+		})
+	}
+}
+
+func TestApp_Run_PreviewFormatExtension(t *testing.T) {
+	app, fs, stdout := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "preview", "--format", "webp", "/test/image.jpg"})
+
+	require.NoError(t, runErr)
+	_, exists := fs.GetWrittenFile("/test/image_preview.webp")
+	assert.True(t, exists)
+	assert.Contains(t, stdout.String(), "Preview written to /test/image_preview.webp")
+}
+
+func TestApp_Run_SplitMaxBytesReportsSizes(t *testing.T) {
+	app, fs, stdout := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--max-bytes", "4096", "--colors", "16", "--compression", "best", "/test/image.jpg"})
+
+	require.NoError(t, runErr)
+	content, exists := fs.GetWrittenFile("/test/image_9.png")
+	require.True(t, exists)
+	assert.LessOrEqual(t, len(content), 4096)
+	assert.Contains(t, stdout.String(), "Tile 1: /test/image_1.png (")
+	assert.Contains(t, stdout.String(), fmt.Sprintf("Tile 9: /test/image_9.png (%d bytes)", len(content)))
+}
+
+func TestApp_Run_SplitMaxBytesTooSmall(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--max-bytes", "10", "/test/image.jpg"})
+
+	require.ErrorIs(t, runErr, processor.ErrOverBudget)
+	assert.Contains(t, runErr.Error(), "error saving tile 1")
+}
+
+func TestApp_Run_InvalidCompression(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--compression", "max", "/test/image.jpg"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), `invalid PNG compression "max"`)
+}
+
+func TestApp_Run_SplitNoClobber(t *testing.T) {
+	app, fs, _ := newTestApp(t)
+	fs.AddFile("/test/image_3.png", []byte("existing tile"))
+
+	runErr := app.Run([]string{"ccbm", "split", "--no-clobber", "/test/image.jpg"})
+
+	require.ErrorIs(t, runErr, iofs.ErrExist)
+	assert.Contains(t, runErr.Error(), "refusing to overwrite /test/image_3.png")
+	assert.Empty(t, fs.WrittenFiles())
+}
+
+func TestApp_Run_SplitForceOverridesConfiguredNoClobber(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	fs.AddFile("/test/image.jpg", []byte("fake image data"))
+	fs.AddFile("/test/image_3.png", []byte("existing tile"))
+	config := processor.DefaultConfig()
+	config.NoClobber = true
+	service, serviceErr := processor.NewServiceWithDeps(fs,
+		processor.NewTestMockImageDecoder(processor.CreateTestImage(400, 300), "jpeg", nil),
+		processor.NewTestMockImageEncoder(nil), processor.NewTestMockImageResizer(), config)
+	require.NoError(t, serviceErr)
+	app := cli.NewAppWithProcessor(service)
+	app.SetOutput(io.Discard, io.Discard)
+
+	runErr := app.Run([]string{"ccbm", "split", "--force", "/test/image.jpg"})
+
+	require.NoError(t, runErr)
+	content, _ := fs.GetWrittenFile("/test/image_3.png")
+	assert.Equal(t, "fake png data", string(content))
+}
+
+func TestApp_Run_NoClobberAndForce(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--no-clobber", "--force", "/test/image.jpg"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "--no-clobber and --force cannot be used together")
+}
+
+func TestApp_Run_SplitDryRun(t *testing.T) {
+	app, fs, stdout := newTestApp(t)
+	fs.AddFile("/test/image_4.png", []byte("existing tile"))
+
+	runErr := app.Run([]string{"ccbm", "split", "--dry-run", "--no-clobber", "/test/image.jpg"})
+
+	require.NoError(t, runErr)
+	assert.Empty(t, fs.WrittenFiles())
+	output := stdout.String()
+	assert.Contains(t, output, "Dry run, no files written for /test/image.jpg")
+	assert.Contains(t, output, "Resized:    378x378")
+	assert.Contains(t, output, "Crop:       378x378 at 0,0")
+	assert.Contains(t, output, "1 (row 1, col 1) -> /test/image_1.png (create)")
+	assert.Contains(t, output, "4 (row 2, col 1) -> /test/image_4.png (overwrite)")
+	assert.Contains(t, output, "--no-clobber: 1 of 9 tiles exist, nothing would be written")
+}
+
+func TestApp_Run_SplitBatchContinuesPastFailures(t *testing.T) {
+	app, fs, stdout := newTestApp(t)
+	fs.AddFile("/art/a.jpg", []byte("fake image data"))
+	fs.AddFile("/art/b.jpg", []byte("corrupt"))
+	fs.AddFile("/art/nested/c.png", []byte("fake image data"))
+	fs.AddFile("/art/notes.txt", []byte("not an image"))
+	service, serviceErr := processor.NewServiceWithDeps(fs, &processor.TestMockImageDecoder{
+		DecodeFunc: func(r io.Reader) (image.Image, string, error) {
+			data, _ := io.ReadAll(r)
+			if string(data) == "corrupt" {
+				return nil, "", errors.New("invalid JPEG")
+			}
+			return processor.CreateTestImage(400, 300), "jpeg", nil
+		},
+	}, processor.NewTestMockImageEncoder(nil), processor.NewTestMockImageResizer(), processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+	app = cli.NewAppWithProcessor(service)
+	app.SetOutput(stdout, io.Discard)
+
+	runErr := app.Run([]string{"ccbm", "split", "--jobs", "2", "--recursive", "/art"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "1 of 3 images failed:\n  /art/b.jpg")
+	assert.Equal(t, "/art/a.jpg: ok\n"+
+		"/art/b.jpg: failed: failed to load image: error decoding image: invalid JPEG\n"+
+		"/art/nested/c.png: ok\n"+
+		"Split 3 images: 2 succeeded, 1 failed\n", stdout.String())
+	for _, name := range []string{"/art/a_9.png", "/art/nested/c_9.png"} {
+		_, exists := fs.GetWrittenFile(name)
+		assert.True(t, exists, "expected %s to be written", name)
+	}
+}
+
+func TestApp_Run_SplitBatchRejectsCollidingOutputs(t *testing.T) {
+	app, fs, _ := newTestApp(t)
+	fs.AddFile("/one/a.jpg", []byte("fake image data"))
+	fs.AddFile("/two/a.jpg", []byte("fake image data"))
+
+	runErr := app.Run([]string{"ccbm", "split", "--out-dir", "/out", "/one/a.jpg", "/two/a.jpg"})
+
+	require.ErrorIs(t, runErr, processor.ErrOutputCollision)
+	assert.Contains(t, runErr.Error(), "/one/a.jpg and /two/a.jpg would both write /out/a_1.png")
+	assert.Empty(t, fs.WrittenFiles(), "nothing is written")
+}
+
+func TestApp_Run_SplitGlob(t *testing.T) {
+	app, fs, stdout := newTestApp(t)
+	fs.AddFile("/test/other.jpg", []byte("fake image data"))
+
+	runErr := app.Run([]string{"ccbm", "split", "/test/*.jpg"})
+
+	require.NoError(t, runErr)
+	assert.Contains(t, stdout.String(), "Split 2 images: 2 succeeded, 0 failed")
+	_, exists := fs.GetWrittenFile("/test/other_1.png")
+	assert.True(t, exists)
+}
+
+func TestApp_Run_SplitInvalidJobs(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--jobs", "0", "/test/image.jpg"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "--jobs must be at least 1")
+}
+
+func TestApp_RunContext_CancelStopsBatch(t *testing.T) {
+	app, fs, stdout := newTestApp(t)
+	fs.AddFile("/art/a.jpg", []byte("fake image data"))
+	fs.AddFile("/art/b.jpg", []byte("interrupt"))
+	fs.AddFile("/art/c.jpg", []byte("fake image data"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service, serviceErr := processor.NewServiceWithDeps(fs, &processor.TestMockImageDecoder{
+		DecodeFunc: func(r io.Reader) (image.Image, string, error) {
+			data, _ := io.ReadAll(r)
+			if string(data) == "interrupt" {
+				cancel()
+			}
+			return processor.CreateTestImage(400, 300), "jpeg", nil
+		},
+	}, processor.NewTestMockImageEncoder(nil), processor.NewTestMockImageResizer(), processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+	app = cli.NewAppWithProcessor(service)
+	app.SetOutput(stdout, io.Discard)
+
+	runErr := app.RunContext(ctx, []string{"ccbm", "split", "--jobs", "1", "/art"})
+
+	require.ErrorIs(t, runErr, context.Canceled)
+	assert.Equal(t, "/art/a.jpg: ok\n"+
+		"/art/b.jpg: failed: failed to process image: context canceled\n"+
+		"/art/c.jpg: failed: context canceled\n"+
+		"Split 3 images: 1 succeeded, 2 failed\n", stdout.String())
+	for _, name := range fs.WrittenFiles() {
+		assert.Regexp(t, `^/art/a_\d\.png$`, name, "only the tiles of a.jpg may be written")
+	}
+}
+
+func TestApp_Run_SplitTimeout(t *testing.T) {
+	app, fs, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--timeout", "1ns", "/test/image.jpg"})
+
+	require.ErrorIs(t, runErr, context.DeadlineExceeded)
+	assert.Empty(t, fs.WrittenFiles())
+}
+
+func TestApp_Run_InvalidTimeout(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--timeout", "-1s", "/test/image.jpg"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "--timeout must not be negative")
+}
+
+func TestApp_Run_InfoResampleNearestInteger(t *testing.T) {
+	app, _, stdout := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "info", "--resample", "nearest-integer", "/test/image.jpg"})
+
+	// The 400x300 image is scaled by 2 to cover the 378x378 canvas.
+	require.NoError(t, runErr)
+	assert.Contains(t, stdout.String(), "Resized:    800x600\n")
+}
+
+func TestApp_Run_InvalidResample(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	runErr := app.Run([]string{"ccbm", "split", "--resample", "sinc", "/test/image.jpg"})
+
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), `invalid resampling "sinc"`)
+}
+
+// newDecodingTestApp returns an app that decodes the image file it is given
+// with the default decoders.
+func newDecodingTestApp(t *testing.T, path string, data []byte) (*cli.App, *bytes.Buffer) {
+	t.Helper()
+
+	fs := processor.NewTestMockFileSystem()
+	fs.AddFile(path, data)
+	service, serviceErr := processor.NewServiceWithDeps(fs, processor.NewDefaultDecoderRegistry(),
+		processor.NewTestMockImageEncoder(nil), processor.NewTestMockImageResizer(), processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+	app := cli.NewAppWithProcessor(service)
+	var stdout bytes.Buffer
+	app.SetOutput(&stdout, &bytes.Buffer{})
+
+	return app, &stdout
+}
+
+func TestApp_Run_InfoColorProfile(t *testing.T) {
+	profile, readErr := os.ReadFile("../processor/testdata/icc/display-p3.icc")
+	require.NoError(t, readErr)
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, processor.CreateTestImage(400, 300)))
+	app, stdout := newDecodingTestApp(t, "/test/image.png", processor.EmbedPNGColorProfile(encoded.Bytes(), profile))
+
+	require.NoError(t, app.Run([]string{"ccbm", "info", "--linear-light", "/test/image.png"}))
+
+	assert.Contains(t, stdout.String(), "Profile:    Display P3, converted to sRGB\n")
+}
+
+func TestApp_Run_InfoEXIFOrientation(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, jpeg.Encode(&encoded, processor.CreateTestImage(400, 300), nil))
+	data := processor.EmbedEXIFOrientation(encoded.Bytes(), processor.OrientationRotate90)
+
+	app, stdout := newDecodingTestApp(t, "/test/phone.jpg", data)
+	require.NoError(t, app.Run([]string{"ccbm", "info", "/test/phone.jpg"}))
+	assert.Contains(t, stdout.String(), "Dimensions: 300x400\n")
+	assert.Contains(t, stdout.String(), "EXIF:       rotated 90° clockwise to be upright\n")
+
+	app, stdout = newDecodingTestApp(t, "/test/phone.jpg", data)
+	require.NoError(t, app.Run([]string{"ccbm", "info", "--ignore-exif", "/test/phone.jpg"}))
+	assert.Contains(t, stdout.String(), "Dimensions: 400x300\n")
+	assert.NotContains(t, stdout.String(), "EXIF:")
+}
+
+func TestApp_Run_InfoTransform(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, processor.CreateTestImage(400, 300)))
+
+	app, stdout := newDecodingTestApp(t, "/test/photo.png", encoded.Bytes())
+	require.NoError(t, app.Run([]string{
+		"ccbm", "info", "--rotate", "90", "--flip", "vertical", "--zoom", "2", "--pan", "0,0", "/test/photo.png",
+	}))
+	assert.Contains(t, stdout.String(),
+		"Transform:  flipped vertically, rotated 90°, zoomed 2x on 0,0, 150x200 at 0,0 of 300x400\n")
+
+	app, stdout = newDecodingTestApp(t, "/test/photo.png", encoded.Bytes())
+	require.NoError(t, app.Run([]string{"ccbm", "info", "/test/photo.png"}))
+	assert.NotContains(t, stdout.String(), "Transform:")
+
+	app, _ = newDecodingTestApp(t, "/test/photo.png", encoded.Bytes())
+	runErr := app.Run([]string{"ccbm", "info", "--flip", "diagonal", "/test/photo.png"})
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), `invalid flip "diagonal"`)
+
+	app, _ = newDecodingTestApp(t, "/test/photo.png", encoded.Bytes())
+	runErr = app.Run([]string{"ccbm", "info", "--zoom", "0.5", "/test/photo.png"})
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "invalid Transform.Zoom 0.5")
+}
+
+func TestApp_Run_InfoSourceRect(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, processor.CreateTestImage(400, 300)))
+
+	app, stdout := newDecodingTestApp(t, "/test/screen.png", encoded.Bytes())
+	require.NoError(t, app.Run([]string{"ccbm", "info", "--source-rect", "10,20,200,150", "/test/screen.png"}))
+	assert.Contains(t, stdout.String(), "Fit:        source rectangle resized to the canvas\n")
+	assert.Contains(t, stdout.String(), "Crop:       source rectangle 10,20,200,150\n")
+
+	app, _ = newDecodingTestApp(t, "/test/screen.png", encoded.Bytes())
+	runErr := app.Run([]string{"ccbm", "info", "--source-rect", "300,0,200,150", "/test/screen.png"})
+	require.ErrorIs(t, runErr, processor.ErrSourceRectOutOfBounds)
+
+	app, _ = newDecodingTestApp(t, "/test/screen.png", encoded.Bytes())
+	runErr = app.Run([]string{"ccbm", "info", "--source-rect", "10,20,200", "/test/screen.png"})
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), "expected x,y,w,h")
+}
+
+func TestApp_Run_InfoAdjustments(t *testing.T) {
+	devices := []byte(`{"devices": [{"name": "desk-pad", "columns": 2, "rows": 2, "tile_size": 80, "spacing": 10,
+		"adjustments": ["contrast=1.2"]}]}`)
+	device := []string{"--devices-file", "/cfg/devices.json", "--device", "desk-pad"}
+
+	testCases := []struct {
+		name     string
+		flags    []string
+		expected string
+	}{
+		{"device defaults", device, "Adjust:     contrast=1.2\n"},
+		{
+			"flags replace the defaults in order",
+			append([]string{"--adjust", "gamma=1.1,saturation=1.3", "--adjust", "hue=-10"}, device...),
+			"Adjust:     gamma=1.1,saturation=1.3,hue=-10\n",
+		},
+		{"none drops the defaults", append([]string{"--adjust", "none"}, device...), ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, fs, stdout := newTestApp(t)
+			fs.AddFile("/cfg/devices.json", devices)
+
+			require.NoError(t, app.Run(append(append([]string{"ccbm", "info"}, tc.flags...), "/test/image.jpg")))
+
+			if tc.expected == "" {
+				assert.NotContains(t, stdout.String(), "Adjust:")
+			} else {
+				assert.Contains(t, stdout.String(), tc.expected)
+			}
+		})
+	}
+
+	app, _, _ := newTestApp(t)
+	runErr := app.Run([]string{"ccbm", "info", "--adjust", "vibrance=2", "/test/image.jpg"})
+	require.Error(t, runErr)
+	assert.Contains(t, runErr.Error(), `invalid adjustment "vibrance=2"`)
+}
@@ -2,26 +2,15 @@ package processor
 
 import (
 	"image"
-	"image/png"
 	"io"
-
-	"github.com/nfnt/resize"
 )
 
-// StandardImageDecoder implements ImageDecoder using Go's standard image package.
+// StandardImageDecoder implements ImageDecoder using the default format registry.
 type StandardImageDecoder struct{}
 
 // Decode decodes an image from a reader.
 func (d *StandardImageDecoder) Decode(r io.Reader) (image.Image, string, error) {
-	return image.Decode(r)
-}
-
-// PNGEncoder implements ImageEncoder for PNG format.
-type PNGEncoder struct{}
-
-// Encode encodes an image as PNG.
-func (e *PNGEncoder) Encode(w io.Writer, img image.Image) error {
-	return png.Encode(w, img)
+	return NewDefaultDecoderRegistry().Decode(r)
 }
 
 // LanczosResizer implements ImageResizer using Lanczos3 algorithm.
@@ -29,5 +18,10 @@ type LanczosResizer struct{}
 
 // Resize resizes an image using Lanczos3 algorithm.
 func (r *LanczosResizer) Resize(width, height uint, img image.Image) image.Image {
-	return resize.Resize(width, height, img, resize.Lanczos3)
+	return (&ConvolutionResizer{Filter: LanczosFilter()}).Resize(width, height, img)
+}
+
+// ResizeLinear resizes an image using Lanczos3 algorithm in linear light.
+func (r *LanczosResizer) ResizeLinear(width, height uint, img image.Image) image.Image {
+	return (&ConvolutionResizer{Filter: LanczosFilter()}).ResizeLinear(width, height, img)
 }
@@ -0,0 +1,269 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// AdjustmentKind selects what an Adjustment changes.
+type AdjustmentKind string
+
+const (
+	// AdjustBrightness adds Amount, from -1 to 1, to every channel.
+	AdjustBrightness AdjustmentKind = "brightness"
+	// AdjustContrast scales the distance of every channel from mid-gray by
+	// Amount, which is at least 0; 1 keeps the image as it is.
+	AdjustContrast AdjustmentKind = "contrast"
+	// AdjustSaturation scales the distance of every color from the gray of
+	// the same luma by Amount, which is at least 0; 0 turns the image gray.
+	AdjustSaturation AdjustmentKind = "saturation"
+	// AdjustGamma raises every channel to the power 1/Amount, so amounts
+	// above 1 lighten the midtones and amounts below 1 darken them.
+	AdjustGamma AdjustmentKind = "gamma"
+	// AdjustHue rotates the hue of every color by Amount degrees.
+	AdjustHue AdjustmentKind = "hue"
+)
+
+// AdjustmentKinds returns the supported adjustment kinds.
+func AdjustmentKinds() []AdjustmentKind {
+	return []AdjustmentKind{AdjustBrightness, AdjustContrast, AdjustSaturation, AdjustGamma, AdjustHue}
+}
+
+// Adjustment is a color adjustment of a given kind and amount.
+type Adjustment struct {
+	Kind   AdjustmentKind
+	Amount float64
+}
+
+// ParseAdjustment parses "kind=amount", such as "contrast=1.2", ignoring the
+// case of the kind.
+func ParseAdjustment(value string) (Adjustment, error) {
+	kindValue, amountValue, found := strings.Cut(value, "=")
+	if !found {
+		return Adjustment{}, fmt.Errorf("invalid adjustment %q: expected kind=amount", value)
+	}
+
+	var adjustment Adjustment
+	kindValue = strings.TrimSpace(kindValue)
+	names := make([]string, 0, len(AdjustmentKinds()))
+	for _, kind := range AdjustmentKinds() {
+		if strings.EqualFold(kindValue, string(kind)) {
+			adjustment.Kind = kind
+		}
+		names = append(names, string(kind))
+	}
+	if adjustment.Kind == "" {
+		return Adjustment{}, fmt.Errorf("invalid adjustment %q: supported are: %s", value, strings.Join(names, ", "))
+	}
+
+	amount, amountErr := strconv.ParseFloat(strings.TrimSpace(amountValue), 64)
+	if amountErr != nil {
+		return Adjustment{}, fmt.Errorf("invalid adjustment %q: expected a number", value)
+	}
+	adjustment.Amount = amount
+	if validateErr := adjustment.validate(); validateErr != nil {
+		return Adjustment{}, fmt.Errorf("invalid adjustment %q: %w", value, validateErr)
+	}
+	return adjustment, nil
+}
+
+// String formats the adjustment the way ParseAdjustment reads it.
+func (a Adjustment) String() string {
+	return string(a.Kind) + "=" + strconv.FormatFloat(a.Amount, 'f', -1, 64)
+}
+
+// MarshalText implements encoding.TextMarshaler, so that device profiles list
+// adjustments as "kind=amount" strings.
+func (a Adjustment) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler with ParseAdjustment.
+func (a *Adjustment) UnmarshalText(text []byte) error {
+	adjustment, parseErr := ParseAdjustment(string(text))
+	if parseErr != nil {
+		return parseErr
+	}
+	*a = adjustment
+	return nil
+}
+
+func (a Adjustment) validate() error {
+	if math.IsNaN(a.Amount) || math.IsInf(a.Amount, 0) {
+		return errors.New("amount must be finite")
+	}
+	switch a.Kind {
+	case AdjustBrightness:
+		if a.Amount < -1 || a.Amount > 1 {
+			return errors.New("brightness must be between -1 and 1")
+		}
+	case AdjustContrast, AdjustSaturation:
+		if a.Amount < 0 {
+			return fmt.Errorf("%s must not be negative", a.Kind)
+		}
+	case AdjustGamma:
+		if a.Amount <= 0 {
+			return errors.New("gamma must be positive")
+		}
+	case AdjustHue:
+	default:
+		return fmt.Errorf("unknown kind %q", a.Kind)
+	}
+	return nil
+}
+
+// step returns the function that adjusts a color with straight alpha.
+func (a Adjustment) step() func(c *[3]float64) {
+	amount := a.Amount
+	switch a.Kind {
+	case AdjustBrightness:
+		return func(c *[3]float64) {
+			for ch := range c {
+				c[ch] += amount
+			}
+		}
+	case AdjustContrast:
+		return func(c *[3]float64) {
+			for ch := range c {
+				c[ch] = (c[ch]-0.5)*amount + 0.5
+			}
+		}
+	case AdjustSaturation:
+		return func(c *[3]float64) {
+			luma := lumaRed*c[0] + lumaGreen*c[1] + lumaBlue*c[2]
+			for ch := range c {
+				c[ch] = luma + (c[ch]-luma)*amount
+			}
+		}
+	case AdjustGamma:
+		return func(c *[3]float64) {
+			for ch := range c {
+				c[ch] = math.Pow(c[ch], 1/amount)
+			}
+		}
+	case AdjustHue:
+		rotation := hueRotation(amount)
+		return func(c *[3]float64) {
+			*c = transform3(rotation, *c)
+		}
+	default:
+		return func(*[3]float64) {}
+	}
+}
+
+// hueRotation returns the matrix that rotates colors by degrees around the
+// gray axis, so that positive angles turn red towards yellow and green.
+func hueRotation(degrees float64) [3][3]float64 {
+	sin, cos := math.Sincos(degrees * math.Pi / (fullTurn / 2))
+	// Rodrigues' formula for the axis (1, 1, 1) / sqrt(3).
+	diagonal := cos + (1-cos)/3
+	ahead := (1-cos)/3 - sin/math.Sqrt(3)
+	behind := (1-cos)/3 + sin/math.Sqrt(3)
+	return [3][3]float64{
+		{diagonal, ahead, behind},
+		{behind, diagonal, ahead},
+		{ahead, behind, diagonal},
+	}
+}
+
+// Adjustments is a chain of color adjustments applied in order.
+type Adjustments []Adjustment
+
+// ParseAdjustments parses a comma-separated list of adjustments, such as
+// "contrast=1.2,saturation=1.1". "none" is an empty list.
+func ParseAdjustments(value string) (Adjustments, error) {
+	if strings.EqualFold(strings.TrimSpace(value), "none") {
+		return Adjustments{}, nil
+	}
+	fields := strings.Split(value, ",")
+	adjustments := make(Adjustments, 0, len(fields))
+	for _, field := range fields {
+		adjustment, parseErr := ParseAdjustment(field)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+	return adjustments, nil
+}
+
+// String formats the adjustments the way ParseAdjustments reads them.
+func (a Adjustments) String() string {
+	if len(a) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(a))
+	for i, adjustment := range a {
+		parts[i] = adjustment.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// validate reports every invalid adjustment as a *ConfigError.
+func (a Adjustments) validate() []error {
+	var errs []error
+	for i, adjustment := range a {
+		if validateErr := adjustment.validate(); validateErr != nil {
+			errs = append(errs, &ConfigError{
+				Field:       fmt.Sprintf("Adjustments[%d]", i),
+				Value:       adjustment,
+				Requirement: validateErr.Error(),
+			})
+		}
+	}
+	return errs
+}
+
+// Apply returns the image with the adjustments applied in order to the sRGB
+// values of its colors, clamping the result of every step. Alpha is kept,
+// and so is the depth of 16-bit images. The image is returned unchanged when
+// there are no adjustments.
+func (a Adjustments) Apply(img image.Image) image.Image {
+	return a.applyWithin(img, img.Bounds())
+}
+
+// applyWithin is Apply, adjusting only the pixels within area and keeping
+// the others as they are.
+func (a Adjustments) applyWithin(img image.Image, area image.Rectangle) image.Image {
+	if len(a) == 0 {
+		return img
+	}
+	steps := make([]func(c *[3]float64), len(a))
+	for i, adjustment := range a {
+		steps[i] = adjustment.step()
+	}
+
+	source := newPixelSource(img, false)
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	area = area.Intersect(bounds).Sub(bounds.Min)
+	values := make([]float32, width*height*rgbaChannels)
+	parallelFor(height, 0, func(y int) {
+		line := values[y*width*rgbaChannels : (y+1)*width*rgbaChannels]
+		source.row(y, line)
+		if y < area.Min.Y || y >= area.Max.Y {
+			return
+		}
+		for x := area.Min.X * rgbaChannels; x < area.Max.X*rgbaChannels; x += rgbaChannels {
+			alpha := float64(line[x+3])
+			if alpha == 0 {
+				continue
+			}
+			c := [3]float64{float64(line[x]) / alpha, float64(line[x+1]) / alpha, float64(line[x+2]) / alpha}
+			for _, step := range steps {
+				step(&c)
+				for ch := range c {
+					c[ch] = min(1, max(0, c[ch]))
+				}
+			}
+			for ch, value := range c {
+				line[x+ch] = float32(value * alpha)
+			}
+		}
+	})
+	return storePixels(values, width, height, source.deep, false, 0)
+}
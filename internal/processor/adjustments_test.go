@@ -0,0 +1,194 @@
+package processor_test
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestParseAdjustments(t *testing.T) {
+	adjustments, parseErr := processor.ParseAdjustments("Contrast=1.2, gamma=0.8,hue=-30")
+
+	require.NoError(t, parseErr)
+	assert.Equal(t, processor.Adjustments{
+		{Kind: processor.AdjustContrast, Amount: 1.2},
+		{Kind: processor.AdjustGamma, Amount: 0.8},
+		{Kind: processor.AdjustHue, Amount: -30},
+	}, adjustments)
+	assert.Equal(t, "contrast=1.2,gamma=0.8,hue=-30", adjustments.String())
+
+	none, parseErr := processor.ParseAdjustments("none")
+	require.NoError(t, parseErr)
+	assert.Empty(t, none)
+	assert.Equal(t, "none", none.String())
+}
+
+func TestParseAdjustments_Invalid(t *testing.T) {
+	testCases := []struct {
+		input   string
+		message string
+	}{
+		{"contrast", "expected kind=amount"},
+		{"sharpness=2", "supported are: brightness, contrast, saturation, gamma, hue"},
+		{"gamma=bright", "expected a number"},
+		{"brightness=1.5", "brightness must be between -1 and 1"},
+		{"contrast=1,saturation=-1", "saturation must not be negative"},
+		{"gamma=0", "gamma must be positive"},
+		{"hue=Inf", "amount must be finite"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			_, parseErr := processor.ParseAdjustments(tc.input)
+
+			require.Error(t, parseErr)
+			assert.Contains(t, parseErr.Error(), "invalid adjustment")
+			assert.Contains(t, parseErr.Error(), tc.message)
+		})
+	}
+}
+
+func TestAdjustments_Apply(t *testing.T) {
+	testCases := []struct {
+		adjustments string
+		input       color.NRGBA
+		expected    color.NRGBA
+	}{
+		{"brightness=0.2", color.NRGBA{R: 100, G: 200, B: 250, A: 255}, color.NRGBA{R: 151, G: 251, B: 255, A: 255}},
+		{"contrast=2", color.NRGBA{R: 100, G: 128, B: 200, A: 255}, color.NRGBA{R: 73, G: 129, B: 255, A: 255}},
+		{"saturation=0", color.NRGBA{R: 255, A: 255}, color.NRGBA{R: 76, G: 76, B: 76, A: 255}},
+		{"gamma=2", color.NRGBA{R: 64, G: 0, B: 255, A: 255}, color.NRGBA{R: 128, G: 0, B: 255, A: 255}},
+		{"hue=120", color.NRGBA{R: 255, A: 255}, color.NRGBA{G: 255, A: 255}},
+		{"hue=-120", color.NRGBA{R: 255, A: 255}, color.NRGBA{B: 255, A: 255}},
+		{"brightness=0.5", color.NRGBA{R: 100, G: 100, B: 100, A: 128}, color.NRGBA{R: 228, G: 228, B: 228, A: 128}},
+		// The steps are applied in order and clamped in between.
+		{"brightness=1,brightness=-0.5", color.NRGBA{R: 200, A: 255}, color.NRGBA{R: 128, G: 128, B: 128, A: 255}},
+		{"brightness=-0.5,brightness=1", color.NRGBA{R: 200, A: 255}, color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.adjustments, func(t *testing.T) {
+			adjustments, parseErr := processor.ParseAdjustments(tc.adjustments)
+			require.NoError(t, parseErr)
+
+			adjusted := adjustments.Apply(processor.CreateColoredTestImage(2, 2, tc.input))
+
+			c := color.NRGBAModel.Convert(adjusted.At(1, 1)).(color.NRGBA)
+			assert.InDelta(t, tc.expected.R, c.R, 1)
+			assert.InDelta(t, tc.expected.G, c.G, 1)
+			assert.InDelta(t, tc.expected.B, c.B, 1)
+			assert.Equal(t, tc.expected.A, c.A)
+		})
+	}
+}
+
+func TestAdjustments_Apply_KeepsImage(t *testing.T) {
+	source := processor.CreateTestImage(4, 4)
+	assert.Same(t, source, processor.Adjustments(nil).Apply(source), "no adjustments leave the image as it is")
+
+	deep := image.NewNRGBA64(image.Rect(2, 2, 6, 6))
+	adjusted := processor.Adjustments{{Kind: processor.AdjustContrast, Amount: 1.5}}.Apply(deep)
+	assert.IsType(t, &image.RGBA64{}, adjusted)
+	assert.Equal(t, image.Rect(0, 0, 4, 4), adjusted.Bounds())
+}
+
+func TestConfig_Validate_Adjustments(t *testing.T) {
+	config := processor.DefaultConfig()
+	config.Adjustments = processor.Adjustments{
+		{Kind: processor.AdjustContrast, Amount: 1.1},
+		{Kind: "blur", Amount: 2},
+		{Kind: processor.AdjustGamma, Amount: -1},
+	}
+
+	validateErr := config.Validate()
+
+	require.Error(t, validateErr)
+	assert.Contains(t, validateErr.Error(), `invalid Adjustments[1] blur=2: unknown kind "blur"`)
+	assert.Contains(t, validateErr.Error(), "invalid Adjustments[2] gamma=-1: gamma must be positive")
+	assert.NotContains(t, validateErr.Error(), "Adjustments[0]")
+}
+
+func TestService_ProcessImageData_Adjustments(t *testing.T) {
+	config := processor.Config{GridSize: 1, TileSize: 20, Adjustments: processor.Adjustments{
+		{Kind: processor.AdjustSaturation, Amount: 0},
+		{Kind: processor.AdjustBrightness, Amount: 0.1},
+	}}
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, &processor.LanczosResizer{}, config)
+	require.NoError(t, serviceErr)
+
+	procImg, processErr := service.ProcessImageData(context.Background(), &processor.ProcessedImage{
+		Original: processor.CreateColoredTestImage(40, 40, color.RGBA{B: 255, A: 255}),
+	})
+
+	require.NoError(t, processErr)
+	assertSameColor(t, color.RGBA{B: 255, A: 255}, procImg.Resized.At(5, 5), "the resized image is not adjusted")
+	for _, img := range []image.Image{procImg.Squared, procImg.Result.Tiles[0]} {
+		c := color.RGBAModel.Convert(img.At(5, 5)).(color.RGBA)
+		assert.InDelta(t, 55, c.R, 1)
+		assert.Equal(t, c.R, c.G)
+		assert.Equal(t, c.R, c.B)
+	}
+}
+
+func TestService_ProcessImageData_AdjustmentsKeepFill(t *testing.T) {
+	fill := color.NRGBA{R: 20, G: 40, B: 60, A: 255}
+	config := processor.Config{
+		GridSize: 1, TileSize: 20, FitMode: processor.FitContain, Fill: fill,
+		Adjustments: processor.Adjustments{{Kind: processor.AdjustBrightness, Amount: 0.5}},
+	}
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, &processor.LanczosResizer{}, config)
+	require.NoError(t, serviceErr)
+
+	procImg, processErr := service.ProcessImageData(context.Background(), &processor.ProcessedImage{
+		Original: processor.CreateColoredTestImage(40, 20, color.RGBA{B: 128, A: 255}),
+	})
+
+	require.NoError(t, processErr)
+	// The 20x10 image sits between 5 rows of padding above and below.
+	for _, img := range []image.Image{procImg.Squared, procImg.Result.Tiles[0]} {
+		assertSameColor(t, fill, img.At(10, 2), "the padding is not adjusted")
+		assertSameColor(t, fill, img.At(0, 19), "the padding is not adjusted")
+		c := color.RGBAModel.Convert(img.At(10, 10)).(color.RGBA)
+		assert.InDelta(t, 128, c.R, 1, "the image is adjusted")
+		assert.InDelta(t, 255, c.B, 1, "the image is adjusted")
+	}
+}
+
+func TestDeviceProfile_Adjustments(t *testing.T) {
+	mockFS := processor.NewTestMockFileSystem()
+	mockFS.AddFile("/cfg/devices.json", []byte(`{"devices": [
+		{"name": "desk-pad", "columns": 2, "rows": 2, "tile_size": 80, "spacing": 10,
+		 "adjustments": ["contrast=1.2", "saturation=1.1"]}
+	]}`))
+	service, serviceErr := processor.NewServiceWithDeps(mockFS, nil, nil, nil, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+
+	profiles, loadErr := service.LoadDeviceProfiles("/cfg/devices.json")
+	require.NoError(t, loadErr)
+	require.Len(t, profiles, 1)
+	config, configErr := profiles[0].Config()
+	require.NoError(t, configErr)
+
+	expected := processor.Adjustments{
+		{Kind: processor.AdjustContrast, Amount: 1.2},
+		{Kind: processor.AdjustSaturation, Amount: 1.1},
+	}
+	assert.Equal(t, expected, config.Adjustments)
+	encoded, marshalErr := json.Marshal(profiles[0].Adjustments)
+	require.NoError(t, marshalErr)
+	assert.JSONEq(t, `["contrast=1.2", "saturation=1.1"]`, string(encoded))
+
+	mockFS.AddFile("/cfg/bad.json", []byte(`{"devices": [
+		{"name": "x", "columns": 1, "rows": 1, "tile_size": 80, "adjustments": ["gamma=0"]}
+	]}`))
+	_, loadErr = service.LoadDeviceProfiles("/cfg/bad.json")
+	require.Error(t, loadErr)
+	assert.Contains(t, loadErr.Error(), "gamma must be positive")
+}
@@ -0,0 +1,50 @@
+package processor
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+const (
+	shortHexLen     = 3
+	rgbHexLen       = 6
+	rgbaHexLen      = 8
+	shortHexExpand  = 17
+	opaqueAlpha     = 255
+	hexChannelWidth = 2
+)
+
+// ParseHexColor parses "#rgb", "#rrggbb", "#rrggbbaa" or "transparent" into a color.
+func ParseHexColor(value string) (color.NRGBA, error) {
+	if strings.EqualFold(value, "transparent") {
+		return color.NRGBA{}, nil
+	}
+
+	hex := strings.TrimPrefix(value, "#")
+	switch len(hex) {
+	case shortHexLen:
+		var channels [3]uint8
+		for i := range channels {
+			v, parseErr := strconv.ParseUint(hex[i:i+1], 16, 8)
+			if parseErr != nil {
+				return color.NRGBA{}, fmt.Errorf("invalid color %q: %w", value, parseErr)
+			}
+			channels[i] = uint8(v * shortHexExpand) // #nosec G115
+		}
+		return color.NRGBA{R: channels[0], G: channels[1], B: channels[2], A: opaqueAlpha}, nil
+	case rgbHexLen, rgbaHexLen:
+		channels := [4]uint8{3: opaqueAlpha}
+		for i := range len(hex) / hexChannelWidth {
+			v, parseErr := strconv.ParseUint(hex[i*hexChannelWidth:(i+1)*hexChannelWidth], 16, 8)
+			if parseErr != nil {
+				return color.NRGBA{}, fmt.Errorf("invalid color %q: %w", value, parseErr)
+			}
+			channels[i] = uint8(v) // #nosec G115
+		}
+		return color.NRGBA{R: channels[0], G: channels[1], B: channels[2], A: channels[3]}, nil
+	default:
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: expected #rgb, #rrggbb, #rrggbbaa or transparent", value)
+	}
+}
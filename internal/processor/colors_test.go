@@ -0,0 +1,45 @@
+package processor_test
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestParseHexColor(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected color.NRGBA
+	}{
+		{"#000000", color.NRGBA{A: 255}},
+		{"#ff8000", color.NRGBA{R: 255, G: 128, A: 255}},
+		{"FF8000", color.NRGBA{R: 255, G: 128, A: 255}},
+		{"#f80", color.NRGBA{R: 255, G: 136, A: 255}},
+		{"#11223344", color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0x44}},
+		{"transparent", color.NRGBA{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			parsed, parseErr := processor.ParseHexColor(tc.input)
+
+			require.NoError(t, parseErr)
+			assert.Equal(t, tc.expected, parsed)
+		})
+	}
+}
+
+func TestParseHexColor_Invalid(t *testing.T) {
+	for _, input := range []string{"", "#12", "#gggggg", "red", "#1234567"} {
+		t.Run(input, func(t *testing.T) {
+			_, parseErr := processor.ParseHexColor(input)
+
+			require.Error(t, parseErr)
+			assert.Contains(t, parseErr.Error(), "invalid color")
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+)
+
+// AutoTargetSize makes the service derive TargetSize from the grid geometry.
+const AutoTargetSize = 0
+
+// SpacingMode selects whether the spacing between keys is part of the image.
+type SpacingMode string
+
+const (
+	// SpacingPhysical lays the image out over the keys and the gaps between
+	// them, so the gap pixels are discarded and the image looks continuous
+	// across keys.
+	SpacingPhysical SpacingMode = "physical"
+	// SpacingContiguous makes the tiles abut so every pixel of the image is
+	// used, which suits icon sheets.
+	SpacingContiguous SpacingMode = "contiguous"
+)
+
+// ParseSpacingMode returns the spacing mode with the given name, ignoring case.
+func ParseSpacingMode(value string) (SpacingMode, error) {
+	for _, mode := range []SpacingMode{SpacingPhysical, SpacingContiguous} {
+		if strings.EqualFold(value, string(mode)) {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("invalid spacing mode %q, supported are: %s, %s", value, SpacingPhysical, SpacingContiguous)
+}
+
+// ConfigError reports a Config field whose value breaks a required relationship.
+type ConfigError struct {
+	Field       string
+	Value       any
+	Requirement string
+}
+
+// Error implements error.
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid %s %v: %s", e.Field, e.Value, e.Requirement)
+}
+
+// GridColumns returns the number of key columns, Columns or else GridSize.
+func (c Config) GridColumns() int {
+	if c.Columns != 0 {
+		return c.Columns
+	}
+	return c.GridSize
+}
+
+// GridRows returns the number of key rows, Rows or else GridSize.
+func (c Config) GridRows() int {
+	if c.Rows != 0 {
+		return c.Rows
+	}
+	return c.GridSize
+}
+
+// SpacingX returns the gap between columns in the image, HorizontalSpacing or
+// else Spacing, or 0 with SpacingContiguous.
+func (c Config) SpacingX() int {
+	if c.SpacingMode == SpacingContiguous {
+		return 0
+	}
+	if c.HorizontalSpacing != 0 {
+		return c.HorizontalSpacing
+	}
+	return c.Spacing
+}
+
+// SpacingY returns the gap between rows in the image, VerticalSpacing or else
+// Spacing, or 0 with SpacingContiguous.
+func (c Config) SpacingY() int {
+	if c.SpacingMode == SpacingContiguous {
+		return 0
+	}
+	if c.VerticalSpacing != 0 {
+		return c.VerticalSpacing
+	}
+	return c.Spacing
+}
+
+// LayoutSize returns the area covered by the keys and the spacing between them.
+func (c Config) LayoutSize() image.Point {
+	return image.Point{
+		X: c.GridColumns()*c.TileSize + (c.GridColumns()-1)*c.SpacingX(),
+		Y: c.GridRows()*c.TileSize + (c.GridRows()-1)*c.SpacingY(),
+	}
+}
+
+// RequiredTargetSize returns the smallest TargetSize that fits the whole grid,
+// the longer side of LayoutSize.
+func (c Config) RequiredTargetSize() int {
+	size := c.LayoutSize()
+	return max(size.X, size.Y)
+}
+
+// CanvasSize returns the dimensions of the canvas the image is fitted to:
+// TargetSize along the longer side of the layout, the other side scaled to
+// keep the layout aspect ratio.
+func (c Config) CanvasSize() image.Point {
+	target := c.Normalized().TargetSize
+	layout := c.LayoutSize()
+	if layout.X <= 0 || layout.Y <= 0 {
+		return image.Point{X: target, Y: target}
+	}
+
+	if layout.X >= layout.Y {
+		return image.Point{X: target, Y: target * layout.Y / layout.X}
+	}
+	return image.Point{X: target * layout.X / layout.Y, Y: target}
+}
+
+// Normalized returns a copy of the configuration where TargetSize is replaced
+// by RequiredTargetSize when it is AutoTargetSize or the spacing is
+// SpacingContiguous: abutting tiles cover exactly the layout, so a larger
+// canvas would leave its right and bottom edges out of every tile.
+func (c Config) Normalized() Config {
+	if c.TargetSize == AutoTargetSize || c.SpacingMode == SpacingContiguous {
+		c.TargetSize = c.RequiredTargetSize()
+	}
+	return c
+}
+
+// Validate checks that the configuration describes a grid that fits in the
+// target canvas. Every violation is reported as a *ConfigError.
+func (c Config) Validate() error {
+	var errs []error
+
+	if (c.Columns == 0 || c.Rows == 0) && c.GridSize < 1 {
+		errs = append(errs, &ConfigError{
+			Field:       "GridSize",
+			Value:       c.GridSize,
+			Requirement: "must be at least 1 unless Columns and Rows are set",
+		})
+	}
+	if c.TileSize < 1 {
+		errs = append(errs, &ConfigError{Field: "TileSize", Value: c.TileSize, Requirement: "must be at least 1"})
+	}
+	for _, field := range []struct {
+		name  string
+		value int
+	}{
+		{"Columns", c.Columns},
+		{"Rows", c.Rows},
+		{"Spacing", c.Spacing},
+		{"HorizontalSpacing", c.HorizontalSpacing},
+		{"VerticalSpacing", c.VerticalSpacing},
+	} {
+		if field.value < 0 {
+			errs = append(errs, &ConfigError{Field: field.name, Value: field.value, Requirement: "must not be negative"})
+		}
+	}
+	if c.EncodeJobs < 0 {
+		errs = append(errs, &ConfigError{
+			Field:       "EncodeJobs",
+			Value:       c.EncodeJobs,
+			Requirement: "must not be negative (use 0 for one per CPU)",
+		})
+	}
+	if c.TargetSize < 0 {
+		errs = append(errs, &ConfigError{
+			Field:       "TargetSize",
+			Value:       c.TargetSize,
+			Requirement: "must not be negative (use 0 to derive it from the grid)",
+		})
+	}
+
+	errs = append(errs, c.validateModes()...)
+	errs = append(errs, c.validateSource()...)
+	errs = append(errs, c.Transform.validate()...)
+	errs = append(errs, c.Adjustments.validate()...)
+
+	if c.FileNameTemplate != "" {
+		if templateErr := validateFileNameTemplate(c.FileNameTemplate); templateErr != nil {
+			errs = append(errs, &ConfigError{
+				Field:       "FileNameTemplate",
+				Value:       fmt.Sprintf("%q", c.FileNameTemplate),
+				Requirement: templateErr.Error(),
+			})
+		}
+	}
+
+	if len(errs) == 0 && c.TargetSize != AutoTargetSize && c.TargetSize < c.RequiredTargetSize() {
+		errs = append(errs, &ConfigError{Field: "TargetSize", Value: c.TargetSize, Requirement: c.targetSizeRequirement()})
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateModes reports every invalid mode of the fit and the crop, and an
+// invalid FocalPoint, as a *ConfigError.
+func (c Config) validateModes() []error {
+	var errs []error
+	if c.FitMode != "" && !c.FitMode.valid() {
+		errs = append(errs, &ConfigError{
+			Field:       "FitMode",
+			Value:       fmt.Sprintf("%q", c.FitMode),
+			Requirement: "must be one of cover, contain, stretch",
+		})
+	}
+	if c.SpacingMode != "" && c.SpacingMode != SpacingPhysical && c.SpacingMode != SpacingContiguous {
+		errs = append(errs, &ConfigError{
+			Field:       "SpacingMode",
+			Value:       fmt.Sprintf("%q", c.SpacingMode),
+			Requirement: "must be physical or contiguous",
+		})
+	}
+	if c.CropMode != "" && c.CropMode != CropGravity && c.CropMode != CropSmart {
+		errs = append(errs, &ConfigError{
+			Field:       "CropMode",
+			Value:       fmt.Sprintf("%q", c.CropMode),
+			Requirement: "must be gravity or smart",
+		})
+	}
+	if c.Gravity != "" && !c.Gravity.valid() {
+		errs = append(errs, &ConfigError{
+			Field:       "Gravity",
+			Value:       fmt.Sprintf("%q", c.Gravity),
+			Requirement: "must be a compass direction or center",
+		})
+	}
+	if c.FocalPoint != nil {
+		if focalErr := c.FocalPoint.validate(); focalErr != nil {
+			errs = append(errs, &ConfigError{Field: "FocalPoint", Value: c.FocalPoint, Requirement: focalErr.Error()})
+		}
+	}
+	return errs
+}
+
+// validateSource reports an invalid SourceRect, and the settings it
+// excludes, as a *ConfigError.
+func (c Config) validateSource() []error {
+	if c.SourceRect == nil {
+		return nil
+	}
+	var errs []error
+	if rectErr := validateSourceRect(*c.SourceRect); rectErr != nil {
+		errs = append(errs, &ConfigError{Field: "SourceRect", Value: *c.SourceRect, Requirement: rectErr.Error()})
+	}
+	if c.OptimizeGutters {
+		errs = append(errs, &ConfigError{
+			Field:       "OptimizeGutters",
+			Value:       c.OptimizeGutters,
+			Requirement: "must be false with SourceRect, which fixes the crop",
+		})
+	}
+	return errs
+}
+
+func (c Config) targetSizeRequirement() string {
+	columns, rows := c.GridColumns(), c.GridRows()
+	if c.SpacingMode == SpacingContiguous {
+		return fmt.Sprintf("must be at least max(Columns, Rows)*TileSize = %d*%d = %d with contiguous spacing",
+			max(columns, rows), c.TileSize, c.RequiredTargetSize())
+	}
+	if columns == rows && c.SpacingX() == c.SpacingY() {
+		return fmt.Sprintf("must be at least GridSize*TileSize + (GridSize-1)*Spacing = %d*%d + %d*%d = %d",
+			columns, c.TileSize, columns-1, c.SpacingX(), c.RequiredTargetSize())
+	}
+
+	layout := c.LayoutSize()
+	return fmt.Sprintf("must be at least the longer side of the grid, "+
+		"max(Columns*TileSize + (Columns-1)*HorizontalSpacing, Rows*TileSize + (Rows-1)*VerticalSpacing) "+
+		"= max(%d, %d) = %d", layout.X, layout.Y, c.RequiredTargetSize())
+}
@@ -0,0 +1,252 @@
+package processor_test
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestConfig_Validate_Default(t *testing.T) {
+	require.NoError(t, processor.DefaultConfig().Validate())
+}
+
+func TestConfig_Validate_Invalid(t *testing.T) {
+	testCases := []struct {
+		name        string
+		config      processor.Config
+		field       string
+		requirement string
+	}{
+		{
+			name:        "zero grid",
+			config:      processor.Config{TargetSize: 378, GridSize: 0, TileSize: 116, Spacing: 15},
+			field:       "GridSize",
+			requirement: "must be at least 1",
+		},
+		{
+			name:        "zero tile size",
+			config:      processor.Config{TargetSize: 378, GridSize: 3, TileSize: 0, Spacing: 15},
+			field:       "TileSize",
+			requirement: "must be at least 1",
+		},
+		{
+			name:        "negative spacing",
+			config:      processor.Config{TargetSize: 378, GridSize: 3, TileSize: 116, Spacing: -1},
+			field:       "Spacing",
+			requirement: "must not be negative",
+		},
+		{
+			name:        "negative target size",
+			config:      processor.Config{TargetSize: -5, GridSize: 3, TileSize: 116, Spacing: 15},
+			field:       "TargetSize",
+			requirement: "must not be negative",
+		},
+		{
+			name:        "negative encode jobs",
+			config:      processor.Config{TargetSize: 378, GridSize: 3, TileSize: 116, Spacing: 15, EncodeJobs: -1},
+			field:       "EncodeJobs",
+			requirement: "must not be negative",
+		},
+		{
+			name:        "target size too small",
+			config:      processor.Config{TargetSize: 377, GridSize: 3, TileSize: 116, Spacing: 15},
+			field:       "TargetSize",
+			requirement: "GridSize*TileSize + (GridSize-1)*Spacing = 3*116 + 2*15 = 378",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			validateErr := tc.config.Validate()
+
+			var configErr *processor.ConfigError
+			require.ErrorAs(t, validateErr, &configErr)
+			assert.Equal(t, tc.field, configErr.Field)
+			assert.Contains(t, configErr.Requirement, tc.requirement)
+			assert.Contains(t, validateErr.Error(), "invalid "+tc.field)
+		})
+	}
+}
+
+func TestConfig_Validate_ReportsEveryField(t *testing.T) {
+	config := processor.Config{TargetSize: 100, GridSize: 0, TileSize: 0, Spacing: -2}
+
+	validateErr := config.Validate()
+
+	require.Error(t, validateErr)
+	fields := map[string]bool{}
+	var joined interface{ Unwrap() []error }
+	require.ErrorAs(t, validateErr, &joined)
+	for _, err := range joined.Unwrap() {
+		var configErr *processor.ConfigError
+		if errors.As(err, &configErr) {
+			fields[configErr.Field] = true
+		}
+	}
+	assert.Equal(t, map[string]bool{"GridSize": true, "TileSize": true, "Spacing": true}, fields)
+}
+
+func TestConfig_AutoTargetSize(t *testing.T) {
+	config := processor.Config{TargetSize: processor.AutoTargetSize, GridSize: 2, TileSize: 90, Spacing: 10}
+
+	require.NoError(t, config.Validate())
+	assert.Equal(t, 190, config.RequiredTargetSize())
+	assert.Equal(t, 190, config.Normalized().TargetSize)
+}
+
+func TestConfig_Normalized_KeepsExplicitTargetSize(t *testing.T) {
+	config := processor.Config{TargetSize: 250, GridSize: 2, TileSize: 90, Spacing: 10}
+
+	assert.Equal(t, config, config.Normalized())
+}
+
+func TestNewServiceWithDeps_InvalidConfig(t *testing.T) {
+	config := processor.DefaultConfig()
+	config.TargetSize = 300
+
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, nil, config)
+
+	require.Error(t, serviceErr)
+	assert.Nil(t, service)
+	assert.Contains(t, serviceErr.Error(), "invalid configuration")
+}
+
+func TestNewServiceWithDeps_DerivesTargetSize(t *testing.T) {
+	config := processor.Config{TargetSize: processor.AutoTargetSize, GridSize: 2, TileSize: 90, Spacing: 10}
+
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, nil, config)
+
+	require.NoError(t, serviceErr)
+	assert.Equal(t, 190, service.Config().TargetSize)
+}
+
+func TestService_WithConfig_Invalid(t *testing.T) {
+	service := processor.NewService()
+	config := processor.DefaultConfig()
+	config.GridSize = 0
+
+	configured, configErr := service.WithConfig(config)
+
+	var fieldErr *processor.ConfigError
+	require.ErrorAs(t, configErr, &fieldErr)
+	assert.Equal(t, "GridSize", fieldErr.Field)
+	assert.Nil(t, configured)
+}
+
+func TestConfig_GridFallbacks(t *testing.T) {
+	config := processor.Config{GridSize: 3, Columns: 5, Spacing: 15, VerticalSpacing: 8}
+
+	assert.Equal(t, 5, config.GridColumns())
+	assert.Equal(t, 3, config.GridRows())
+	assert.Equal(t, 15, config.SpacingX())
+	assert.Equal(t, 8, config.SpacingY())
+}
+
+func TestConfig_CanvasSize(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   processor.Config
+		expected image.Point
+	}{
+		{"default", processor.DefaultConfig(), image.Pt(378, 378)},
+		{"derived wide", processor.Config{Columns: 5, Rows: 3, TileSize: 72, Spacing: 24}, image.Pt(456, 264)},
+		{"scaled wide", processor.Config{TargetSize: 912, Columns: 5, Rows: 3, TileSize: 72, Spacing: 24}, image.Pt(912, 528)},
+		{"derived tall", processor.Config{Columns: 2, Rows: 4, TileSize: 50, Spacing: 10}, image.Pt(110, 230)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.config.CanvasSize())
+		})
+	}
+}
+
+func TestConfig_Validate_RectangularTargetSize(t *testing.T) {
+	config := processor.Config{TargetSize: 378, Columns: 5, Rows: 3, TileSize: 72, Spacing: 24}
+
+	validateErr := config.Validate()
+
+	var configErr *processor.ConfigError
+	require.ErrorAs(t, validateErr, &configErr)
+	assert.Equal(t, "TargetSize", configErr.Field)
+	assert.Contains(t, configErr.Requirement, "= max(456, 264) = 456")
+}
+
+func TestConfig_Validate_NegativeColumns(t *testing.T) {
+	config := processor.Config{GridSize: 3, Columns: -1, Rows: 3, TileSize: 10}
+
+	var configErr *processor.ConfigError
+	require.ErrorAs(t, config.Validate(), &configErr)
+	assert.Equal(t, "Columns", configErr.Field)
+}
+
+func TestConfig_Validate_ColumnsAndRowsWithoutGridSize(t *testing.T) {
+	config := processor.Config{Columns: 5, Rows: 3, TileSize: 72, Spacing: 24}
+
+	require.NoError(t, config.Validate())
+}
+
+func TestConfig_SpacingContiguous(t *testing.T) {
+	config := processor.DefaultConfig()
+	config.SpacingMode = processor.SpacingContiguous
+	config.TargetSize = processor.AutoTargetSize
+
+	assert.Equal(t, 0, config.SpacingX())
+	assert.Equal(t, 0, config.SpacingY())
+	assert.Equal(t, image.Pt(348, 348), config.LayoutSize())
+	assert.Equal(t, 348, config.Normalized().TargetSize)
+}
+
+func TestConfig_SpacingContiguous_TilesReachEdges(t *testing.T) {
+	// Setup - the default 378px target was sized for physical spacing; the
+	// image has a green band along its right and bottom edges
+	config := processor.DefaultConfig()
+	config.SpacingMode = processor.SpacingContiguous
+	original := image.NewRGBA(image.Rect(0, 0, 696, 696))
+	draw.Draw(original, original.Bounds(), image.NewUniform(color.RGBA{G: 255, A: 255}), image.Point{}, draw.Src)
+	draw.Draw(original, image.Rect(0, 0, 676, 676), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, &processor.LanczosResizer{}, config)
+	require.NoError(t, serviceErr)
+
+	// Execute
+	result, processErr := service.ProcessImageData(context.Background(), &processor.ProcessedImage{Original: original})
+	require.NoError(t, processErr)
+
+	// Assert - the canvas is the layout and the last tile ends on the edges
+	assert.Equal(t, image.Rect(0, 0, 348, 348), result.Squared.Bounds())
+	last := result.Result.Tiles[len(result.Result.Tiles)-1]
+	assertSameColor(t, color.RGBA{G: 255, A: 255}, last.At(115, 60), "right edge")
+	assertSameColor(t, color.RGBA{G: 255, A: 255}, last.At(60, 115), "bottom edge")
+}
+
+func TestConfig_Validate_SpacingMode(t *testing.T) {
+	config := processor.Config{TargetSize: 300, GridSize: 3, TileSize: 116, SpacingMode: processor.SpacingContiguous}
+
+	var configErr *processor.ConfigError
+	require.ErrorAs(t, config.Validate(), &configErr)
+	assert.Equal(t, "must be at least max(Columns, Rows)*TileSize = 3*116 = 348 with contiguous spacing",
+		configErr.Requirement)
+
+	config = processor.DefaultConfig()
+	config.SpacingMode = "loose"
+	require.ErrorAs(t, config.Validate(), &configErr)
+	assert.Equal(t, "SpacingMode", configErr.Field)
+}
+
+func TestParseSpacingMode(t *testing.T) {
+	mode, parseErr := processor.ParseSpacingMode("Contiguous")
+	require.NoError(t, parseErr)
+	assert.Equal(t, processor.SpacingContiguous, mode)
+
+	_, parseErr = processor.ParseSpacingMode("none")
+	require.Error(t, parseErr)
+	assert.Contains(t, parseErr.Error(), "supported are: physical, contiguous")
+}
@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"context"
+	"image"
+	"io"
+)
+
+// contextReader fails reads once its context is done, so that a decoder
+// reading from it gives up on the next read.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if ctxErr := c.ctx.Err(); ctxErr != nil {
+		return 0, ctxErr
+	}
+	return c.r.Read(p)
+}
+
+// contextWriter fails writes once its context is done, so that an encoder
+// writing to it gives up on the next write.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (c *contextWriter) Write(p []byte) (int, error) {
+	if ctxErr := c.ctx.Err(); ctxErr != nil {
+		return 0, ctxErr
+	}
+	return c.w.Write(p)
+}
+
+// contextResizer skips the work of its resizer once its context is done and
+// returns a blank image of the requested size instead, so that the remaining
+// resizes of a cancelled ProcessImageData, such as those of the gutter
+// optimizer, return immediately. The result is discarded by the caller.
+type contextResizer struct {
+	ctx     context.Context
+	resizer ImageResizer
+}
+
+func (c *contextResizer) Resize(width, height uint, img image.Image) image.Image {
+	if c.ctx.Err() == nil {
+		return c.resizer.Resize(width, height, img)
+	}
+	return c.blank(width, height, img)
+}
+
+// ResizeInside forwards to the ResizeInside of the resizer when it
+// implements InsideResizer, and to its Resize otherwise.
+func (c *contextResizer) ResizeInside(width, height uint, img image.Image) image.Image {
+	if c.ctx.Err() != nil {
+		return c.blank(width, height, img)
+	}
+	if inside, ok := c.resizer.(InsideResizer); ok {
+		return inside.ResizeInside(width, height, img)
+	}
+	return c.resizer.Resize(width, height, img)
+}
+
+// blank returns the blank image that stands for img resized to width x height.
+func (c *contextResizer) blank(width, height uint, img image.Image) image.Image {
+	bounds := img.Bounds()
+	w, h := uint(bounds.Dx()), uint(bounds.Dy()) // #nosec G115
+	switch {
+	case width == 0 && height == 0:
+		width, height = w, h
+	case width == 0 && h > 0:
+		width = max(1, (height*w+h/half)/h)
+	case height == 0 && w > 0:
+		height = max(1, (width*h+w/half)/w)
+	}
+	return image.NewRGBA(image.Rect(0, 0, int(width), int(height))) // #nosec G115
+}
@@ -0,0 +1,99 @@
+package processor_test
+
+import (
+	"context"
+	"image"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestService_LoadImage_CancelledWhileDecoding(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	fs.AddFile("/test/image.jpg", []byte("fake jpeg data"))
+	ctx, cancel := context.WithCancel(context.Background())
+	decoder := processor.NewTestMockImageDecoder(nil, "", nil)
+	decoder.DecodeFunc = func(r io.Reader) (image.Image, string, error) {
+		cancel()
+		_, readErr := io.ReadAll(r)
+		return nil, "", readErr
+	}
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, processor.NewTestMockImageEncoder(nil),
+		processor.NewTestMockImageResizer(), processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+
+	procImg, loadErr := service.LoadImage(ctx, "/test/image.jpg")
+
+	require.ErrorIs(t, loadErr, context.Canceled)
+	assert.Nil(t, procImg)
+}
+
+func TestService_ProcessImageData_CancelledSkipsRemainingResizes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	resizes := 0
+	scaler := processor.NewTestMockImageResizer()
+	resizer := processor.NewTestMockImageResizer()
+	resizer.ResizeFunc = func(width, height uint, img image.Image) image.Image {
+		resizes++
+		cancel()
+		return scaler.Resize(width, height, img)
+	}
+	config := processor.DefaultConfig()
+	config.OptimizeGutters = true
+	service, serviceErr := processor.NewServiceWithDeps(processor.NewTestMockFileSystem(),
+		processor.NewTestMockImageDecoder(nil, "", nil), processor.NewTestMockImageEncoder(nil), resizer, config)
+	require.NoError(t, serviceErr)
+
+	procImg, processErr := service.ProcessImageData(ctx,
+		&processor.ProcessedImage{Original: processor.CreateTestImage(400, 300)})
+
+	require.ErrorIs(t, processErr, context.Canceled)
+	assert.Nil(t, procImg)
+	assert.Equal(t, 1, resizes, "the gutter optimizer must not resize once cancelled")
+}
+
+func TestService_SaveTiles_CancelledRemovesPartialOutput(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	ctx, cancel := context.WithCancel(context.Background())
+	encoder := processor.NewTestMockImageEncoder(nil)
+	config := processor.DefaultConfig()
+	config.EncodeJobs = 1
+	service, procImg := newSaveTestService(t, fs, encoder, config)
+
+	// Interrupt the encoding of the third tile halfway through.
+	encoded := 0
+	encoder.EncodeFunc = func(w io.Writer, _ image.Image) error {
+		encoded++
+		if _, writeErr := w.Write([]byte("first half")); writeErr != nil {
+			return writeErr
+		}
+		if encoded == 3 {
+			cancel()
+		}
+		_, writeErr := w.Write([]byte("second half"))
+		return writeErr
+	}
+	saveErr := service.SaveTiles(ctx, procImg, "/test/image.jpg")
+
+	require.ErrorIs(t, saveErr, context.Canceled)
+	assert.Equal(t, 3, encoded, "no tile may be encoded once cancelled")
+	assert.Empty(t, fs.WrittenFiles(), "temporary files must be removed")
+	assert.Empty(t, procImg.Saved)
+}
+
+func TestService_ProcessImage_CancelledContext(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	fs.AddFile("/test/image.jpg", []byte("fake jpeg data"))
+	service, _ := newSaveTestService(t, fs, processor.NewTestMockImageEncoder(nil), processor.DefaultConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	processErr := service.ProcessImage(ctx, "/test/image.jpg")
+
+	require.ErrorIs(t, processErr, context.Canceled)
+	assert.Empty(t, fs.WrittenFiles())
+}
@@ -0,0 +1,314 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"strconv"
+	"strings"
+)
+
+const (
+	percentScale = 100
+	// anchorEnd is the Gravity anchor of a window pushed to the far edge.
+	anchorEnd = 2
+	// sourceRectFields is the number of values of a source rectangle: x, y, w and h.
+	sourceRectFields = 4
+)
+
+// ErrSourceRectOutOfBounds is returned when Config.SourceRect does not lie
+// within the decoded image.
+var ErrSourceRectOutOfBounds = errors.New("source rectangle outside the image")
+
+// CropMode selects how the crop window is chosen.
+type CropMode string
+
+const (
+	// CropGravity places the crop window using Config.Gravity or Config.FocalPoint.
+	CropGravity CropMode = "gravity"
+	// CropSmart places the crop window on the most detailed part of the image.
+	CropSmart CropMode = "smart"
+)
+
+// ParseCropMode returns the crop mode with the given name, ignoring case.
+func ParseCropMode(value string) (CropMode, error) {
+	for _, mode := range []CropMode{CropGravity, CropSmart} {
+		if strings.EqualFold(value, string(mode)) {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("invalid crop mode %q, supported are: %s, %s", value, CropGravity, CropSmart)
+}
+
+// Gravity selects the part of the image that is kept when it is cropped.
+type Gravity string
+
+// Supported gravities, named after the edge or corner the crop is pushed to.
+const (
+	GravityCenter    Gravity = "center"
+	GravityNorth     Gravity = "north"
+	GravitySouth     Gravity = "south"
+	GravityEast      Gravity = "east"
+	GravityWest      Gravity = "west"
+	GravityNorthEast Gravity = "northeast"
+	GravityNorthWest Gravity = "northwest"
+	GravitySouthEast Gravity = "southeast"
+	GravitySouthWest Gravity = "southwest"
+)
+
+// Gravities returns the supported gravities.
+func Gravities() []Gravity {
+	return []Gravity{
+		GravityCenter, GravityNorth, GravitySouth, GravityEast, GravityWest,
+		GravityNorthEast, GravityNorthWest, GravitySouthEast, GravitySouthWest,
+	}
+}
+
+// ParseGravity returns the gravity with the given name, ignoring case and
+// dashes, so "north-west" and "NorthWest" are both GravityNorthWest.
+func ParseGravity(value string) (Gravity, error) {
+	normalized := strings.ToLower(strings.ReplaceAll(value, "-", ""))
+	names := make([]string, 0, len(Gravities()))
+	for _, gravity := range Gravities() {
+		if normalized == string(gravity) {
+			return gravity, nil
+		}
+		names = append(names, string(gravity))
+	}
+	return "", fmt.Errorf("invalid gravity %q, supported are: %s", value, strings.Join(names, ", "))
+}
+
+func (g Gravity) valid() bool {
+	for _, gravity := range Gravities() {
+		if g == gravity {
+			return true
+		}
+	}
+	return false
+}
+
+// anchor returns where the crop window sits along each axis:
+// 0 at the start, 1 in the middle and 2 at the end.
+func (g Gravity) anchor() image.Point {
+	anchor := image.Point{X: 1, Y: 1}
+	name := string(g)
+	switch {
+	case strings.HasPrefix(name, "north"):
+		anchor.Y = 0
+	case strings.HasPrefix(name, "south"):
+		anchor.Y = anchorEnd
+	}
+	switch {
+	case strings.HasSuffix(name, "west"):
+		anchor.X = 0
+	case strings.HasSuffix(name, "east"):
+		anchor.X = anchorEnd
+	}
+	return anchor
+}
+
+// FocalPoint is the point of the original image a crop is centered on,
+// in pixels or, when Percent is set, in percent of the image dimensions.
+type FocalPoint struct {
+	X       float64
+	Y       float64
+	Percent bool
+}
+
+// ParseFocalPoint parses "x,y" in pixels, such as "1200,800", or in percent,
+// such as "50%,30%".
+func ParseFocalPoint(value string) (FocalPoint, error) {
+	xValue, yValue, found := strings.Cut(value, ",")
+	if !found {
+		return FocalPoint{}, fmt.Errorf("invalid focal point %q: expected x,y", value)
+	}
+	xValue, yValue = strings.TrimSpace(xValue), strings.TrimSpace(yValue)
+
+	xPercent, yPercent := strings.HasSuffix(xValue, "%"), strings.HasSuffix(yValue, "%")
+	if xPercent != yPercent {
+		return FocalPoint{}, fmt.Errorf("invalid focal point %q: x and y must both be pixels or percentages", value)
+	}
+
+	x, xErr := strconv.ParseFloat(strings.TrimSuffix(xValue, "%"), 64)
+	y, yErr := strconv.ParseFloat(strings.TrimSuffix(yValue, "%"), 64)
+	if xErr != nil || yErr != nil {
+		return FocalPoint{}, fmt.Errorf("invalid focal point %q: expected numbers", value)
+	}
+
+	point := FocalPoint{X: x, Y: y, Percent: xPercent}
+	if validateErr := point.validate(); validateErr != nil {
+		return FocalPoint{}, fmt.Errorf("invalid focal point %q: %w", value, validateErr)
+	}
+	return point, nil
+}
+
+// String formats the focal point the way ParseFocalPoint reads it.
+func (p FocalPoint) String() string {
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	if p.Percent {
+		return format(p.X) + "%," + format(p.Y) + "%"
+	}
+	return format(p.X) + "," + format(p.Y)
+}
+
+// In returns the focal point in the pixel coordinates of bounds.
+func (p FocalPoint) In(bounds image.Rectangle) image.Point {
+	x, y := p.X, p.Y
+	if p.Percent {
+		x = x * float64(bounds.Dx()) / percentScale
+		y = y * float64(bounds.Dy()) / percentScale
+	}
+	return image.Point{X: bounds.Min.X + int(x), Y: bounds.Min.Y + int(y)}
+}
+
+func (p FocalPoint) validate() error {
+	switch {
+	case p.X < 0 || p.Y < 0:
+		return errors.New("coordinates must not be negative")
+	case p.Percent && (p.X > percentScale || p.Y > percentScale):
+		return fmt.Errorf("percentages must not exceed %d", percentScale)
+	}
+	return nil
+}
+
+// ParseSourceRect parses "x,y,w,h" in pixels, such as "100,50,800,600", into
+// the rectangle of that size whose top-left corner is x,y.
+func ParseSourceRect(value string) (image.Rectangle, error) {
+	fields := strings.Split(value, ",")
+	if len(fields) != sourceRectFields {
+		return image.Rectangle{}, fmt.Errorf("invalid source rectangle %q: expected x,y,w,h", value)
+	}
+	numbers := make([]int, len(fields))
+	for i, field := range fields {
+		number, numberErr := strconv.Atoi(strings.TrimSpace(field))
+		if numberErr != nil {
+			return image.Rectangle{}, fmt.Errorf("invalid source rectangle %q: expected whole numbers", value)
+		}
+		numbers[i] = number
+	}
+
+	// image.Rect would swap the corners of a negative size.
+	x, y, width, height := numbers[0], numbers[1], numbers[2], numbers[3]
+	rect := image.Rectangle{Min: image.Pt(x, y), Max: image.Pt(x+width, y+height)}
+	if validateErr := validateSourceRect(rect); validateErr != nil {
+		return image.Rectangle{}, fmt.Errorf("invalid source rectangle %q: %w", value, validateErr)
+	}
+	return rect, nil
+}
+
+func validateSourceRect(rect image.Rectangle) error {
+	switch {
+	case rect.Min.X < 0 || rect.Min.Y < 0:
+		return errors.New("coordinates must not be negative")
+	case rect.Empty():
+		return errors.New("width and height must be at least 1")
+	}
+	return nil
+}
+
+// CropSourceRect crops rect, relative to the top-left corner of the image,
+// out of an image. It fails with ErrSourceRectOutOfBounds when rect does not
+// lie within the image.
+func CropSourceRect(img image.Image, rect image.Rectangle) (image.Image, error) {
+	bounds := img.Bounds()
+	if !rect.Add(bounds.Min).In(bounds) {
+		return nil, fmt.Errorf("%w: %d,%d,%d,%d is not within %dx%d", ErrSourceRectOutOfBounds,
+			rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy(), bounds.Dx(), bounds.Dy())
+	}
+	return CropAt(img, rect.Dx(), rect.Dy(), rect.Min.Add(bounds.Min)), nil
+}
+
+// CropStrategy chooses where a width x height window is cropped out of an image.
+type CropStrategy interface {
+	CropOrigin(img image.Image, width, height int) image.Point
+}
+
+// CropWith crops a width x height window chosen by strategy out of an image.
+func CropWith(img image.Image, width, height int, strategy CropStrategy) image.Image {
+	return CropAt(img, width, height, strategy.CropOrigin(img, width, height))
+}
+
+// GravityCropper places the crop window according to a Gravity.
+type GravityCropper struct {
+	Gravity Gravity
+}
+
+// CropOrigin implements CropStrategy.
+func (c GravityCropper) CropOrigin(img image.Image, width, height int) image.Point {
+	return GravityOrigin(img.Bounds(), width, height, c.Gravity)
+}
+
+// FocalCropper centers the crop window on a FocalPoint of the original image,
+// which the cropped image is a scaled version of.
+type FocalCropper struct {
+	Point    FocalPoint
+	Original image.Rectangle
+}
+
+// CropOrigin implements CropStrategy.
+func (c FocalCropper) CropOrigin(img image.Image, width, height int) image.Point {
+	bounds := img.Bounds()
+	if c.Original.Empty() {
+		return GravityOrigin(bounds, width, height, GravityCenter)
+	}
+
+	focus := c.Point.In(c.Original).Sub(c.Original.Min)
+	scaled := image.Point{
+		X: bounds.Min.X + focus.X*bounds.Dx()/c.Original.Dx(),
+		Y: bounds.Min.Y + focus.Y*bounds.Dy()/c.Original.Dy(),
+	}
+	return FocalOrigin(bounds, width, height, scaled)
+}
+
+// GravityOrigin returns the top-left corner of a width x height crop window
+// placed inside bounds according to gravity.
+func GravityOrigin(bounds image.Rectangle, width, height int, gravity Gravity) image.Point {
+	anchor := gravity.anchor()
+	return image.Point{
+		X: bounds.Min.X + (bounds.Dx()-width)*anchor.X/centerDivisor,
+		Y: bounds.Min.Y + (bounds.Dy()-height)*anchor.Y/centerDivisor,
+	}
+}
+
+// FocalOrigin returns the top-left corner of a width x height crop window
+// centered on focus, shifted as needed to stay inside bounds.
+func FocalOrigin(bounds image.Rectangle, width, height int, focus image.Point) image.Point {
+	return clampOrigin(bounds, width, height, focus.Sub(image.Point{X: width / centerDivisor, Y: height / centerDivisor}))
+}
+
+// clampOrigin shifts the top-left corner of a width x height window so the
+// window stays inside bounds where possible.
+func clampOrigin(bounds image.Rectangle, width, height int, origin image.Point) image.Point {
+	clamp := func(value, low, high int) int {
+		return max(low, min(value, high))
+	}
+	return image.Point{
+		X: clamp(origin.X, bounds.Min.X, bounds.Max.X-width),
+		Y: clamp(origin.Y, bounds.Min.Y, bounds.Max.Y-height),
+	}
+}
+
+// CropAt crops a width x height window whose top-left corner is origin.
+func CropAt(img image.Image, width, height int, origin image.Point) image.Image {
+	cropped := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(cropped, cropped.Bounds(), img, origin, draw.Src)
+	return cropped
+}
+
+// Cropper returns the crop strategy selected by the configuration for a
+// scaled version of an image with the original bounds: a SmartCropper for
+// CropSmart, otherwise a FocalCropper when FocalPoint is set and a
+// GravityCropper when it is not.
+func (c Config) Cropper(original image.Rectangle) CropStrategy {
+	switch {
+	case c.CropMode == CropSmart:
+		return NewSmartCropper()
+	case c.FocalPoint != nil:
+		return FocalCropper{Point: *c.FocalPoint, Original: original}
+	case c.Gravity == "":
+		return GravityCropper{Gravity: GravityCenter}
+	default:
+		return GravityCropper{Gravity: c.Gravity}
+	}
+}
@@ -0,0 +1,304 @@
+package processor_test
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestParseGravity(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected processor.Gravity
+	}{
+		{"center", processor.GravityCenter},
+		{"North", processor.GravityNorth},
+		{"north-west", processor.GravityNorthWest},
+		{"SouthEast", processor.GravitySouthEast},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			gravity, parseErr := processor.ParseGravity(tc.input)
+
+			require.NoError(t, parseErr)
+			assert.Equal(t, tc.expected, gravity)
+		})
+	}
+
+	_, parseErr := processor.ParseGravity("up")
+	require.Error(t, parseErr)
+	assert.Contains(t, parseErr.Error(), `invalid gravity "up"`)
+}
+
+func TestGravityOrigin(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 200)
+
+	testCases := []struct {
+		gravity  processor.Gravity
+		expected image.Point
+	}{
+		{processor.GravityCenter, image.Pt(50, 50)},
+		{processor.GravityNorth, image.Pt(50, 0)},
+		{processor.GravitySouth, image.Pt(50, 100)},
+		{processor.GravityEast, image.Pt(100, 50)},
+		{processor.GravityWest, image.Pt(0, 50)},
+		{processor.GravityNorthEast, image.Pt(100, 0)},
+		{processor.GravityNorthWest, image.Pt(0, 0)},
+		{processor.GravitySouthEast, image.Pt(100, 100)},
+		{processor.GravitySouthWest, image.Pt(0, 100)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.gravity), func(t *testing.T) {
+			assert.Equal(t, tc.expected, processor.GravityOrigin(bounds, 200, 100, tc.gravity))
+		})
+	}
+}
+
+func TestParseFocalPoint(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected processor.FocalPoint
+	}{
+		{"1200,800", processor.FocalPoint{X: 1200, Y: 800}},
+		{"50%,30%", processor.FocalPoint{X: 50, Y: 30, Percent: true}},
+		{" 12.5% , 100% ", processor.FocalPoint{X: 12.5, Y: 100, Percent: true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			point, parseErr := processor.ParseFocalPoint(tc.input)
+
+			require.NoError(t, parseErr)
+			assert.Equal(t, tc.expected, point)
+		})
+	}
+}
+
+func TestParseFocalPoint_Invalid(t *testing.T) {
+	for _, input := range []string{"", "50%", "50%,30", "a,b", "-1,5", "150%,20%"} {
+		t.Run(input, func(t *testing.T) {
+			_, parseErr := processor.ParseFocalPoint(input)
+
+			require.Error(t, parseErr)
+			assert.Contains(t, parseErr.Error(), "invalid focal point")
+		})
+	}
+}
+
+func TestFocalPoint_In(t *testing.T) {
+	bounds := image.Rect(10, 20, 210, 120)
+
+	assert.Equal(t, image.Pt(110, 50), processor.FocalPoint{X: 50, Y: 30, Percent: true}.In(bounds))
+	assert.Equal(t, image.Pt(15, 25), processor.FocalPoint{X: 5, Y: 5}.In(bounds))
+	assert.Equal(t, "50%,30%", processor.FocalPoint{X: 50, Y: 30, Percent: true}.String())
+}
+
+func TestFocalOrigin_StaysInsideBounds(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 200)
+
+	assert.Equal(t, image.Pt(100, 50), processor.FocalOrigin(bounds, 100, 100, image.Pt(150, 100)))
+	assert.Equal(t, image.Pt(0, 0), processor.FocalOrigin(bounds, 100, 100, image.Pt(10, 10)))
+	assert.Equal(t, image.Pt(200, 100), processor.FocalOrigin(bounds, 100, 100, image.Pt(299, 199)))
+}
+
+func TestConfig_Cropper(t *testing.T) {
+	original := image.Rect(0, 0, 2000, 1000)
+	resized := image.NewRGBA(image.Rect(0, 0, 400, 200))
+
+	testCases := []struct {
+		name     string
+		config   processor.Config
+		expected image.Point
+	}{
+		{"default center", processor.Config{}, image.Pt(100, 0)},
+		{"gravity west", processor.Config{Gravity: processor.GravityWest}, image.Pt(0, 0)},
+		{"focal pixels", processor.Config{FocalPoint: &processor.FocalPoint{X: 1500, Y: 500}}, image.Pt(200, 0)},
+		{
+			"focal wins over gravity",
+			processor.Config{Gravity: processor.GravityWest, FocalPoint: &processor.FocalPoint{X: 60, Y: 50, Percent: true}},
+			image.Pt(140, 0),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.config.Cropper(original).CropOrigin(resized, 200, 200))
+		})
+	}
+}
+
+func TestService_ProcessImageData_Gravity(t *testing.T) {
+	// Setup - a portrait whose top half is green and bottom half red
+	original := image.NewRGBA(image.Rect(0, 0, 100, 400))
+	for y := range 400 {
+		for x := range 100 {
+			c := color.RGBA{R: 255, A: 255}
+			if y < 200 {
+				c = color.RGBA{G: 255, A: 255}
+			}
+			original.Set(x, y, c)
+		}
+	}
+	config := processor.Config{GridSize: 1, TileSize: 100, Gravity: processor.GravityNorth}
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, &processor.LanczosResizer{}, config)
+	require.NoError(t, serviceErr)
+
+	// Execute
+	result, processErr := service.ProcessImageData(context.Background(), &processor.ProcessedImage{Original: original})
+	require.NoError(t, processErr)
+
+	// Assert - the top of the portrait is kept
+	assertSameColor(t, color.RGBA{G: 255, A: 255}, result.Squared.At(50, 50))
+}
+
+func TestConfig_Validate_Crop(t *testing.T) {
+	config := processor.DefaultConfig()
+	config.Gravity = "up"
+	config.FocalPoint = &processor.FocalPoint{X: 120, Y: 10, Percent: true}
+
+	validateErr := config.Validate()
+
+	require.Error(t, validateErr)
+	assert.Contains(t, validateErr.Error(), `invalid Gravity "up"`)
+	assert.Contains(t, validateErr.Error(), "invalid FocalPoint 120%,10%: percentages must not exceed 100")
+}
+
+func TestParseSourceRect(t *testing.T) {
+	rect, parseErr := processor.ParseSourceRect(" 100, 50,800,600")
+	require.NoError(t, parseErr)
+	assert.Equal(t, image.Rect(100, 50, 900, 650), rect)
+
+	for _, input := range []string{
+		"", "1,2,3", "1,2,3,4,5", "a,0,10,10", "1.5,0,10,10", "-1,0,10,10", "0,0,0,10", "5,5,-2,10",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, parseErr := processor.ParseSourceRect(input)
+
+			require.Error(t, parseErr)
+			assert.Contains(t, parseErr.Error(), "invalid source rectangle")
+		})
+	}
+}
+
+func TestCropSourceRect(t *testing.T) {
+	source := createIndexedImage(40, 30)
+
+	cropped, cropErr := processor.CropSourceRect(source.SubImage(image.Rect(5, 5, 40, 30)), image.Rect(10, 5, 30, 25))
+	require.NoError(t, cropErr)
+	assert.Equal(t, image.Rect(0, 0, 20, 20), cropped.Bounds())
+	assertSameColor(t, source.At(15, 10), cropped.At(0, 0), "the rectangle is relative to the top-left corner")
+
+	_, cropErr = processor.CropSourceRect(source, image.Rect(30, 0, 50, 10))
+	require.ErrorIs(t, cropErr, processor.ErrSourceRectOutOfBounds)
+	assert.Contains(t, cropErr.Error(), "30,0,20,10 is not within 40x30")
+}
+
+func TestService_ProcessImageData_SourceRect(t *testing.T) {
+	// Setup - a landscape whose left quarter is green and the rest red, which
+	// a center crop would discard
+	original := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	for y := range 100 {
+		for x := range 400 {
+			c := color.RGBA{R: 255, A: 255}
+			if x < 100 {
+				c = color.RGBA{G: 255, A: 255}
+			}
+			original.Set(x, y, c)
+		}
+	}
+	config := processor.Config{GridSize: 1, TileSize: 50, SourceRect: &image.Rectangle{Max: image.Pt(80, 40)}}
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, &processor.LanczosResizer{}, config)
+	require.NoError(t, serviceErr)
+
+	// Execute
+	result, processErr := service.ProcessImageData(context.Background(), &processor.ProcessedImage{Original: original})
+	require.NoError(t, processErr)
+
+	// Assert - the rectangle fills the canvas exactly
+	assert.Equal(t, image.Rect(0, 0, 80, 40), result.Transformed.Bounds())
+	assert.Equal(t, image.Rect(0, 0, 50, 50), result.Squared.Bounds())
+	assert.Equal(t, result.Squared.Bounds(), result.Crop)
+	assertSameColor(t, color.RGBA{G: 255, A: 255}, result.Squared.At(45, 45))
+
+	// A rectangle beyond the image is rejected
+	config.SourceRect = &image.Rectangle{Min: image.Pt(350, 0), Max: image.Pt(450, 100)}
+	service, serviceErr = service.WithConfig(config)
+	require.NoError(t, serviceErr)
+	_, processErr = service.ProcessImageData(context.Background(), &processor.ProcessedImage{Original: original})
+	require.ErrorIs(t, processErr, processor.ErrSourceRectOutOfBounds)
+}
+
+func TestService_ProcessImageData_SourceRectStretches(t *testing.T) {
+	// Setup - a 2:1 rectangle whose left half is green and right half red,
+	// with a blue band along the top, for a square canvas
+	original := image.NewRGBA(image.Rect(0, 0, 80, 40))
+	for y := range 40 {
+		for x := range 80 {
+			c := color.RGBA{R: 255, A: 255}
+			switch {
+			case y < 10:
+				c = color.RGBA{B: 255, A: 255}
+			case x < 40:
+				c = color.RGBA{G: 255, A: 255}
+			}
+			original.Set(x, y, c)
+		}
+	}
+	config := processor.Config{GridSize: 1, TileSize: 50, SourceRect: &image.Rectangle{Max: image.Pt(80, 40)}}
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, &processor.LanczosResizer{}, config)
+	require.NoError(t, serviceErr)
+
+	// Execute
+	result, processErr := service.ProcessImageData(context.Background(), &processor.ProcessedImage{Original: original})
+	require.NoError(t, processErr)
+
+	// Assert - the width is squeezed and the height stretched, nothing is
+	// cropped or padded
+	assert.Equal(t, image.Rect(0, 0, 50, 50), result.Resized.Bounds())
+	assert.Equal(t, result.Squared.Bounds(), result.Crop)
+	assertSameColor(t, color.RGBA{B: 255, A: 255}, result.Squared.At(25, 3), "the top band is kept, 12px high")
+	assertSameColor(t, color.RGBA{B: 255, A: 255}, result.Squared.At(25, 9))
+	assertSameColor(t, color.RGBA{G: 255, A: 255}, result.Squared.At(3, 40), "the left half is 25px wide")
+	assertSameColor(t, color.RGBA{G: 255, A: 255}, result.Squared.At(21, 40))
+	assertSameColor(t, color.RGBA{R: 255, A: 255}, result.Squared.At(28, 40))
+	assertSameColor(t, color.RGBA{R: 255, A: 255}, result.Squared.At(46, 40))
+}
+
+func TestService_ProcessImageData_SourceRectIntegerScale(t *testing.T) {
+	config := processor.DefaultConfig()
+	config.SourceRect = &image.Rectangle{Min: image.Pt(25, 25), Max: image.Pt(75, 75)}
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, &processor.IntegerScaleResizer{}, config)
+	require.NoError(t, serviceErr)
+
+	result, processErr := service.ProcessImageData(context.Background(),
+		&processor.ProcessedImage{Original: processor.CreateTestImage(100, 100)})
+
+	require.NoError(t, processErr)
+	// 8x would give 400 pixels and lose the right and bottom of the area; 7x
+	// fits and is padded to the canvas.
+	assert.Equal(t, image.Rect(0, 0, 350, 350), result.Resized.Bounds())
+	assert.Equal(t, image.Rect(0, 0, 378, 378), result.Squared.Bounds())
+	assert.True(t, result.Resized.Bounds().In(result.Crop), "the whole area is on the canvas")
+	assertSameColor(t, config.Fill, result.Squared.At(5, 5))
+	assertSameColor(t, color.RGBA{R: 255, A: 255}, result.Squared.At(360, 360))
+}
+
+func TestConfig_Validate_SourceRect(t *testing.T) {
+	config := processor.DefaultConfig()
+	config.SourceRect = &image.Rectangle{Min: image.Pt(-5, 0), Max: image.Pt(10, 10)}
+	config.OptimizeGutters = true
+
+	validateErr := config.Validate()
+
+	require.Error(t, validateErr)
+	assert.Contains(t, validateErr.Error(), "invalid SourceRect (-5,0)-(10,10): coordinates must not be negative")
+	assert.Contains(t, validateErr.Error(), "invalid OptimizeGutters true: must be false with SourceRect")
+}
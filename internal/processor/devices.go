@@ -0,0 +1,196 @@
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultDeviceName is the name of the profile DefaultConfig is built from.
+const DefaultDeviceName = "mx-creative-console"
+
+const (
+	mxConsoleKeys     = 3
+	mxConsoleTileSize = 116
+	mxConsoleSpacing  = 15
+)
+
+// ErrUnknownDevice is returned when a device profile name is not in the catalog.
+var ErrUnknownDevice = errors.New("unknown device")
+
+// DeviceProfile describes the key geometry of a grid-style LCD keypad.
+// HorizontalSpacing and VerticalSpacing override Spacing when non-zero.
+// Adjustments are the color adjustments that suit its screens by default.
+type DeviceProfile struct {
+	Name              string      `json:"name"`
+	Description       string      `json:"description,omitempty"`
+	Columns           int         `json:"columns"`
+	Rows              int         `json:"rows"`
+	TileSize          int         `json:"tile_size"`
+	Spacing           int         `json:"spacing"`
+	HorizontalSpacing int         `json:"horizontal_spacing,omitempty"`
+	VerticalSpacing   int         `json:"vertical_spacing,omitempty"`
+	Adjustments       Adjustments `json:"adjustments,omitempty"`
+}
+
+// DefaultDeviceProfile returns the profile of the Logitech MX Creative Console keypad.
+func DefaultDeviceProfile() DeviceProfile {
+	return DeviceProfile{
+		Name:        DefaultDeviceName,
+		Description: "Logitech MX Creative Console keypad",
+		Columns:     mxConsoleKeys,
+		Rows:        mxConsoleKeys,
+		TileSize:    mxConsoleTileSize,
+		Spacing:     mxConsoleSpacing,
+	}
+}
+
+// BuiltinDeviceProfiles returns the profiles compiled into the binary. Other
+// keypads are described in a devices file, see Service.LoadDeviceProfiles.
+func BuiltinDeviceProfiles() []DeviceProfile {
+	return []DeviceProfile{DefaultDeviceProfile()}
+}
+
+// Width returns the width in pixels covered by the keys and the spacing between them.
+func (p DeviceProfile) Width() int {
+	return p.layout().LayoutSize().X
+}
+
+// Height returns the height in pixels covered by the keys and the spacing between them.
+func (p DeviceProfile) Height() int {
+	return p.layout().LayoutSize().Y
+}
+
+// Config returns the processing configuration matching the profile geometry.
+// Square grids with uniform spacing use GridSize and Spacing only.
+func (p DeviceProfile) Config() (Config, error) {
+	config := p.layout()
+	if config.Columns == config.Rows && config.SpacingX() == config.SpacingY() {
+		config = Config{GridSize: p.Columns, TileSize: p.TileSize, Spacing: config.SpacingX()}
+	}
+	config.TargetSize = config.RequiredTargetSize()
+	config.Device = p.Name
+	config.Adjustments = p.Adjustments
+
+	if validateErr := config.Validate(); validateErr != nil {
+		return Config{}, fmt.Errorf("device %q: %w", p.Name, validateErr)
+	}
+	return config, nil
+}
+
+func (p DeviceProfile) layout() Config {
+	return Config{
+		Columns:           p.Columns,
+		Rows:              p.Rows,
+		TileSize:          p.TileSize,
+		Spacing:           p.Spacing,
+		HorizontalSpacing: p.HorizontalSpacing,
+		VerticalSpacing:   p.VerticalSpacing,
+	}
+}
+
+func (p DeviceProfile) validate() error {
+	switch {
+	case strings.TrimSpace(p.Name) == "":
+		return errors.New("device profile has no name")
+	case p.Columns <= 0 || p.Rows <= 0:
+		return fmt.Errorf("device %q: columns and rows must be positive", p.Name)
+	case p.TileSize <= 0:
+		return fmt.Errorf("device %q: tile_size must be positive", p.Name)
+	case p.Spacing < 0 || p.HorizontalSpacing < 0 || p.VerticalSpacing < 0:
+		return fmt.Errorf("device %q: spacing must not be negative", p.Name)
+	}
+	for _, adjustment := range p.Adjustments {
+		if adjustmentErr := adjustment.validate(); adjustmentErr != nil {
+			return fmt.Errorf("device %q: adjustment %s: %w", p.Name, adjustment, adjustmentErr)
+		}
+	}
+	return nil
+}
+
+// DeviceCatalog is a named collection of device profiles.
+type DeviceCatalog struct {
+	profiles []DeviceProfile
+}
+
+// NewDeviceCatalog creates a catalog containing the given profiles.
+func NewDeviceCatalog(profiles ...DeviceProfile) (*DeviceCatalog, error) {
+	catalog := &DeviceCatalog{}
+	for _, profile := range profiles {
+		if addErr := catalog.Add(profile); addErr != nil {
+			return nil, addErr
+		}
+	}
+	return catalog, nil
+}
+
+// NewDefaultDeviceCatalog creates a catalog containing BuiltinDeviceProfiles.
+func NewDefaultDeviceCatalog() *DeviceCatalog {
+	return &DeviceCatalog{profiles: BuiltinDeviceProfiles()}
+}
+
+// Add adds a profile to the catalog, replacing any profile with the same name.
+func (c *DeviceCatalog) Add(profile DeviceProfile) error {
+	if validateErr := profile.validate(); validateErr != nil {
+		return validateErr
+	}
+
+	for i, existing := range c.profiles {
+		if strings.EqualFold(existing.Name, profile.Name) {
+			c.profiles[i] = profile
+			return nil
+		}
+	}
+	c.profiles = append(c.profiles, profile)
+	return nil
+}
+
+// Lookup returns the profile with the given name, ignoring case.
+func (c *DeviceCatalog) Lookup(name string) (DeviceProfile, error) {
+	names := make([]string, 0, len(c.profiles))
+	for _, profile := range c.profiles {
+		if strings.EqualFold(profile.Name, name) {
+			return profile, nil
+		}
+		names = append(names, profile.Name)
+	}
+	return DeviceProfile{}, fmt.Errorf("%w %q, available are: %s", ErrUnknownDevice, name, strings.Join(names, ", "))
+}
+
+// Profiles returns the profiles in the catalog in the order they were added.
+func (c *DeviceCatalog) Profiles() []DeviceProfile {
+	return append([]DeviceProfile(nil), c.profiles...)
+}
+
+// deviceFile is the on-disk format of user-defined device profiles.
+type deviceFile struct {
+	Devices []DeviceProfile `json:"devices"`
+}
+
+// LoadDeviceProfiles reads user-defined device profiles from a JSON file of the form
+// {"devices": [{"name": "...", "columns": 3, "rows": 3, "tile_size": 116, "spacing": 15}]},
+// optionally with "adjustments": ["contrast=1.2", "saturation=1.1"].
+func (s *Service) LoadDeviceProfiles(path string) ([]DeviceProfile, error) {
+	file, openErr := s.fileSystem.Open(path)
+	if openErr != nil {
+		return nil, fmt.Errorf("error opening device profiles: %w", openErr)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+
+	var parsed deviceFile
+	if decodeErr := decoder.Decode(&parsed); decodeErr != nil {
+		return nil, fmt.Errorf("error parsing device profiles %s: %w", path, decodeErr)
+	}
+
+	for _, profile := range parsed.Devices {
+		if validateErr := profile.validate(); validateErr != nil {
+			return nil, fmt.Errorf("invalid device profile in %s: %w", path, validateErr)
+		}
+	}
+
+	return parsed.Devices, nil
+}
@@ -0,0 +1,171 @@
+package processor_test
+
+import (
+	"image"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestDefaultDeviceProfile_MatchesDefaultConfig(t *testing.T) {
+	profile := processor.DefaultDeviceProfile()
+
+	config, configErr := profile.Config()
+
+	require.NoError(t, configErr)
+	assert.Equal(t, processor.DefaultConfig(), config)
+	assert.Equal(t, processor.DefaultDeviceName, profile.Name)
+	assert.Equal(t, 378, profile.Width())
+	assert.Equal(t, 378, profile.Height())
+}
+
+func TestBuiltinDeviceProfiles(t *testing.T) {
+	profiles := processor.BuiltinDeviceProfiles()
+
+	assert.Equal(t, []processor.DeviceProfile{processor.DefaultDeviceProfile()}, profiles)
+}
+
+func TestDeviceProfile_Config_NonSquare(t *testing.T) {
+	profile := processor.DeviceProfile{Name: "wide", Columns: 5, Rows: 3, TileSize: 72, Spacing: 24}
+
+	config, configErr := profile.Config()
+
+	require.NoError(t, configErr)
+	assert.Equal(t, processor.Config{TargetSize: 456, Columns: 5, Rows: 3, TileSize: 72, Spacing: 24, Device: "wide"}, config)
+	assert.Equal(t, image.Pt(456, 264), config.CanvasSize())
+}
+
+func TestDeviceProfile_Config_SeparateSpacing(t *testing.T) {
+	profile := processor.DeviceProfile{
+		Name: "gutters", Columns: 3, Rows: 3, TileSize: 100, HorizontalSpacing: 10, VerticalSpacing: 20,
+	}
+
+	config, configErr := profile.Config()
+
+	require.NoError(t, configErr)
+	assert.Equal(t, 10, config.SpacingX())
+	assert.Equal(t, 20, config.SpacingY())
+	assert.Equal(t, 320, profile.Width())
+	assert.Equal(t, 340, profile.Height())
+	assert.Equal(t, image.Pt(320, 340), config.CanvasSize())
+}
+
+func TestDeviceProfile_Config_CustomSquare(t *testing.T) {
+	profile := processor.DeviceProfile{Name: "small", Columns: 2, Rows: 2, TileSize: 90, Spacing: 10}
+
+	config, configErr := profile.Config()
+
+	require.NoError(t, configErr)
+	assert.Equal(t, processor.Config{TargetSize: 190, GridSize: 2, TileSize: 90, Spacing: 10, Device: "small"}, config)
+}
+
+func TestDeviceCatalog_Lookup(t *testing.T) {
+	catalog := processor.NewDefaultDeviceCatalog()
+
+	profile, lookupErr := catalog.Lookup("MX-Creative-Console")
+
+	require.NoError(t, lookupErr)
+	assert.Equal(t, processor.DefaultDeviceName, profile.Name)
+}
+
+func TestDeviceCatalog_Lookup_Unknown(t *testing.T) {
+	catalog := processor.NewDefaultDeviceCatalog()
+
+	_, lookupErr := catalog.Lookup("toaster")
+
+	require.ErrorIs(t, lookupErr, processor.ErrUnknownDevice)
+	assert.Contains(t, lookupErr.Error(), "available are: mx-creative-console")
+}
+
+func TestDeviceCatalog_Add_ReplacesByName(t *testing.T) {
+	catalog := processor.NewDefaultDeviceCatalog()
+	custom := processor.DeviceProfile{Name: processor.DefaultDeviceName, Columns: 3, Rows: 3, TileSize: 80, Spacing: 20}
+
+	require.NoError(t, catalog.Add(custom))
+
+	profile, lookupErr := catalog.Lookup(processor.DefaultDeviceName)
+	require.NoError(t, lookupErr)
+	assert.Equal(t, 80, profile.TileSize)
+	assert.Len(t, catalog.Profiles(), len(processor.BuiltinDeviceProfiles()))
+}
+
+func TestDeviceCatalog_Add_Invalid(t *testing.T) {
+	testCases := []struct {
+		name    string
+		profile processor.DeviceProfile
+		message string
+	}{
+		{"missing name", processor.DeviceProfile{Columns: 3, Rows: 3, TileSize: 10}, "no name"},
+		{"zero rows", processor.DeviceProfile{Name: "x", Columns: 3, TileSize: 10}, "columns and rows"},
+		{"zero tile", processor.DeviceProfile{Name: "x", Columns: 3, Rows: 3}, "tile_size"},
+		{"negative spacing", processor.DeviceProfile{Name: "x", Columns: 3, Rows: 3, TileSize: 10, Spacing: -1}, "spacing"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, catalogErr := processor.NewDeviceCatalog(tc.profile)
+
+			require.Error(t, catalogErr)
+			assert.Contains(t, catalogErr.Error(), tc.message)
+		})
+	}
+}
+
+func TestService_LoadDeviceProfiles(t *testing.T) {
+	// Setup
+	mockFS := processor.NewTestMockFileSystem()
+	mockFS.AddFile("/cfg/devices.json", []byte(`{"devices": [
+		{"name": "desk-pad", "description": "Desk pad", "columns": 4, "rows": 4, "tile_size": 80, "spacing": 10}
+	]}`))
+	service, serviceErr := processor.NewServiceWithDeps(mockFS, nil, nil, nil, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+
+	// Execute
+	profiles, loadErr := service.LoadDeviceProfiles("/cfg/devices.json")
+
+	// Assert
+	require.NoError(t, loadErr)
+	require.Len(t, profiles, 1)
+	assert.Equal(t, processor.DeviceProfile{
+		Name: "desk-pad", Description: "Desk pad", Columns: 4, Rows: 4, TileSize: 80, Spacing: 10,
+	}, profiles[0])
+}
+
+func TestService_LoadDeviceProfiles_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		message string
+	}{
+		{"malformed json", `{"devices": [`, "error parsing device profiles"},
+		{"unknown field", `{"devices": [{"name": "x", "colums": 3}]}`, "unknown field"},
+		{"invalid profile", `{"devices": [{"name": "x", "columns": 3, "rows": 3}]}`, "tile_size must be positive"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockFS := processor.NewTestMockFileSystem()
+			mockFS.AddFile("/devices.json", []byte(tc.content))
+			service, serviceErr := processor.NewServiceWithDeps(mockFS, nil, nil, nil, processor.DefaultConfig())
+			require.NoError(t, serviceErr)
+
+			_, loadErr := service.LoadDeviceProfiles("/devices.json")
+
+			require.Error(t, loadErr)
+			assert.Contains(t, loadErr.Error(), tc.message)
+		})
+	}
+}
+
+func TestService_LoadDeviceProfiles_MissingFile(t *testing.T) {
+	service, serviceErr := processor.NewServiceWithDeps(processor.NewTestMockFileSystem(), nil, nil, nil, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+
+	_, loadErr := service.LoadDeviceProfiles("/missing.json")
+
+	require.ErrorIs(t, loadErr, fs.ErrNotExist)
+}
@@ -0,0 +1,312 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"slices"
+	"strings"
+
+	"golang.org/x/image/bmp"
+)
+
+const (
+	// DefaultJPEGQuality is the JPEG quality used when none is given.
+	DefaultJPEGQuality = 90
+	maxJPEGQuality     = 100
+)
+
+// ErrOverBudget is returned by PNGEncoder when an image cannot be made to fit MaxBytes.
+var ErrOverBudget = errors.New("image does not fit the size budget")
+
+// PNGCompression selects the zlib compression level of PNG output.
+type PNGCompression string
+
+const (
+	// PNGCompressionDefault balances size and speed.
+	PNGCompressionDefault PNGCompression = "default"
+	// PNGCompressionNone stores the image data uncompressed.
+	PNGCompressionNone PNGCompression = "none"
+	// PNGCompressionFast compresses quickly at the cost of size.
+	PNGCompressionFast PNGCompression = "fast"
+	// PNGCompressionBest produces the smallest files.
+	PNGCompressionBest PNGCompression = "best"
+)
+
+// PNGCompressions returns the supported PNG compression levels.
+func PNGCompressions() []PNGCompression {
+	return []PNGCompression{PNGCompressionDefault, PNGCompressionNone, PNGCompressionFast, PNGCompressionBest}
+}
+
+// ParsePNGCompression returns the PNG compression level with the given name, ignoring case.
+func ParsePNGCompression(value string) (PNGCompression, error) {
+	names := make([]string, 0, len(PNGCompressions()))
+	for _, compression := range PNGCompressions() {
+		if strings.EqualFold(value, string(compression)) {
+			return compression, nil
+		}
+		names = append(names, string(compression))
+	}
+	return "", fmt.Errorf("invalid PNG compression %q, supported are: %s", value, strings.Join(names, ", "))
+}
+
+func (c PNGCompression) level() png.CompressionLevel {
+	switch c {
+	case PNGCompressionNone:
+		return png.NoCompression
+	case PNGCompressionFast:
+		return png.BestSpeed
+	case PNGCompressionBest:
+		return png.BestCompression
+	default:
+		return png.DefaultCompression
+	}
+}
+
+// EncodeOptions holds the settings an encoder is created with. Encoders
+// ignore the options that do not apply to their format.
+type EncodeOptions struct {
+	// Quality is the JPEG quality from 1 to 100; 0 selects DefaultJPEGQuality.
+	Quality int
+	// Compression is the PNG compression level. Empty means PNGCompressionDefault.
+	Compression PNGCompression
+	// Colors quantizes PNG output to a palette of at most this many colors,
+	// from 2 to 256. 0 keeps full color.
+	Colors int
+	// MaxBytes is the largest PNG file size in bytes; see PNGEncoder.MaxBytes.
+	// 0 means no limit.
+	MaxBytes int
+}
+
+// EncoderFormat describes an output image format.
+type EncoderFormat struct {
+	// Name is the format name selected with --format, e.g. "jpeg".
+	Name string
+	// Aliases lists other names accepted for the format, e.g. "jpg".
+	Aliases []string
+	// New creates an encoder for the format.
+	New func(options EncodeOptions) (ImageEncoder, error)
+}
+
+// DefaultEncoderFormats returns the output formats compiled into the binary.
+func DefaultEncoderFormats() []EncoderFormat {
+	return []EncoderFormat{
+		{
+			Name: "png",
+			New:  newPNGEncoder,
+		},
+		{
+			Name:    "jpeg",
+			Aliases: []string{"jpg"},
+			New:     newJPEGEncoder,
+		},
+		{
+			Name: "gif",
+			New:  func(EncodeOptions) (ImageEncoder, error) { return &GIFEncoder{}, nil },
+		},
+		{
+			Name: "bmp",
+			New:  func(EncodeOptions) (ImageEncoder, error) { return &BMPEncoder{}, nil },
+		},
+		{
+			Name: "webp",
+			New:  func(EncodeOptions) (ImageEncoder, error) { return &WebPEncoder{}, nil },
+		},
+	}
+}
+
+func newPNGEncoder(options EncodeOptions) (ImageEncoder, error) {
+	compression := options.Compression
+	if compression == "" {
+		compression = PNGCompressionDefault
+	}
+	if _, parseErr := ParsePNGCompression(string(compression)); parseErr != nil {
+		return nil, parseErr
+	}
+	if options.Colors != 0 && (options.Colors < MinPaletteColors || options.Colors > MaxPaletteColors) {
+		return nil, fmt.Errorf("invalid PNG colors %d, must be between %d and %d",
+			options.Colors, MinPaletteColors, MaxPaletteColors)
+	}
+	if options.MaxBytes < 0 {
+		return nil, fmt.Errorf("invalid PNG size budget %d, must not be negative", options.MaxBytes)
+	}
+	return &PNGEncoder{Compression: compression, Colors: options.Colors, MaxBytes: options.MaxBytes}, nil
+}
+
+func newJPEGEncoder(options EncodeOptions) (ImageEncoder, error) {
+	quality := options.Quality
+	if quality == 0 {
+		quality = DefaultJPEGQuality
+	}
+	if quality < 1 || quality > maxJPEGQuality {
+		return nil, fmt.Errorf("invalid JPEG quality %d, must be between 1 and %d", quality, maxJPEGQuality)
+	}
+	return &JPEGEncoder{Quality: quality}, nil
+}
+
+// EncoderRegistry looks up output formats by name.
+type EncoderRegistry struct {
+	formats []EncoderFormat
+}
+
+// NewEncoderRegistry creates a registry containing the given formats.
+func NewEncoderRegistry(formats ...EncoderFormat) *EncoderRegistry {
+	registry := &EncoderRegistry{}
+	for _, format := range formats {
+		registry.Register(format)
+	}
+	return registry
+}
+
+// NewDefaultEncoderRegistry creates a registry containing DefaultEncoderFormats.
+func NewDefaultEncoderRegistry() *EncoderRegistry {
+	return NewEncoderRegistry(DefaultEncoderFormats()...)
+}
+
+// Register adds a format to the registry, replacing any format with the same name.
+func (r *EncoderRegistry) Register(format EncoderFormat) {
+	for i, existing := range r.formats {
+		if existing.Name == format.Name {
+			r.formats[i] = format
+			return
+		}
+	}
+	r.formats = append(r.formats, format)
+}
+
+// Formats returns the sorted names of the registered formats.
+func (r *EncoderRegistry) Formats() []string {
+	names := make([]string, 0, len(r.formats))
+	for _, format := range r.formats {
+		names = append(names, format.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Encoder creates an encoder for the format with the given name or alias,
+// ignoring case.
+func (r *EncoderRegistry) Encoder(name string, options EncodeOptions) (ImageEncoder, error) {
+	for _, format := range r.formats {
+		if strings.EqualFold(name, format.Name) ||
+			slices.ContainsFunc(format.Aliases, func(alias string) bool { return strings.EqualFold(name, alias) }) {
+			return format.New(options)
+		}
+	}
+	return nil, fmt.Errorf("unsupported output format %q, supported are: %s", name, strings.Join(r.Formats(), ", "))
+}
+
+// OutputExtension returns the file extension, without the dot, of the images
+// written by encoder, or "png" when the encoder does not tell.
+func OutputExtension(encoder ImageEncoder) string {
+	if named, ok := encoder.(ExtensionEncoder); ok {
+		return named.Extension()
+	}
+	return defaultOutputExtension
+}
+
+// PNGEncoder implements ImageEncoder for PNG format. The zero value writes
+// full color images with the default compression.
+type PNGEncoder struct {
+	// Compression is the zlib compression level. Empty means PNGCompressionDefault.
+	Compression PNGCompression
+	// Colors quantizes the image to a palette of at most this many colors
+	// with Quantize. 0 keeps full color.
+	Colors int
+	// MaxBytes, when positive, is the largest size of an encoded image. Images
+	// that do not fit are quantized to 256 colors, then to half as many colors
+	// at a time down to 2, and ErrOverBudget is returned if none fits.
+	MaxBytes int
+}
+
+// Extension returns the file extension of PNG images.
+func (e *PNGEncoder) Extension() string {
+	return "png"
+}
+
+// Encode encodes an image as PNG.
+func (e *PNGEncoder) Encode(w io.Writer, img image.Image) error {
+	if e.MaxBytes <= 0 {
+		return e.encode(w, img, e.Colors)
+	}
+
+	var buf bytes.Buffer
+	colors := e.Colors
+	for {
+		buf.Reset()
+		if encodeErr := e.encode(&buf, img, colors); encodeErr != nil {
+			return encodeErr
+		}
+		if buf.Len() <= e.MaxBytes {
+			_, writeErr := buf.WriteTo(w)
+			return writeErr
+		}
+
+		switch {
+		case colors == 0:
+			colors = MaxPaletteColors
+		case colors > MinPaletteColors:
+			colors = max(MinPaletteColors, colors/half)
+		default:
+			return fmt.Errorf("%w: %d bytes with %d colors, the budget is %d bytes",
+				ErrOverBudget, buf.Len(), colors, e.MaxBytes)
+		}
+	}
+}
+
+func (e *PNGEncoder) encode(w io.Writer, img image.Image, colors int) error {
+	if colors > 0 {
+		img = Quantize(img, colors)
+	}
+	encoder := &png.Encoder{CompressionLevel: e.Compression.level()}
+	return encoder.Encode(w, img)
+}
+
+// JPEGEncoder implements ImageEncoder for JPEG format. JPEG has no alpha
+// channel, so transparent pixels are written as black.
+type JPEGEncoder struct {
+	// Quality ranges from 1 to 100, higher is better.
+	Quality int
+}
+
+// Extension returns the file extension of JPEG images.
+func (e *JPEGEncoder) Extension() string {
+	return "jpg"
+}
+
+// Encode encodes an image as JPEG.
+func (e *JPEGEncoder) Encode(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: e.Quality})
+}
+
+// GIFEncoder implements ImageEncoder for GIF format. Colors are reduced to
+// the Plan 9 palette with Floyd-Steinberg dithering.
+type GIFEncoder struct{}
+
+// Extension returns the file extension of GIF images.
+func (e *GIFEncoder) Extension() string {
+	return "gif"
+}
+
+// Encode encodes an image as GIF.
+func (e *GIFEncoder) Encode(w io.Writer, img image.Image) error {
+	return gif.Encode(w, img, nil)
+}
+
+// BMPEncoder implements ImageEncoder for BMP format.
+type BMPEncoder struct{}
+
+// Extension returns the file extension of BMP images.
+func (e *BMPEncoder) Extension() string {
+	return "bmp"
+}
+
+// Encode encodes an image as BMP.
+func (e *BMPEncoder) Encode(w io.Writer, img image.Image) error {
+	return bmp.Encode(w, img)
+}
@@ -0,0 +1,249 @@
+package processor_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestEncoderRegistry_Encoder_RoundTripsDefaultFormats(t *testing.T) {
+	img := processor.CreateColoredTestImage(12, 8, color.RGBA{R: 10, G: 200, B: 30, A: 255})
+	registry := processor.NewDefaultEncoderRegistry()
+	decoders := processor.NewDefaultDecoderRegistry()
+
+	testCases := []struct {
+		name      string
+		format    string
+		extension string
+	}{
+		{"png", "png", "png"},
+		{"jpeg", "jpeg", "jpg"},
+		{"jpg alias", "JPG", "jpg"},
+		{"gif", "gif", "gif"},
+		{"bmp", "bmp", "bmp"},
+		{"webp", "webp", "webp"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			encoder, lookupErr := registry.Encoder(tc.format, processor.EncodeOptions{})
+			require.NoError(t, lookupErr)
+
+			// Execute
+			var buf bytes.Buffer
+			require.NoError(t, encoder.Encode(&buf, img))
+			decoded, _, decodeErr := decoders.Decode(&buf)
+
+			// Assert
+			require.NoError(t, decodeErr)
+			assert.Equal(t, img.Bounds().Size(), decoded.Bounds().Size())
+			assert.Equal(t, tc.extension, processor.OutputExtension(encoder))
+		})
+	}
+}
+
+func TestEncoderRegistry_Formats(t *testing.T) {
+	registry := processor.NewDefaultEncoderRegistry()
+
+	assert.Equal(t, []string{"bmp", "gif", "jpeg", "png", "webp"}, registry.Formats())
+}
+
+func TestEncoderRegistry_Encoder_Unsupported(t *testing.T) {
+	registry := processor.NewDefaultEncoderRegistry()
+
+	encoder, lookupErr := registry.Encoder("tga", processor.EncodeOptions{})
+
+	require.Error(t, lookupErr)
+	assert.Nil(t, encoder)
+	assert.Contains(t, lookupErr.Error(), `unsupported output format "tga", supported are: bmp, gif, jpeg, png, webp`)
+}
+
+func TestEncoderRegistry_Encoder_JPEGQuality(t *testing.T) {
+	registry := processor.NewDefaultEncoderRegistry()
+	img := processor.CreateTestImage(64, 64)
+
+	encodedSize := func(quality int) int {
+		encoder, lookupErr := registry.Encoder("jpeg", processor.EncodeOptions{Quality: quality})
+		require.NoError(t, lookupErr)
+		var buf bytes.Buffer
+		require.NoError(t, encoder.Encode(&buf, img))
+		return buf.Len()
+	}
+
+	defaultEncoder, lookupErr := registry.Encoder("jpeg", processor.EncodeOptions{})
+	require.NoError(t, lookupErr)
+	assert.Equal(t, &processor.JPEGEncoder{Quality: processor.DefaultJPEGQuality}, defaultEncoder)
+	assert.Less(t, encodedSize(10), encodedSize(100))
+
+	_, invalidErr := registry.Encoder("jpeg", processor.EncodeOptions{Quality: 101})
+	require.Error(t, invalidErr)
+	assert.Contains(t, invalidErr.Error(), "invalid JPEG quality 101")
+}
+
+func TestEncoderRegistry_Register_ReplacesFormat(t *testing.T) {
+	mock := processor.NewTestMockImageEncoder(nil)
+	registry := processor.NewDefaultEncoderRegistry()
+	registry.Register(processor.EncoderFormat{
+		Name: "png",
+		New:  func(processor.EncodeOptions) (processor.ImageEncoder, error) { return mock, nil },
+	})
+
+	encoder, lookupErr := registry.Encoder("png", processor.EncodeOptions{})
+
+	require.NoError(t, lookupErr)
+	assert.Same(t, mock, encoder)
+	assert.Len(t, registry.Formats(), 5)
+}
+
+func TestOutputExtension_DefaultsToPNG(t *testing.T) {
+	assert.Equal(t, "png", processor.OutputExtension(processor.NewTestMockImageEncoder(nil)))
+}
+
+func TestService_TileOutputPath_UsesEncoderExtension(t *testing.T) {
+	service := processor.NewService().WithEncoder(&processor.WebPEncoder{})
+
+	path := service.TileOutputPath("/photos/image.jpg", processor.TileCoordinate{Number: 3})
+
+	assert.Equal(t, "/photos/image_3.webp", path)
+	assert.Equal(t, "webp", service.OutputExtension())
+}
+
+func TestService_SaveTiles_WithEncoder(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	service, serviceErr := processor.NewServiceWithDeps(
+		fs, nil, processor.NewTestMockImageEncoder(nil), processor.NewTestMockImageResizer(), processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+	procImg := &processor.ProcessedImage{Result: processor.ProcessingResult{
+		Tiles:      []image.Image{processor.CreateTestImage(4, 4)},
+		TileCoords: []processor.TileCoordinate{{Number: 1}},
+	}}
+
+	saveErr := service.WithEncoder(&processor.BMPEncoder{}).SaveTiles(context.Background(), procImg, "/test/image.png")
+
+	require.NoError(t, saveErr)
+	written, exists := fs.GetWrittenFile("/test/image_1.bmp")
+	require.True(t, exists)
+	assert.Equal(t, "BM", string(written[:2]))
+}
+
+func TestPNGEncoder_Encode_Compression(t *testing.T) {
+	img := createCoordinateImage(64, 64)
+	encodedSize := func(compression processor.PNGCompression) int {
+		var buf bytes.Buffer
+		require.NoError(t, (&processor.PNGEncoder{Compression: compression}).Encode(&buf, img))
+		return buf.Len()
+	}
+
+	assert.Less(t, encodedSize(processor.PNGCompressionBest), encodedSize(processor.PNGCompressionNone))
+}
+
+func TestPNGEncoder_Encode_Colors(t *testing.T) {
+	var buf bytes.Buffer
+
+	encodeErr := (&processor.PNGEncoder{Colors: 8}).Encode(&buf, createCoordinateImage(64, 64))
+
+	require.NoError(t, encodeErr)
+	decoded, decodeErr := png.Decode(&buf)
+	require.NoError(t, decodeErr)
+	paletted, ok := decoded.(*image.Paletted)
+	require.True(t, ok, "expected a paletted PNG, got %T", decoded)
+	assert.LessOrEqual(t, len(paletted.Palette), 8)
+}
+
+func TestPNGEncoder_Encode_MaxBytes(t *testing.T) {
+	img := createNoiseImage(64, 64)
+	var full bytes.Buffer
+	require.NoError(t, (&processor.PNGEncoder{}).Encode(&full, img))
+
+	t.Run("reduces colors until it fits", func(t *testing.T) {
+		var buf bytes.Buffer
+		budget := full.Len() / 2
+
+		encodeErr := (&processor.PNGEncoder{MaxBytes: budget}).Encode(&buf, img)
+
+		require.NoError(t, encodeErr)
+		assert.LessOrEqual(t, buf.Len(), budget)
+		decoded, decodeErr := png.Decode(&buf)
+		require.NoError(t, decodeErr)
+		assert.IsType(t, &image.Paletted{}, decoded)
+	})
+
+	t.Run("keeps full color when it fits", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		encodeErr := (&processor.PNGEncoder{MaxBytes: full.Len()}).Encode(&buf, img)
+
+		require.NoError(t, encodeErr)
+		assert.Equal(t, full.Bytes(), buf.Bytes())
+	})
+
+	t.Run("fails when nothing fits", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		encodeErr := (&processor.PNGEncoder{MaxBytes: 10}).Encode(&buf, img)
+
+		require.ErrorIs(t, encodeErr, processor.ErrOverBudget)
+		assert.Contains(t, encodeErr.Error(), "with 2 colors, the budget is 10 bytes")
+		assert.Zero(t, buf.Len())
+	})
+}
+
+func TestEncoderRegistry_Encoder_InvalidPNGOptions(t *testing.T) {
+	registry := processor.NewDefaultEncoderRegistry()
+
+	testCases := []struct {
+		name     string
+		options  processor.EncodeOptions
+		expected string
+	}{
+		{"compression", processor.EncodeOptions{Compression: "max"}, `invalid PNG compression "max"`},
+		{"too few colors", processor.EncodeOptions{Colors: 1}, "invalid PNG colors 1, must be between 2 and 256"},
+		{"too many colors", processor.EncodeOptions{Colors: 257}, "invalid PNG colors 257"},
+		{"negative budget", processor.EncodeOptions{MaxBytes: -1}, "invalid PNG size budget -1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, lookupErr := registry.Encoder("png", tc.options)
+
+			require.Error(t, lookupErr)
+			assert.Contains(t, lookupErr.Error(), tc.expected)
+		})
+	}
+}
+
+func TestParsePNGCompression(t *testing.T) {
+	compression, parseErr := processor.ParsePNGCompression("BEST")
+
+	require.NoError(t, parseErr)
+	assert.Equal(t, processor.PNGCompressionBest, compression)
+}
+
+func TestService_SaveTiles_RecordsSizes(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	service, serviceErr := processor.NewServiceWithDeps(
+		fs, nil, processor.NewTestMockImageEncoder(nil), processor.NewTestMockImageResizer(), processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+	procImg := &processor.ProcessedImage{Result: processor.ProcessingResult{
+		Tiles:      []image.Image{processor.CreateTestImage(4, 4), processor.CreateTestImage(4, 4)},
+		TileCoords: []processor.TileCoordinate{{Number: 1}, {Row: 0, Col: 1, Number: 2}},
+	}}
+
+	require.NoError(t, service.SaveTiles(context.Background(), procImg, "/test/image.png"))
+
+	require.Len(t, procImg.Saved, 2)
+	assert.Equal(t, processor.SavedTile{
+		Coord: processor.TileCoordinate{Row: 0, Col: 1, Number: 2},
+		Path:  "/test/image_2.png",
+		Size:  int64(len("fake png data")),
+	}, procImg.Saved[1])
+}
@@ -2,6 +2,7 @@ package processor
 
 import (
 	"io"
+	iofs "io/fs"
 	"os"
 )
 
@@ -17,3 +18,28 @@ func (fs *OSFileSystem) Open(name string) (io.ReadCloser, error) {
 func (fs *OSFileSystem) Create(name string) (io.WriteCloser, error) {
 	return os.Create(name)
 }
+
+// MkdirAll creates a directory along with any missing parents.
+func (fs *OSFileSystem) MkdirAll(path string, perm iofs.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+// Rename moves a file, replacing newPath if it exists.
+func (fs *OSFileSystem) Rename(oldPath, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+// Remove deletes a file.
+func (fs *OSFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// Stat describes a file.
+func (fs *OSFileSystem) Stat(name string) (iofs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// ReadDir lists a directory sorted by file name.
+func (fs *OSFileSystem) ReadDir(name string) ([]iofs.DirEntry, error) {
+	return os.ReadDir(name)
+}
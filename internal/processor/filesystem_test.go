@@ -2,6 +2,7 @@ package processor_test
 
 import (
 	"fmt"
+	iofs "io/fs"
 	"os"
 	"path/filepath"
 	"testing"
@@ -308,3 +309,33 @@ func BenchmarkOSFileSystem_Open(b *testing.B) {
 		file.Close()
 	}
 }
+
+func TestOSFileSystem_MkdirAll(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "keys", "sunset")
+	fs := &processor.OSFileSystem{}
+
+	require.NoError(t, fs.MkdirAll(dir, 0o755))
+
+	info, statErr := os.Stat(dir)
+	require.NoError(t, statErr)
+	assert.True(t, info.IsDir())
+}
+
+func TestOSFileSystem_RenameStatRemove(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, ".tile.png.tmp"), filepath.Join(dir, "tile.png")
+	require.NoError(t, os.WriteFile(oldPath, []byte("tile"), 0o600))
+	fs := &processor.OSFileSystem{}
+
+	require.NoError(t, fs.Rename(oldPath, newPath))
+
+	_, oldErr := fs.Stat(oldPath)
+	require.ErrorIs(t, oldErr, iofs.ErrNotExist)
+	info, statErr := fs.Stat(newPath)
+	require.NoError(t, statErr)
+	assert.Equal(t, int64(4), info.Size())
+
+	require.NoError(t, fs.Remove(newPath))
+	_, removedErr := fs.Stat(newPath)
+	assert.ErrorIs(t, removedErr, iofs.ErrNotExist)
+}
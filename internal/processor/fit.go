@@ -0,0 +1,148 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+)
+
+// FitMode selects how an image is fitted to the canvas.
+type FitMode string
+
+const (
+	// FitCover scales the image to cover the canvas and crops the overflow.
+	FitCover FitMode = "cover"
+	// FitContain scales the image to fit inside the canvas and pads the rest with Config.Fill.
+	FitContain FitMode = "contain"
+	// FitStretch scales each axis independently to the canvas, ignoring the aspect ratio.
+	FitStretch FitMode = "stretch"
+)
+
+// FitModes returns the supported fit modes.
+func FitModes() []FitMode {
+	return []FitMode{FitCover, FitContain, FitStretch}
+}
+
+// ParseFitMode returns the fit mode with the given name, ignoring case.
+func ParseFitMode(value string) (FitMode, error) {
+	names := make([]string, 0, len(FitModes()))
+	for _, mode := range FitModes() {
+		if strings.EqualFold(value, string(mode)) {
+			return mode, nil
+		}
+		names = append(names, string(mode))
+	}
+	return "", fmt.Errorf("invalid fit mode %q, supported are: %s", value, strings.Join(names, ", "))
+}
+
+func (m FitMode) valid() bool {
+	for _, mode := range FitModes() {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// InsideResizer is implemented by resizers whose result may exceed the
+// requested size, such as IntegerScaleResizer, to resize an image so that it
+// stays within the requested size, which ResizeToContain prefers.
+type InsideResizer interface {
+	ImageResizer
+	ResizeInside(width, height uint, img image.Image) image.Image
+}
+
+// ResizeToContain resizes an image, keeping its aspect ratio, so that it fits
+// entirely inside a width x height area. It uses the ResizeInside of resizers
+// that implement InsideResizer.
+func ResizeToContain(img image.Image, width, height int, resizer ImageResizer) image.Image {
+	bounds := img.Bounds()
+	resize := insideResize(resizer)
+
+	// The image is relatively wider than the target when
+	// origWidth/origHeight > width/height, in which case the width is matched.
+	if bounds.Dx()*height > bounds.Dy()*width {
+		return resize(uint(width), 0, img) // #nosec G115
+	}
+	return resize(0, uint(height), img) // #nosec G115
+}
+
+// insideResize returns the ResizeInside of resizer when it implements
+// InsideResizer, and its Resize otherwise.
+func insideResize(resizer ImageResizer) func(width, height uint, img image.Image) image.Image {
+	if inside, ok := resizer.(InsideResizer); ok {
+		return inside.ResizeInside
+	}
+	return resizer.Resize
+}
+
+// PadToSize centers an image on a width x height canvas filled with fill.
+// Parts of the image that do not fit are cropped.
+func PadToSize(img image.Image, width, height int, fill color.Color) image.Image {
+	return padToSize(img, width, height, fill, false)
+}
+
+// padToSize is PadToSize, blending a transparent image with fill in linear
+// light when linear is set.
+func padToSize(img image.Image, width, height int, fill color.Color, linear bool) image.Image {
+	bounds := img.Bounds()
+	padded := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(padded, padded.Bounds(), image.NewUniform(fill), image.Point{}, draw.Src)
+
+	offset := GravityOrigin(padded.Bounds(), bounds.Dx(), bounds.Dy(), GravityCenter)
+	if linear {
+		drawOverLinear(padded, bounds.Sub(bounds.Min).Add(offset), img, bounds.Min)
+	} else {
+		draw.Draw(padded, bounds.Sub(bounds.Min).Add(offset), img, bounds.Min, draw.Over)
+	}
+
+	return padded
+}
+
+// FitToCanvas resizes an image to a width x height canvas according to the
+// FitMode of config, cropping it with the strategy from Config.Cropper.
+// It returns the resized image, the canvas cut from or padded around it and
+// the area of the resized image the canvas covers, which extends beyond the
+// resized image when it is padded. Config.LinearLight applies to the padding
+// only; the resizer is used as given. With Config.SourceRect, img is taken to
+// be the source rectangle and is stretched to the canvas, or as close to it
+// as an InsideResizer gets and padded to the canvas size.
+func FitToCanvas(
+	img image.Image, width, height int, config Config, resizer ImageResizer,
+) (image.Image, image.Image, image.Rectangle) {
+	if config.SourceRect != nil {
+		resized := insideResize(resizer)(uint(width), uint(height), img) // #nosec G115
+		if resized.Bounds().Size() == image.Pt(width, height) {
+			return resized, resized, resized.Bounds()
+		}
+		squared, window := padToCanvas(resized, width, height, config)
+		return resized, squared, window
+	}
+
+	var resized image.Image
+	switch config.FitMode {
+	case FitContain:
+		resized = ResizeToContain(img, width, height, resizer)
+		squared, window := padToCanvas(resized, width, height, config)
+		return resized, squared, window
+	case FitStretch:
+		resized = resizer.Resize(uint(width), uint(height), img) // #nosec G115
+	default:
+		resized = ResizeToCover(img, width, height, resizer)
+	}
+
+	origin := config.Cropper(img.Bounds()).CropOrigin(resized, width, height)
+	window := image.Rect(0, 0, width, height).Add(origin)
+	return resized, CropAt(resized, width, height, origin), window
+}
+
+// padToCanvas centers resized on a width x height canvas filled with
+// Config.Fill, and returns the canvas and the area of resized it covers.
+func padToCanvas(resized image.Image, width, height int, config Config) (image.Image, image.Rectangle) {
+	bounds := resized.Bounds()
+	offset := GravityOrigin(image.Rect(0, 0, width, height), bounds.Dx(), bounds.Dy(), GravityCenter)
+	window := image.Rect(0, 0, width, height).Add(bounds.Min.Sub(offset))
+	return padToSize(resized, width, height, config.Fill, config.LinearLight), window
+}
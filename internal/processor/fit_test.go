@@ -0,0 +1,98 @@
+package processor_test
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestParseFitMode(t *testing.T) {
+	for _, input := range []string{"cover", "contain", "stretch", "Contain"} {
+		t.Run(input, func(t *testing.T) {
+			mode, parseErr := processor.ParseFitMode(input)
+
+			require.NoError(t, parseErr)
+			assert.Contains(t, processor.FitModes(), mode)
+		})
+	}
+
+	_, parseErr := processor.ParseFitMode("fill")
+	require.Error(t, parseErr)
+	assert.Contains(t, parseErr.Error(), "supported are: cover, contain, stretch")
+}
+
+func TestFitToCanvas(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+
+	testCases := []struct {
+		name            string
+		width, height   int
+		mode            processor.FitMode
+		expectedResized image.Rectangle
+		cornerColor     color.Color
+	}{
+		{"cover wide", 400, 100, processor.FitCover, image.Rect(0, 0, 1512, 378), red},
+		{"cover tall", 100, 400, processor.FitCover, image.Rect(0, 0, 378, 1512), red},
+		{"default is cover", 400, 100, "", image.Rect(0, 0, 1512, 378), red},
+		{"contain wide", 400, 100, processor.FitContain, image.Rect(0, 0, 378, 95), blue},
+		{"contain tall", 100, 400, processor.FitContain, image.Rect(0, 0, 95, 378), blue},
+		{"contain square", 200, 200, processor.FitContain, image.Rect(0, 0, 378, 378), red},
+		{"stretch wide", 400, 100, processor.FitStretch, image.Rect(0, 0, 378, 378), red},
+		{"stretch tall", 100, 400, processor.FitStretch, image.Rect(0, 0, 378, 378), red},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := processor.Config{FitMode: tc.mode, Fill: blue}
+			resized, canvas, _ := processor.FitToCanvas(processor.CreateTestImage(tc.width, tc.height),
+				378, 378, config, &processor.LanczosResizer{})
+
+			assert.Equal(t, tc.expectedResized, resized.Bounds())
+			assert.Equal(t, image.Rect(0, 0, 378, 378), canvas.Bounds())
+			assertSameColor(t, tc.cornerColor, canvas.At(0, 0), "canvas corner")
+			assertSameColor(t, red, canvas.At(189, 189), "canvas center")
+		})
+	}
+}
+
+func TestPadToSize_Transparent(t *testing.T) {
+	padded := processor.PadToSize(processor.CreateTestImage(10, 4), 10, 10, color.NRGBA{})
+
+	assertSameColor(t, color.RGBA{}, padded.At(5, 2), "padding above")
+	assertSameColor(t, color.RGBA{R: 255, A: 255}, padded.At(5, 3), "image top row")
+	assertSameColor(t, color.RGBA{R: 255, A: 255}, padded.At(5, 6), "image bottom row")
+	assertSameColor(t, color.RGBA{}, padded.At(5, 7), "padding below")
+}
+
+func TestService_ProcessImageData_Contain(t *testing.T) {
+	config := processor.DefaultConfig()
+	config.FitMode = processor.FitContain
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, &processor.LanczosResizer{}, config)
+	require.NoError(t, serviceErr)
+
+	result, processErr := service.ProcessImageData(context.Background(),
+		&processor.ProcessedImage{Original: processor.CreateTestImage(800, 200)})
+	require.NoError(t, processErr)
+
+	// The letterboxed image leaves the top row of keys transparent.
+	require.Len(t, result.Result.Tiles, 9)
+	assertSameColor(t, color.RGBA{}, result.Result.Tiles[0].At(58, 58))
+	assertSameColor(t, color.RGBA{R: 255, A: 255}, result.Result.Tiles[4].At(58, 58))
+}
+
+func TestConfig_Validate_FitMode(t *testing.T) {
+	config := processor.DefaultConfig()
+	config.FitMode = "fill"
+
+	var configErr *processor.ConfigError
+	require.ErrorAs(t, config.Validate(), &configErr)
+	assert.Equal(t, "FitMode", configErr.Field)
+	assert.EqualError(t, configErr, `invalid FitMode "fill": must be one of cover, contain, stretch`)
+}
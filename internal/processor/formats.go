@@ -0,0 +1,182 @@
+package processor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"slices"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// ErrUnsupportedFormat is returned when no registered format recognizes the input.
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// Format describes an image format that can be recognized and decoded.
+type Format struct {
+	// Name is the format name reported by Decode, e.g. "jpeg".
+	Name string
+	// Extensions lists the file extensions commonly used for the format.
+	Extensions []string
+	// Magic lists the header signatures of the format. A '?' matches any byte.
+	Magic []string
+	// Decode decodes an image in this format.
+	Decode func(r io.Reader) (image.Image, error)
+	// DecodeConfig decodes the color model and dimensions without decoding the whole image.
+	DecodeConfig func(r io.Reader) (image.Config, error)
+}
+
+// DefaultFormats returns the input formats compiled into the binary.
+func DefaultFormats() []Format {
+	return []Format{
+		{
+			Name:         "png",
+			Extensions:   []string{".png"},
+			Magic:        []string{"\x89PNG\r\n\x1a\n"},
+			Decode:       png.Decode,
+			DecodeConfig: png.DecodeConfig,
+		},
+		{
+			Name:         "jpeg",
+			Extensions:   []string{".jpg", ".jpeg", ".jpe", ".jfif"},
+			Magic:        []string{"\xff\xd8"},
+			Decode:       jpeg.Decode,
+			DecodeConfig: jpeg.DecodeConfig,
+		},
+		{
+			Name:         "gif",
+			Extensions:   []string{".gif"},
+			Magic:        []string{"GIF87a", "GIF89a"},
+			Decode:       gif.Decode,
+			DecodeConfig: gif.DecodeConfig,
+		},
+		{
+			Name:         "bmp",
+			Extensions:   []string{".bmp", ".dib"},
+			Magic:        []string{"BM????\x00\x00\x00\x00"},
+			Decode:       bmp.Decode,
+			DecodeConfig: bmp.DecodeConfig,
+		},
+		{
+			Name:         "tiff",
+			Extensions:   []string{".tif", ".tiff"},
+			Magic:        []string{"II*\x00", "MM\x00*"},
+			Decode:       tiff.Decode,
+			DecodeConfig: tiff.DecodeConfig,
+		},
+		{
+			Name:         "webp",
+			Extensions:   []string{".webp"},
+			Magic:        []string{"RIFF????WEBPVP8"},
+			Decode:       webp.Decode,
+			DecodeConfig: webp.DecodeConfig,
+		},
+	}
+}
+
+// DecoderRegistry implements ImageDecoder by sniffing the input header
+// and dispatching to the matching registered format.
+type DecoderRegistry struct {
+	formats []Format
+}
+
+// NewDecoderRegistry creates a registry containing the given formats.
+func NewDecoderRegistry(formats ...Format) *DecoderRegistry {
+	registry := &DecoderRegistry{}
+	for _, format := range formats {
+		registry.Register(format)
+	}
+	return registry
+}
+
+// NewDefaultDecoderRegistry creates a registry containing DefaultFormats.
+func NewDefaultDecoderRegistry() *DecoderRegistry {
+	return NewDecoderRegistry(DefaultFormats()...)
+}
+
+// Register adds a format to the registry, replacing any format with the same name.
+func (r *DecoderRegistry) Register(format Format) {
+	for i, existing := range r.formats {
+		if existing.Name == format.Name {
+			r.formats[i] = format
+			return
+		}
+	}
+	r.formats = append(r.formats, format)
+}
+
+// Formats returns the sorted names of the registered formats.
+func (r *DecoderRegistry) Formats() []string {
+	names := make([]string, 0, len(r.formats))
+	for _, format := range r.formats {
+		names = append(names, format.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Decode detects the format of the data in r and decodes it.
+func (r *DecoderRegistry) Decode(rd io.Reader) (image.Image, string, error) {
+	format, br, sniffErr := r.sniff(rd)
+	if sniffErr != nil {
+		return nil, "", sniffErr
+	}
+
+	img, decodeErr := format.Decode(br)
+	if decodeErr != nil {
+		return nil, "", fmt.Errorf("error decoding %s: %w", format.Name, decodeErr)
+	}
+	return img, format.Name, nil
+}
+
+// DecodeConfig detects the format of the data in r and decodes its dimensions.
+func (r *DecoderRegistry) DecodeConfig(rd io.Reader) (image.Config, string, error) {
+	format, br, sniffErr := r.sniff(rd)
+	if sniffErr != nil {
+		return image.Config{}, "", sniffErr
+	}
+
+	cfg, decodeErr := format.DecodeConfig(br)
+	if decodeErr != nil {
+		return image.Config{}, "", fmt.Errorf("error decoding %s: %w", format.Name, decodeErr)
+	}
+	return cfg, format.Name, nil
+}
+
+func (r *DecoderRegistry) sniff(rd io.Reader) (Format, *bufio.Reader, error) {
+	br := bufio.NewReader(rd)
+	for _, format := range r.formats {
+		for _, magic := range format.Magic {
+			header, _ := br.Peek(len(magic))
+			if matchMagic(magic, header) {
+				return format, br, nil
+			}
+		}
+	}
+	return Format{}, nil, r.unsupportedError()
+}
+
+func (r *DecoderRegistry) unsupportedError() error {
+	return fmt.Errorf("%w, supported are: %s", ErrUnsupportedFormat, strings.Join(r.Formats(), ", "))
+}
+
+// matchMagic reports whether header matches magic, treating '?' as a wildcard.
+func matchMagic(magic string, header []byte) bool {
+	if len(header) != len(magic) {
+		return false
+	}
+	for i := range len(magic) {
+		if magic[i] != '?' && magic[i] != header[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package processor_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestDecoderRegistry_Decode_DefaultFormats(t *testing.T) {
+	img := processor.CreateColoredTestImage(12, 8, color.RGBA{R: 10, G: 200, B: 30, A: 255})
+
+	testCases := []struct {
+		name   string
+		encode func(w io.Writer, m image.Image) error
+	}{
+		{"png", png.Encode},
+		{"jpeg", func(w io.Writer, m image.Image) error { return jpeg.Encode(w, m, nil) }},
+		{"gif", func(w io.Writer, m image.Image) error { return gif.Encode(w, m, nil) }},
+		{"bmp", bmp.Encode},
+		{"tiff", func(w io.Writer, m image.Image) error { return tiff.Encode(w, m, nil) }},
+	}
+
+	registry := processor.NewDefaultDecoderRegistry()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			var buf bytes.Buffer
+			require.NoError(t, tc.encode(&buf, img))
+
+			// Execute
+			decoded, format, decodeErr := registry.Decode(&buf)
+
+			// Assert
+			require.NoError(t, decodeErr)
+			assert.Equal(t, tc.name, format)
+			assert.Equal(t, 12, decoded.Bounds().Dx())
+			assert.Equal(t, 8, decoded.Bounds().Dy())
+		})
+	}
+}
+
+func TestDecoderRegistry_Decode_WebPIsRecognized(t *testing.T) {
+	// Setup - a RIFF/WEBP header with a truncated payload
+	registry := processor.NewDefaultDecoderRegistry()
+	data := strings.NewReader("RIFF\x00\x00\x00\x00WEBPVP8L")
+
+	// Execute
+	_, _, decodeErr := registry.Decode(data)
+
+	// Assert - the data reaches the WebP decoder instead of being rejected as unknown
+	require.Error(t, decodeErr)
+	require.NotErrorIs(t, decodeErr, processor.ErrUnsupportedFormat)
+	assert.Contains(t, decodeErr.Error(), "webp")
+}
+
+func TestDecoderRegistry_Decode_UnsupportedFormat(t *testing.T) {
+	// Setup
+	registry := processor.NewDefaultDecoderRegistry()
+
+	// Execute
+	img, format, decodeErr := registry.Decode(strings.NewReader("definitely not an image"))
+
+	// Assert
+	require.ErrorIs(t, decodeErr, processor.ErrUnsupportedFormat)
+	assert.Nil(t, img)
+	assert.Empty(t, format)
+	assert.Contains(t, decodeErr.Error(), "supported are: bmp, gif, jpeg, png, tiff, webp")
+}
+
+func TestDecoderRegistry_Decode_EmptyInput(t *testing.T) {
+	registry := processor.NewDefaultDecoderRegistry()
+
+	_, _, decodeErr := registry.Decode(bytes.NewReader(nil))
+
+	require.ErrorIs(t, decodeErr, processor.ErrUnsupportedFormat)
+}
+
+func TestDecoderRegistry_Register_CustomFormat(t *testing.T) {
+	// Setup
+	custom := processor.Format{
+		Name:  "fake",
+		Magic: []string{"FAKE"},
+		Decode: func(_ io.Reader) (image.Image, error) {
+			return processor.CreateTestImage(3, 3), nil
+		},
+	}
+	registry := processor.NewDecoderRegistry(custom)
+
+	// Execute
+	img, format, decodeErr := registry.Decode(strings.NewReader("FAKE data"))
+
+	// Assert
+	require.NoError(t, decodeErr)
+	assert.Equal(t, "fake", format)
+	assert.Equal(t, 3, img.Bounds().Dx())
+	assert.Equal(t, []string{"fake"}, registry.Formats())
+}
+
+func TestDecoderRegistry_Register_ReplacesExisting(t *testing.T) {
+	registry := processor.NewDefaultDecoderRegistry()
+	before := registry.Formats()
+
+	registry.Register(processor.Format{Name: "png", Magic: []string{"\x89PNG"}})
+
+	assert.Equal(t, before, registry.Formats())
+}
+
+func TestDecoderRegistry_DecodeConfig(t *testing.T) {
+	// Setup
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, processor.CreateTestImage(40, 30)))
+	registry := processor.NewDefaultDecoderRegistry()
+
+	// Execute
+	cfg, format, decodeErr := registry.DecodeConfig(&buf)
+
+	// Assert
+	require.NoError(t, decodeErr)
+	assert.Equal(t, "png", format)
+	assert.Equal(t, 40, cfg.Width)
+	assert.Equal(t, 30, cfg.Height)
+}
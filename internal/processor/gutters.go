@@ -0,0 +1,180 @@
+package processor
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// gutterScaleSteps and gutterScaleStep give the zoom levels tried by the
+	// gutter optimizer: 1.00, 1.025, 1.05, 1.075 and 1.10.
+	gutterScaleSteps = 5
+	gutterScaleStep  = 0.025
+)
+
+// GutterReport describes how much image detail falls into the spacing between
+// keys, which SplitIntoTiles discards, and how the crop was adjusted to reduce it.
+type GutterReport struct {
+	// Offset is how far the crop window was moved from where the crop
+	// strategy placed it, in pixels of the resized image.
+	Offset image.Point
+	// Scale is the zoom applied on top of the fitted size.
+	Scale float64
+	// DetailLost is the fraction of the detail of the key area that lands in
+	// the gutters with the chosen crop, from 0 to 1.
+	DetailLost float64
+	// BaselineLost is DetailLost of the crop before optimization.
+	BaselineLost float64
+}
+
+// Moved reports whether the optimizer changed the crop.
+func (r GutterReport) Moved() bool {
+	return r.Offset != (image.Point{}) || r.Scale != 1
+}
+
+// gutterCandidate is a crop window of a resized image considered by the optimizer.
+type gutterCandidate struct {
+	resized image.Image
+	origin  image.Point
+	offset  image.Point
+	scale   float64
+	lost    float64
+	cost    int
+}
+
+// OptimizeGutters searches small offsets of the crop window, up to the spacing
+// on each axis, and zoom levels up to 10% for the crop of img that puts the
+// least detail (luminance gradient) into the gutters between keys. Resized and
+// crop are the result of FitToCanvas with the same arguments.
+//
+// It returns the chosen resized image and crop window along with the report.
+// Images fitted with FitContain are returned unchanged since their padding
+// already keeps the whole image on the canvas.
+func OptimizeGutters(
+	img, resized image.Image, crop image.Rectangle, config Config, resizer ImageResizer,
+) (image.Image, image.Rectangle, GutterReport) {
+	baseline := gutterLoss(newDetailMap(resized), resized.Bounds(), crop.Min, config)
+	report := GutterReport{Scale: 1, DetailLost: baseline, BaselineLost: baseline}
+	if config.FitMode == FitContain {
+		return resized, crop, report
+	}
+
+	width, height := crop.Dx(), crop.Dy()
+	center := crop.Min.Add(image.Point{X: width / centerDivisor, Y: height / centerDivisor}).Sub(resized.Bounds().Min)
+	best := gutterCandidate{resized: resized, origin: crop.Min, scale: 1, lost: baseline}
+
+	for step := range gutterScaleSteps {
+		scale := 1 + float64(step)*gutterScaleStep
+		scaled := resized
+		if step > 0 {
+			scaled = resizeForGutters(img, width, height, scale, config.FitMode, resizer)
+		}
+		bounds := scaled.Bounds()
+		detail := newDetailMap(scaled)
+
+		// Keep the crop centered on the same point of the image at every scale.
+		anchor := image.Point{
+			X: bounds.Min.X + center.X*bounds.Dx()/resized.Bounds().Dx() - width/centerDivisor,
+			Y: bounds.Min.Y + center.Y*bounds.Dy()/resized.Bounds().Dy() - height/centerDivisor,
+		}
+
+		for dy := -config.SpacingY(); dy <= config.SpacingY(); dy++ {
+			for dx := -config.SpacingX(); dx <= config.SpacingX(); dx++ {
+				offset := image.Point{X: dx, Y: dy}
+				origin := anchor.Add(offset)
+				if origin != clampOrigin(bounds, width, height, origin) {
+					continue
+				}
+
+				candidate := gutterCandidate{
+					resized: scaled,
+					origin:  origin,
+					offset:  offset,
+					scale:   scale,
+					lost:    gutterLoss(detail, bounds, origin, config),
+					cost:    step*(config.SpacingX()+config.SpacingY()+1) + abs(dx) + abs(dy),
+				}
+				if candidate.lost < best.lost-scoreEpsilon ||
+					(candidate.lost < best.lost+scoreEpsilon && candidate.cost < best.cost) {
+					best = candidate
+				}
+			}
+		}
+	}
+
+	report.Offset, report.Scale, report.DetailLost = best.offset, best.scale, best.lost
+	return best.resized, image.Rect(0, 0, width, height).Add(best.origin), report
+}
+
+// resizeForGutters resizes img like FitToCanvas for a canvas zoomed by scale.
+func resizeForGutters(img image.Image, width, height int, scale float64, mode FitMode, resizer ImageResizer) image.Image {
+	scaledWidth := int(math.Round(float64(width) * scale))
+	scaledHeight := int(math.Round(float64(height) * scale))
+	if mode == FitStretch {
+		return resizer.Resize(uint(scaledWidth), uint(scaledHeight), img) // #nosec G115
+	}
+	return ResizeToCover(img, scaledWidth, scaledHeight, resizer)
+}
+
+// gutterLoss returns the fraction of the detail of the key area that falls in
+// the gutters when the canvas is cut from bounds at origin.
+func gutterLoss(detail summedArea, bounds image.Rectangle, origin image.Point, config Config) float64 {
+	// The detail map is indexed from the top-left corner of bounds.
+	base := origin.Sub(bounds.Min)
+	layout := config.LayoutSize()
+	region := func(r image.Rectangle) float64 {
+		r = r.Add(base).Intersect(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		if r.Empty() {
+			return 0
+		}
+		return detail.sum(r)
+	}
+
+	total := region(image.Rect(0, 0, layout.X, layout.Y))
+	if total == 0 {
+		return 0
+	}
+
+	pitchX, pitchY := config.TileSize+config.SpacingX(), config.TileSize+config.SpacingY()
+	keys := 0.0
+	for row := range config.GridRows() {
+		for col := range config.GridColumns() {
+			x, y := col*pitchX, row*pitchY
+			keys += region(image.Rect(x, y, x+config.TileSize, y+config.TileSize))
+		}
+	}
+
+	return max(0, total-keys) / total
+}
+
+// newDetailMap returns a summed-area table of the luminance gradient of img.
+func newDetailMap(img image.Image) summedArea {
+	bounds := img.Bounds()
+	cols, rows := bounds.Dx(), bounds.Dy()
+
+	luminance := make([]float64, cols*rows)
+	for y := range rows {
+		for x := range cols {
+			luminance[y*cols+x] = pixelLuma(img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+
+	lum := func(x, y int) float64 {
+		return luminance[min(max(y, 0), rows-1)*cols+min(max(x, 0), cols-1)]
+	}
+	detail := make([]float64, cols*rows)
+	for y := range rows {
+		for x := range cols {
+			detail[y*cols+x] = math.Abs(lum(x+1, y)-lum(x-1, y)) + math.Abs(lum(x, y+1)-lum(x, y-1))
+		}
+	}
+
+	return newSummedArea(detail, cols, rows)
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
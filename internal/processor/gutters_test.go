@@ -0,0 +1,94 @@
+package processor_test
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+// gutterConfig is a 2x2 grid whose only vertical gutter spans x = 90..100.
+func gutterConfig() processor.Config {
+	return processor.Config{GridSize: 2, TileSize: 90, Spacing: 10, OptimizeGutters: true}
+}
+
+func TestOptimizeGutters_MovesDetailOutOfGutter(t *testing.T) {
+	// Setup - a centered crop of this image puts the stripe at x = 122..128
+	// of the image right into the gutter
+	config := gutterConfig()
+	img := createPatchedImage(250, 190, image.Rect(122, 0, 128, 190), checkerboard)
+	resizer := &processor.LanczosResizer{}
+	resized, _, crop := processor.FitToCanvas(img, 190, 190, config, resizer)
+	require.Equal(t, image.Rect(30, 0, 220, 190), crop)
+
+	// Execute
+	_, optimized, report := processor.OptimizeGutters(img, resized, crop, config, resizer)
+
+	// Assert
+	assert.Greater(t, report.BaselineLost, 0.5)
+	assert.Less(t, report.DetailLost, 0.05)
+	assert.True(t, report.Moved())
+	assert.NotZero(t, report.Offset.X)
+	assert.Equal(t, crop.Size(), optimized.Size())
+}
+
+func TestOptimizeGutters_FlatImageIsKept(t *testing.T) {
+	config := gutterConfig()
+	img := processor.CreateTestImage(250, 190)
+	resizer := &processor.LanczosResizer{}
+	resized, _, crop := processor.FitToCanvas(img, 190, 190, config, resizer)
+
+	optimizedResized, optimized, report := processor.OptimizeGutters(img, resized, crop, config, resizer)
+
+	assert.False(t, report.Moved())
+	assert.Zero(t, report.DetailLost)
+	assert.Equal(t, crop, optimized)
+	assert.Same(t, resized, optimizedResized)
+}
+
+func TestOptimizeGutters_ZoomsWithoutSlack(t *testing.T) {
+	// Setup - a square image leaves no room to shift without zooming in
+	config := gutterConfig()
+	img := createPatchedImage(190, 190, image.Rect(92, 0, 98, 190), checkerboard)
+	resizer := &processor.LanczosResizer{}
+	resized, _, crop := processor.FitToCanvas(img, 190, 190, config, resizer)
+
+	// Execute
+	optimizedResized, _, report := processor.OptimizeGutters(img, resized, crop, config, resizer)
+
+	// Assert
+	assert.Greater(t, report.Scale, 1.0)
+	assert.Less(t, report.DetailLost, report.BaselineLost)
+	assert.Greater(t, optimizedResized.Bounds().Dx(), 190)
+}
+
+func TestOptimizeGutters_ContainIsUnchanged(t *testing.T) {
+	config := gutterConfig()
+	config.FitMode = processor.FitContain
+	img := createPatchedImage(250, 190, image.Rect(122, 0, 128, 190), checkerboard)
+	resizer := &processor.LanczosResizer{}
+	resized, _, crop := processor.FitToCanvas(img, 190, 190, config, resizer)
+
+	_, optimized, report := processor.OptimizeGutters(img, resized, crop, config, resizer)
+
+	assert.False(t, report.Moved())
+	assert.Equal(t, crop, optimized)
+}
+
+func TestService_ProcessImageData_OptimizeGutters(t *testing.T) {
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, &processor.LanczosResizer{}, gutterConfig())
+	require.NoError(t, serviceErr)
+	img := createPatchedImage(250, 190, image.Rect(122, 0, 128, 190), checkerboard)
+
+	result, processErr := service.ProcessImageData(context.Background(), &processor.ProcessedImage{Original: img})
+	require.NoError(t, processErr)
+
+	require.NotNil(t, result.Gutters)
+	assert.True(t, result.Gutters.Moved())
+	assert.Equal(t, image.Rect(0, 0, 190, 190), result.Squared.Bounds())
+	assert.Len(t, result.Result.Tiles, 4)
+}
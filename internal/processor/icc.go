@@ -0,0 +1,337 @@
+package processor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"unicode/utf16"
+)
+
+// Layout of an ICC profile, see https://www.color.org/specification/ICC.1-2022-05.pdf.
+const (
+	iccHeaderSize       = 128
+	iccTagEntrySize     = 12
+	iccTypeHeaderSize   = 8
+	iccColorSpaceOffset = 16
+	iccSignatureOffset  = 36
+	iccXYZSize          = 12
+	iccFixedOne         = 1 << 16
+	iccGammaOne         = 1 << 8
+	iccMlucRecordSize   = 12
+	// srgbTolerance is how far the colorants and curves of a profile may be
+	// from sRGB for the profile to be treated as sRGB.
+	srgbTolerance  = 0.002
+	srgbCurveTests = 16
+)
+
+// ErrUnsupportedProfile is returned by ParseColorProfile for ICC profiles
+// that are not RGB matrix profiles, such as CMYK or lookup table profiles.
+var ErrUnsupportedProfile = errors.New("unsupported color profile")
+
+// srgbColorants returns the XYZ coordinates of the sRGB primaries adapted to
+// the D50 white of ICC profiles, one column per primary.
+func srgbColorants() [3][3]float64 {
+	return [3][3]float64{
+		{0.4360747, 0.3850649, 0.1430804},
+		{0.2225045, 0.7168786, 0.0606169},
+		{0.0139322, 0.0971045, 0.7141733},
+	}
+}
+
+// ColorProfile is an RGB matrix ICC profile, such as Display P3 or Adobe RGB:
+// a tone curve per channel followed by a matrix to the XYZ colors of the
+// profile connection space.
+type ColorProfile struct {
+	// Description is the name of the profile, e.g. "Display P3".
+	Description string
+	colorants   [3][3]float64
+	curves      [3]toneCurve
+}
+
+// toneCurve converts an encoded channel value from 0 to 1 to linear light.
+type toneCurve func(float64) float64
+
+// ParseColorProfile parses an ICC profile. Only RGB profiles made of tone
+// curves and colorants are supported, which covers the usual display and
+// working spaces.
+func ParseColorProfile(data []byte) (*ColorProfile, error) {
+	if len(data) < iccHeaderSize+4 || string(data[iccSignatureOffset:iccSignatureOffset+4]) != "acsp" {
+		return nil, errors.New("invalid color profile: missing ICC header")
+	}
+	if space := string(data[iccColorSpaceOffset : iccColorSpaceOffset+4]); space != "RGB " {
+		return nil, fmt.Errorf("%w: color space %q", ErrUnsupportedProfile, space)
+	}
+
+	tags := map[string][]byte{}
+	count := int(binary.BigEndian.Uint32(data[iccHeaderSize:]))
+	table := data[iccHeaderSize+4:]
+	if count > len(table)/iccTagEntrySize {
+		return nil, errors.New("invalid color profile: truncated tag table")
+	}
+	for i := range count {
+		entry := table[i*iccTagEntrySize:]
+		offset, size := binary.BigEndian.Uint32(entry[4:]), binary.BigEndian.Uint32(entry[8:])
+		if uint64(offset)+uint64(size) > uint64(len(data)) || size < iccTypeHeaderSize {
+			return nil, fmt.Errorf("invalid color profile: tag %q out of bounds", entry[:4])
+		}
+		tags[string(entry[:4])] = data[offset : offset+size]
+	}
+
+	profile := &ColorProfile{Description: profileDescription(tags["desc"])}
+	if profile.Description == "" {
+		profile.Description = "unnamed profile"
+	}
+	for ch, prefix := range []string{"r", "g", "b"} {
+		colorant, colorantErr := parseXYZ(tags[prefix+"XYZ"])
+		if colorantErr != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnsupportedProfile, colorantErr)
+		}
+		for row := range colorant {
+			profile.colorants[row][ch] = colorant[row]
+		}
+		curve, curveErr := parseToneCurve(tags[prefix+"TRC"])
+		if curveErr != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnsupportedProfile, curveErr)
+		}
+		profile.curves[ch] = curve
+	}
+	return profile, nil
+}
+
+// parseXYZ parses an XYZType tag.
+func parseXYZ(tag []byte) ([3]float64, error) {
+	if len(tag) < iccTypeHeaderSize+iccXYZSize || string(tag[:4]) != "XYZ " {
+		return [3]float64{}, errors.New("missing colorant")
+	}
+	var xyz [3]float64
+	for i := range xyz {
+		xyz[i] = s15Fixed16(tag[iccTypeHeaderSize+4*i:])
+	}
+	return xyz, nil
+}
+
+// parseToneCurve parses a curveType or parametricCurveType tag.
+func parseToneCurve(tag []byte) (toneCurve, error) {
+	if len(tag) < iccTypeHeaderSize+4 {
+		return nil, errors.New("missing tone curve")
+	}
+
+	switch string(tag[:4]) {
+	case "curv":
+		count := int(binary.BigEndian.Uint32(tag[iccTypeHeaderSize:]))
+		values := tag[iccTypeHeaderSize+4:]
+		if count > len(values)/2 {
+			return nil, errors.New("truncated tone curve")
+		}
+		switch count {
+		case 0:
+			return func(x float64) float64 { return x }, nil
+		case 1:
+			gamma := float64(binary.BigEndian.Uint16(values)) / iccGammaOne
+			return func(x float64) float64 { return math.Pow(x, gamma) }, nil
+		default:
+			table := make([]float64, count)
+			for i := range table {
+				table[i] = float64(binary.BigEndian.Uint16(values[2*i:])) / maxUint16
+			}
+			return func(x float64) float64 {
+				position := min(1, max(0, x)) * float64(count-1)
+				i := min(count-2, int(position))
+				return table[i] + (table[i+1]-table[i])*(position-float64(i))
+			}, nil
+		}
+	case "para":
+		// Parametric curves of type 0 to 4 take 1, 3, 4, 5 and 7 parameters.
+		parameterCounts := []int{1, 3, 4, 5, 7}
+		kind := int(binary.BigEndian.Uint16(tag[iccTypeHeaderSize:]))
+		if kind >= len(parameterCounts) || len(tag) < iccTypeHeaderSize+4+4*parameterCounts[kind] {
+			return nil, fmt.Errorf("unknown parametric curve type %d", kind)
+		}
+		// g, a, b, c, d, e, f as in the specification, with the defaults
+		// that turn the simpler types into type 4.
+		p := [7]float64{1, 1, 0, 0, math.Inf(-1), 0, 0}
+		for i := range parameterCounts[kind] {
+			p[i] = s15Fixed16(tag[iccTypeHeaderSize+4+4*i:])
+		}
+		if kind == 1 || kind == 2 {
+			// Y = (aX+b)^g + c from X = -b/a, and c below it.
+			p[3], p[4], p[5], p[6] = 0, -p[2]/p[1], p[3], p[3]
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+		return func(x float64) float64 {
+			if x >= d {
+				return math.Pow(max(0, a*x+b), g) + e
+			}
+			return c*x + f
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported tone curve type %q", tag[:4])
+	}
+}
+
+// profileDescription returns the text of a textDescriptionType (ICC v2) or
+// multiLocalizedUnicodeType (ICC v4) tag, preferring the first record.
+func profileDescription(tag []byte) string {
+	switch {
+	case len(tag) >= iccTypeHeaderSize+4 && string(tag[:4]) == "desc":
+		length := int(binary.BigEndian.Uint32(tag[iccTypeHeaderSize:]))
+		text := tag[iccTypeHeaderSize+4:]
+		if length == 0 || length > len(text) {
+			return ""
+		}
+		return string(text[:length-1])
+	case len(tag) >= iccTypeHeaderSize+8+iccMlucRecordSize && string(tag[:4]) == "mluc":
+		record := tag[iccTypeHeaderSize+8:]
+		length, offset := binary.BigEndian.Uint32(record[4:]), binary.BigEndian.Uint32(record[8:])
+		if uint64(offset)+uint64(length) > uint64(len(tag)) {
+			return ""
+		}
+		text := tag[offset : offset+length]
+		units := make([]uint16, len(text)/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(text[2*i:])
+		}
+		return string(utf16.Decode(units))
+	default:
+		return ""
+	}
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / iccFixedOne // #nosec G115
+}
+
+// IsSRGB reports whether the profile describes sRGB, in which case images
+// need no conversion.
+func (p *ColorProfile) IsSRGB() bool {
+	srgb := srgbColorants()
+	for row := range p.colorants {
+		for col := range p.colorants[row] {
+			if math.Abs(p.colorants[row][col]-srgb[row][col]) > srgbTolerance {
+				return false
+			}
+		}
+	}
+	for _, curve := range p.curves {
+		for i := range srgbCurveTests + 1 {
+			x := float64(i) / srgbCurveTests
+			if math.Abs(curve(x)-float64(srgbToLinear(float32(x)))) > srgbTolerance {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ConvertToSRGB converts an image in the color space of the profile to
+// sRGB. Colors outside of the sRGB gamut are clipped. The result is an
+// *image.RGBA, or an *image.RGBA64 for images with 16 bits per channel.
+func (p *ColorProfile) ConvertToSRGB(img image.Image) image.Image {
+	// From the channels of the profile to XYZ, then from XYZ to sRGB.
+	toSRGB := multiply3(invert3(srgbColorants()), p.colorants)
+	encode := linearToSRGB16()
+	bounds := img.Bounds()
+
+	if !newPixelSource(img, false).deep {
+		var decode [3][maxUint8 + 1]float64
+		for ch, curve := range p.curves {
+			for c := range decode[ch] {
+				decode[ch][c] = curve(float64(c) / maxUint8)
+			}
+		}
+		rgba := image.NewRGBA(bounds)
+		draw.Draw(rgba, bounds, img, bounds.Min, draw.Src)
+		parallelFor(bounds.Dy(), 0, func(y int) {
+			row := rgba.Pix[y*rgba.Stride : y*rgba.Stride+bounds.Dx()*rgbaChannels]
+			for x := 0; x < len(row); x += rgbaChannels {
+				pixel := row[x : x+rgbaChannels]
+				alpha := uint32(pixel[3])
+				if alpha == 0 {
+					continue
+				}
+				var linear [3]float64
+				for ch := range linear {
+					linear[ch] = decode[ch][(uint32(pixel[ch])*maxUint8+alpha/2)/alpha]
+				}
+				for ch, value := range transform3(toSRGB, linear) {
+					c := uint32(encode[int(min(1, max(0, value))*maxUint16+0.5)])
+					pixel[ch] = uint8((c*alpha + maxUint16/2) / maxUint16) // #nosec G115
+				}
+			}
+		})
+		return rgba
+	}
+
+	rgba64 := image.NewRGBA64(bounds)
+	draw.Draw(rgba64, bounds, img, bounds.Min, draw.Src)
+	parallelFor(bounds.Dy(), 0, func(y int) {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := rgba64.RGBA64At(x, bounds.Min.Y+y)
+			alpha := uint32(pixel.A)
+			if alpha == 0 {
+				continue
+			}
+			channels := [3]uint32{uint32(pixel.R), uint32(pixel.G), uint32(pixel.B)}
+			var linear [3]float64
+			for ch, c := range channels {
+				linear[ch] = p.curves[ch](float64(c) / float64(alpha))
+			}
+			for ch, value := range transform3(toSRGB, linear) {
+				c := uint32(encode[int(min(1, max(0, value))*maxUint16+0.5)])
+				channels[ch] = (c*alpha + maxUint16/2) / maxUint16
+			}
+			pixel.R, pixel.G, pixel.B = uint16(channels[0]), uint16(channels[1]), uint16(channels[2]) // #nosec G115
+			rgba64.SetRGBA64(x, bounds.Min.Y+y, pixel)
+		}
+	})
+	return rgba64
+}
+
+// linearToSRGB16 returns the 16-bit sRGB encoding of every 16-bit linear value.
+func linearToSRGB16() []uint16 {
+	table := make([]uint16, maxUint16+1)
+	for c := range table {
+		table[c] = uint16(linearToSRGB(float32(c)/maxUint16)*maxUint16 + 0.5)
+	}
+	return table
+}
+
+func multiply3(a, b [3][3]float64) [3][3]float64 {
+	var m [3][3]float64
+	for row := range m {
+		for col := range m[row] {
+			for i := range 3 {
+				m[row][col] += a[row][i] * b[i][col]
+			}
+		}
+	}
+	return m
+}
+
+func invert3(m [3][3]float64) [3][3]float64 {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	var inverse [3][3]float64
+	for row := range inverse {
+		for col := range inverse[row] {
+			// The cofactor of the transposed position, from the cyclic
+			// neighbours of the row and column.
+			r1, r2 := (col+1)%3, (col+2)%3
+			c1, c2 := (row+1)%3, (row+2)%3
+			inverse[row][col] = (m[r1][c1]*m[r2][c2] - m[r1][c2]*m[r2][c1]) / det
+		}
+	}
+	return inverse
+}
+
+func transform3(m [3][3]float64, v [3]float64) [3]float64 {
+	var out [3]float64
+	for row := range out {
+		out[row] = m[row][0]*v[0] + m[row][1]*v[1] + m[row][2]*v[2]
+	}
+	return out
+}
@@ -0,0 +1,176 @@
+package processor_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+// loadProfile reads an ICC profile from testdata/icc.
+func loadProfile(t *testing.T, name string) []byte {
+	t.Helper()
+	data, readErr := os.ReadFile(filepath.Join("testdata", "icc", name))
+	require.NoError(t, readErr)
+	return data
+}
+
+// embedJPEGColorProfile returns a copy of a JPEG file with profile split
+// across APP2 segments of at most partSize bytes.
+func embedJPEGColorProfile(data, profile []byte, partSize int) []byte {
+	count := (len(profile) + partSize - 1) / partSize
+	segments := []byte{0xff, 0xd8}
+	for i := range count {
+		part := profile[i*partSize : min(len(profile), (i+1)*partSize)]
+		segments = append(segments, 0xff, 0xe2)
+		segments = binary.BigEndian.AppendUint16(segments, uint16(2+12+2+len(part))) // #nosec G115
+		segments = append(segments, "ICC_PROFILE\x00"...)
+		segments = append(segments, byte(i+1), byte(count))
+		segments = append(segments, part...)
+	}
+	return append(segments, data[2:]...)
+}
+
+func TestParseColorProfile(t *testing.T) {
+	testCases := []struct {
+		file        string
+		description string
+		srgb        bool
+	}{
+		{"display-p3.icc", "Display P3", false},
+		{"adobe-rgb.icc", "Adobe RGB (1998)", false},
+		{"srgb.icc", "sRGB IEC61966-2.1", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.file, func(t *testing.T) {
+			profile, parseErr := processor.ParseColorProfile(loadProfile(t, tc.file))
+
+			require.NoError(t, parseErr)
+			assert.Equal(t, tc.description, profile.Description)
+			assert.Equal(t, tc.srgb, profile.IsSRGB())
+		})
+	}
+}
+
+func TestParseColorProfile_Invalid(t *testing.T) {
+	_, parseErr := processor.ParseColorProfile([]byte("not a profile"))
+	require.Error(t, parseErr)
+	assert.Contains(t, parseErr.Error(), "missing ICC header")
+
+	cmyk := loadProfile(t, "adobe-rgb.icc")
+	copy(cmyk[16:], "CMYK")
+	_, parseErr = processor.ParseColorProfile(cmyk)
+	require.ErrorIs(t, parseErr, processor.ErrUnsupportedProfile)
+
+	truncated := loadProfile(t, "display-p3.icc")[:300]
+	_, parseErr = processor.ParseColorProfile(truncated)
+	require.Error(t, parseErr)
+	assert.Contains(t, parseErr.Error(), "out of bounds")
+}
+
+func TestColorProfile_ConvertToSRGB(t *testing.T) {
+	// The encoding of sRGB 180,90,40 in each color space.
+	testCases := []struct {
+		file    string
+		encoded color.RGBA
+	}{
+		{"display-p3.icc", color.RGBA{R: 168, G: 95, B: 52, A: 255}},
+		{"adobe-rgb.icc", color.RGBA{R: 159, G: 90, B: 47, A: 255}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.file, func(t *testing.T) {
+			profile, parseErr := processor.ParseColorProfile(loadProfile(t, tc.file))
+			require.NoError(t, parseErr)
+
+			converted := profile.ConvertToSRGB(processor.CreateColoredTestImage(4, 4, tc.encoded))
+
+			c := color.RGBAModel.Convert(converted.At(1, 1)).(color.RGBA)
+			assert.InDelta(t, 180, c.R, 2)
+			assert.InDelta(t, 90, c.G, 2)
+			assert.InDelta(t, 40, c.B, 2)
+		})
+	}
+}
+
+func TestColorProfile_ConvertToSRGB_KeepsAlphaAndDepth(t *testing.T) {
+	profile, parseErr := processor.ParseColorProfile(loadProfile(t, "display-p3.icc"))
+	require.NoError(t, parseErr)
+
+	translucent := processor.CreateColoredTestImage(2, 2, color.NRGBA{R: 168, G: 95, B: 52, A: 128})
+	c := color.NRGBAModel.Convert(profile.ConvertToSRGB(translucent).At(0, 0)).(color.NRGBA)
+	assert.Equal(t, uint8(128), c.A)
+	assert.InDelta(t, 180, c.R, 3)
+
+	deep := image.NewNRGBA64(image.Rect(0, 0, 2, 2))
+	deep.SetNRGBA64(0, 0, color.NRGBA64{R: 168 * 257, G: 95 * 257, B: 52 * 257, A: 0xffff})
+	converted := profile.ConvertToSRGB(deep)
+	require.IsType(t, &image.RGBA64{}, converted)
+	r, _, _, _ := converted.At(0, 0).RGBA()
+	assert.InDelta(t, 180*257, r, 2*257)
+}
+
+func TestService_LoadImage_ColorProfile(t *testing.T) {
+	p3 := loadProfile(t, "display-p3.icc")
+	original := processor.CreateColoredTestImage(8, 8, color.RGBA{R: 168, G: 95, B: 52, A: 255})
+	var pngData, jpegData bytes.Buffer
+	require.NoError(t, png.Encode(&pngData, original))
+	require.NoError(t, jpeg.Encode(&jpegData, original, &jpeg.Options{Quality: 100}))
+
+	testCases := []struct {
+		name    string
+		data    []byte
+		profile string
+	}{
+		{"png iCCP", processor.EmbedPNGColorProfile(pngData.Bytes(), p3), "Display P3"},
+		{"jpeg APP2 in parts", embedJPEGColorProfile(jpegData.Bytes(), p3, 100), "Display P3"},
+		{"srgb is kept", processor.EmbedPNGColorProfile(pngData.Bytes(), loadProfile(t, "srgb.icc")), ""},
+		{"untagged", pngData.Bytes(), ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := processor.NewTestMockFileSystem()
+			fs.AddFile("/test/image", tc.data)
+			service, serviceErr := processor.NewServiceWithDeps(fs, processor.NewDefaultDecoderRegistry(),
+				processor.NewTestMockImageEncoder(nil), processor.NewTestMockImageResizer(), processor.DefaultConfig())
+			require.NoError(t, serviceErr)
+
+			procImg, loadErr := service.LoadImage(context.Background(), "/test/image")
+
+			require.NoError(t, loadErr)
+			assert.Equal(t, tc.profile, procImg.ColorProfile)
+			c := color.RGBAModel.Convert(procImg.Original.At(4, 4)).(color.RGBA)
+			if tc.profile == "" {
+				assert.InDelta(t, 168, c.R, 2, "untagged and sRGB images are left as they are")
+			} else {
+				assert.InDelta(t, 180, c.R, 3)
+				assert.InDelta(t, 90, c.G, 3)
+				assert.InDelta(t, 40, c.B, 3)
+			}
+		})
+	}
+}
+
+func TestEmbeddedColorProfile_Truncated(t *testing.T) {
+	p3 := loadProfile(t, "display-p3.icc")
+	var jpegData bytes.Buffer
+	require.NoError(t, jpeg.Encode(&jpegData, processor.CreateTestImage(8, 8), nil))
+	tagged := embedJPEGColorProfile(jpegData.Bytes(), p3, 100)
+
+	assert.Equal(t, p3, processor.EmbeddedColorProfile("jpeg", tagged))
+	assert.Nil(t, processor.EmbeddedColorProfile("jpeg", tagged[:300]), "missing parts")
+	assert.Nil(t, processor.EmbeddedColorProfile("gif", tagged))
+}
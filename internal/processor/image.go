@@ -1,34 +1,101 @@
 package processor
 
 import (
+	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
 )
 
 const (
-	defaultTargetSize = 378
-	defaultGridSize   = 3
-	defaultTileSize   = 116
-	defaultSpacing    = 15
-	centerDivisor     = 2
+	// centerDivisor splits the space left around an area evenly to center it.
+	centerDivisor = 2
+	// half divides a quantity in two, such as when rounding to the nearest
+	// integer.
+	half = 2
 )
 
 // Config holds the processing configuration.
 type Config struct {
+	// TargetSize is the length of the longer side of the canvas the image is
+	// resized and cropped to; the shorter side follows the aspect ratio of the
+	// grid. AutoTargetSize derives it from the grid geometry, which
+	// SpacingContiguous always does.
 	TargetSize int
-	GridSize   int
-	TileSize   int
-	Spacing    int
+	// GridSize is the number of keys per row and per column of a square grid.
+	GridSize int
+	// Columns and Rows override GridSize for rectangular grids when non-zero.
+	Columns int
+	Rows    int
+	// TileSize is the edge length of a key in pixels.
+	TileSize int
+	// Spacing is the gap between keys in pixels.
+	Spacing int
+	// HorizontalSpacing (between columns) and VerticalSpacing (between rows)
+	// override Spacing when non-zero.
+	HorizontalSpacing int
+	VerticalSpacing   int
+	// SpacingMode selects whether the spacing is cut out of the image or the
+	// tiles abut. Empty means SpacingPhysical.
+	SpacingMode SpacingMode
+	// FitMode selects how the image is fitted to the canvas. Empty means FitCover.
+	FitMode FitMode
+	// Fill is the color FitContain pads the image with. The zero value is transparent.
+	Fill color.NRGBA
+	// CropMode selects how the crop window is chosen. Empty means CropGravity;
+	// CropSmart ignores Gravity and FocalPoint.
+	CropMode CropMode
+	// Gravity selects the part of the image kept when it is cropped. Empty means GravityCenter.
+	Gravity Gravity
+	// FocalPoint, when set, centers the crop on a point of the original image,
+	// after Transform, and takes precedence over Gravity.
+	FocalPoint *FocalPoint
+	// SourceRect, when set, is the area of the decoded image, relative to its
+	// top-left corner, that is resized to the canvas as it is, instead of
+	// fitting the whole image with FitMode and the crop settings. Each axis
+	// is scaled independently, so a rectangle whose aspect ratio differs from
+	// CanvasSize is stretched, as with FitStretch, rather than cropped or
+	// padded.
+	SourceRect *image.Rectangle
+	// Transform flips, rotates and zooms the image before it is fitted to
+	// the canvas. The zero value leaves it as it is.
+	Transform Transform
+	// Adjustments are the color adjustments applied, in order, to the canvas
+	// before it is split into tiles, such as a contrast boost for keypad
+	// LCDs that look washed out. The Fill padding of FitContain is kept.
+	Adjustments Adjustments
+	// IgnoreEXIF keeps images as they are stored instead of turning them
+	// upright according to their EXIF orientation.
+	IgnoreEXIF bool
+	// LinearLight resizes and composites images in linear light rather than
+	// on their sRGB values, which keeps high-contrast edges and fine detail
+	// from darkening when large images are scaled down.
+	LinearLight bool
+	// OptimizeGutters shifts and zooms the crop slightly to keep detail out of
+	// the spacing between keys; see OptimizeGutters.
+	OptimizeGutters bool
+	// OutputDir is where tiles are written. Empty means next to the input image.
+	OutputDir string
+	// FileNameTemplate names the tile files, relative to OutputDir; see
+	// ExpandFileNameTemplate. Empty means DefaultFileNameTemplate.
+	FileNameTemplate string
+	// NoClobber refuses to replace existing files: SaveTiles fails before
+	// writing anything when one of the tile files already exists. Files
+	// created by another process while the tiles are encoded are not
+	// detected and are replaced.
+	NoClobber bool
+	// EncodeJobs is the number of tiles SaveTiles encodes at the same time.
+	// 0 means one per CPU.
+	EncodeJobs int
+	// Device is the name of the device profile the geometry comes from,
+	// available to FileNameTemplate as {device}.
+	Device string
 }
 
-// DefaultConfig returns the default processing configuration.
+// DefaultConfig returns the processing configuration of the default device profile.
 func DefaultConfig() Config {
-	return Config{
-		TargetSize: defaultTargetSize,
-		GridSize:   defaultGridSize,
-		TileSize:   defaultTileSize,
-		Spacing:    defaultSpacing,
-	}
+	config, _ := DefaultDeviceProfile().Config() // the built-in profile is always valid
+	return config
 }
 
 // ProcessingResult holds the result of image processing.
@@ -46,59 +113,56 @@ type TileCoordinate struct {
 
 // ResizeImage resizes an image to fit within the target size.
 func ResizeImage(img image.Image, targetSize int, resizer ImageResizer) image.Image {
+	return ResizeToCover(img, targetSize, targetSize, resizer)
+}
+
+// ResizeToCover resizes an image, keeping its aspect ratio, so that it covers
+// a width x height area with as little overflow as possible.
+func ResizeToCover(img image.Image, width, height int, resizer ImageResizer) image.Image {
 	bounds := img.Bounds()
 	origWidth := bounds.Max.X - bounds.Min.X
 	origHeight := bounds.Max.Y - bounds.Min.Y
 
-	targetSizeUint := uint(targetSize) // #nosec G115
-
-	if origWidth > origHeight {
-		return resizer.Resize(0, targetSizeUint, img)
+	// The image is relatively wider than the target when
+	// origWidth/origHeight > width/height, in which case the height is matched.
+	if origWidth*height > origHeight*width {
+		return resizer.Resize(0, uint(height), img) // #nosec G115
 	}
-	return resizer.Resize(targetSizeUint, 0, img)
+	return resizer.Resize(uint(width), 0, img) // #nosec G115
 }
 
 // CropToSquare crops an image to a square centered on the original.
 func CropToSquare(img image.Image, targetSize int) image.Image {
-	bounds := img.Bounds()
-	width := bounds.Max.X - bounds.Min.X
-	height := bounds.Max.Y - bounds.Min.Y
-
-	squared := image.NewRGBA(image.Rect(0, 0, targetSize, targetSize))
-
-	startX := (width - targetSize) / centerDivisor
-	startY := (height - targetSize) / centerDivisor
+	return CropToSize(img, targetSize, targetSize)
+}
 
-	draw.Draw(squared, squared.Bounds(), img, image.Point{
-		X: startX,
-		Y: startY,
-	}, draw.Src)
+// CropToSize crops an image to width x height centered on the original.
+func CropToSize(img image.Image, width, height int) image.Image {
+	return CropAt(img, width, height, GravityOrigin(img.Bounds(), width, height, GravityCenter))
+}
 
-	return squared
+// TileCoordinates returns the position of every tile of the grid, numbered row by row from 1.
+func (c Config) TileCoordinates() []TileCoordinate {
+	coords := make([]TileCoordinate, 0, c.GridColumns()*c.GridRows())
+	for y := range c.GridRows() {
+		for x := range c.GridColumns() {
+			coords = append(coords, TileCoordinate{Row: y, Col: x, Number: len(coords) + 1})
+		}
+	}
+	return coords
 }
 
-// SplitIntoTiles splits a square image into a grid of tiles.
+// SplitIntoTiles splits an image into a grid of tiles, numbered row by row.
 func SplitIntoTiles(img image.Image, config Config) ProcessingResult {
-	var tiles []image.Image
-	var coords []TileCoordinate
-
-	count := 1
-	for y := range config.GridSize {
-		for x := range config.GridSize {
-			srcX := x * (config.TileSize + config.Spacing)
-			srcY := y * (config.TileSize + config.Spacing)
-
-			tile := image.NewRGBA(image.Rect(0, 0, config.TileSize, config.TileSize))
-			draw.Draw(tile, tile.Bounds(), img, image.Point{X: srcX, Y: srcY}, draw.Src)
-
-			tiles = append(tiles, tile)
-			coords = append(coords, TileCoordinate{
-				Row:    y,
-				Col:    x,
-				Number: count,
-			})
-			count++
-		}
+	coords := config.TileCoordinates()
+	tiles := make([]image.Image, 0, len(coords))
+	for _, coord := range coords {
+		srcX := coord.Col * (config.TileSize + config.SpacingX())
+		srcY := coord.Row * (config.TileSize + config.SpacingY())
+
+		tile := image.NewRGBA(image.Rect(0, 0, config.TileSize, config.TileSize))
+		draw.Draw(tile, tile.Bounds(), img, image.Point{X: srcX, Y: srcY}, draw.Src)
+		tiles = append(tiles, tile)
 	}
 
 	return ProcessingResult{
@@ -106,3 +170,30 @@ func SplitIntoTiles(img image.Image, config Config) ProcessingResult {
 		TileCoords: coords,
 	}
 }
+
+// JoinTiles assembles tiles back into a single image laid out like the device,
+// filling the spacing between keys with the background color. Transparent
+// tiles are blended with it in linear light when Config.LinearLight is set.
+func JoinTiles(tiles []image.Image, config Config, background color.Color) (image.Image, error) {
+	columns, rows := config.GridColumns(), config.GridRows()
+	if expected := columns * rows; len(tiles) != expected {
+		return nil, fmt.Errorf("expected %d tiles for a %dx%d grid, got %d", expected, columns, rows, len(tiles))
+	}
+
+	size := config.LayoutSize()
+	joined := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	draw.Draw(joined, joined.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+	for i, tile := range tiles {
+		x := (i % columns) * (config.TileSize + config.SpacingX())
+		y := (i / columns) * (config.TileSize + config.SpacingY())
+		dst := image.Rect(x, y, x+config.TileSize, y+config.TileSize)
+		if config.LinearLight {
+			drawOverLinear(joined, dst, tile, tile.Bounds().Min)
+		} else {
+			draw.Draw(joined, dst, tile, tile.Bounds().Min, draw.Over)
+		}
+	}
+
+	return joined, nil
+}
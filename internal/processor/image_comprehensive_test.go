@@ -1,10 +1,13 @@
 package processor_test
 
 import (
+	"context"
 	"image"
+	"image/color"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
 )
@@ -287,3 +290,178 @@ func TestProcessingResult_Structure(t *testing.T) {
 	assert.Len(t, result.TileCoords, len(result.Tiles),
 		"Tiles and coordinates should have same length")
 }
+
+func TestJoinTiles_RoundTrip(t *testing.T) {
+	// Setup - split a canvas and join the tiles back together
+	config := processor.Config{
+		TargetSize: 200,
+		GridSize:   2,
+		TileSize:   90,
+		Spacing:    10,
+	}
+	testImg := processor.CreateTestImage(config.TargetSize, config.TargetSize)
+	result := processor.SplitIntoTiles(testImg, config)
+	background := color.RGBA{R: 0, G: 0, B: 255, A: 255}
+
+	// Execute
+	joined, joinErr := processor.JoinTiles(result.Tiles, config, background)
+
+	// Assert
+	require.NoError(t, joinErr)
+	assert.Equal(t, image.Rect(0, 0, 190, 190), joined.Bounds())
+	assertSameColor(t, color.RGBA{R: 255, G: 0, B: 0, A: 255}, joined.At(10, 10))
+	assertSameColor(t, color.RGBA{R: 255, G: 0, B: 0, A: 255}, joined.At(150, 150))
+	assertSameColor(t, background, joined.At(95, 10), "spacing should use the background color")
+}
+
+func TestJoinTiles_WrongTileCount(t *testing.T) {
+	config := processor.DefaultConfig()
+	tiles := []image.Image{processor.CreateTestImage(116, 116)}
+
+	joined, joinErr := processor.JoinTiles(tiles, config, color.Black)
+
+	require.Error(t, joinErr)
+	assert.Nil(t, joined)
+	assert.Contains(t, joinErr.Error(), "expected 9 tiles for a 3x3 grid, got 1")
+}
+
+func assertSameColor(t *testing.T, expected, actual color.Color, msgAndArgs ...any) {
+	t.Helper()
+
+	er, eg, eb, ea := expected.RGBA()
+	ar, ag, ab, aa := actual.RGBA()
+	assert.Equal(t, [4]uint32{er, eg, eb, ea}, [4]uint32{ar, ag, ab, aa}, msgAndArgs...)
+}
+
+// createCoordinateImage encodes the x coordinate in red and y in green.
+func createCoordinateImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+	return img
+}
+
+func TestSplitIntoTiles_RectangularGrid(t *testing.T) {
+	// Setup - 5 columns x 3 rows of 20px keys, 5px between columns, 10px between rows
+	config := processor.Config{
+		Columns:           5,
+		Rows:              3,
+		TileSize:          20,
+		HorizontalSpacing: 5,
+		VerticalSpacing:   10,
+	}
+	layout := config.LayoutSize()
+	require.Equal(t, image.Pt(120, 80), layout)
+	testImg := createCoordinateImage(layout.X, layout.Y)
+
+	// Execute
+	result := processor.SplitIntoTiles(testImg, config)
+
+	// Assert - numbered row-major
+	require.Len(t, result.Tiles, 15)
+	assert.Equal(t, processor.TileCoordinate{Row: 0, Col: 4, Number: 5}, result.TileCoords[4])
+	assert.Equal(t, processor.TileCoordinate{Row: 1, Col: 0, Number: 6}, result.TileCoords[5])
+	assert.Equal(t, processor.TileCoordinate{Row: 2, Col: 4, Number: 15}, result.TileCoords[14])
+
+	// Assert - tiles start after the horizontal and vertical gutters
+	for i, coord := range result.TileCoords {
+		tile := result.Tiles[i]
+		assert.Equal(t, image.Rect(0, 0, 20, 20), tile.Bounds())
+		assertSameColor(t, color.RGBA{R: uint8(coord.Col * 25), G: uint8(coord.Row * 30), A: 255}, tile.At(0, 0),
+			"tile %d origin", coord.Number)
+	}
+}
+
+func TestCropToSize(t *testing.T) {
+	testImg := createCoordinateImage(200, 100)
+
+	result := processor.CropToSize(testImg, 120, 60)
+
+	assert.Equal(t, image.Rect(0, 0, 120, 60), result.Bounds())
+	assertSameColor(t, color.RGBA{R: 40, G: 20, A: 255}, result.At(0, 0), "crop should be centered")
+}
+
+func TestCropToSize_OffsetBounds(t *testing.T) {
+	testImg := createCoordinateImage(200, 100).SubImage(image.Rect(100, 0, 200, 100))
+
+	result := processor.CropToSize(testImg, 50, 50)
+
+	assertSameColor(t, color.RGBA{R: 125, G: 25, A: 255}, result.At(0, 0))
+}
+
+func TestResizeToCover(t *testing.T) {
+	testCases := []struct {
+		name           string
+		width, height  int
+		targetW        int
+		targetH        int
+		expectedWidth  uint
+		expectedHeight uint
+	}{
+		{"wider than target matches height", 1000, 400, 456, 264, 0, 264},
+		{"narrower than target matches width", 400, 400, 456, 264, 456, 0},
+		{"same aspect matches width", 912, 528, 456, 264, 456, 0},
+		{"tall target", 300, 300, 100, 200, 0, 200},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var capturedWidth, capturedHeight uint
+			resizer := processor.NewTestMockImageResizer()
+			resizer.ResizeFunc = func(width, height uint, img image.Image) image.Image {
+				capturedWidth, capturedHeight = width, height
+				return img
+			}
+
+			processor.ResizeToCover(processor.CreateTestImage(tc.width, tc.height), tc.targetW, tc.targetH, resizer)
+
+			assert.Equal(t, tc.expectedWidth, capturedWidth)
+			assert.Equal(t, tc.expectedHeight, capturedHeight)
+		})
+	}
+}
+
+func TestJoinTiles_RectangularGrid(t *testing.T) {
+	config := processor.Config{Columns: 3, Rows: 2, TileSize: 10, HorizontalSpacing: 2, VerticalSpacing: 4}
+	tiles := make([]image.Image, 6)
+	for i := range tiles {
+		tiles[i] = processor.CreateTestImage(10, 10)
+	}
+
+	joined, joinErr := processor.JoinTiles(tiles, config, color.Black)
+
+	require.NoError(t, joinErr)
+	assert.Equal(t, image.Rect(0, 0, 34, 24), joined.Bounds())
+	assertSameColor(t, color.Black, joined.At(5, 12), "vertical gutter")
+	assertSameColor(t, color.RGBA{R: 255, A: 255}, joined.At(33, 23))
+}
+
+func TestSplitIntoTiles_ContiguousSpacing(t *testing.T) {
+	config := processor.Config{GridSize: 2, TileSize: 20, Spacing: 10, SpacingMode: processor.SpacingContiguous}
+	testImg := createCoordinateImage(40, 40)
+
+	result := processor.SplitIntoTiles(testImg, config)
+
+	require.Len(t, result.Tiles, 4)
+	assertSameColor(t, color.RGBA{R: 20, G: 0, A: 255}, result.Tiles[1].At(0, 0), "second tile starts right after the first")
+	assertSameColor(t, color.RGBA{R: 20, G: 20, A: 255}, result.Tiles[3].At(0, 0))
+	assertSameColor(t, color.RGBA{R: 39, G: 39, A: 255}, result.Tiles[3].At(19, 19), "every pixel is used")
+}
+
+func TestService_ProcessImageData_ContiguousSpacing(t *testing.T) {
+	config := processor.DefaultConfig()
+	config.SpacingMode = processor.SpacingContiguous
+	config.TargetSize = processor.AutoTargetSize
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, processor.NewTestMockImageResizer(), config)
+	require.NoError(t, serviceErr)
+
+	result, processErr := service.ProcessImageData(context.Background(),
+		&processor.ProcessedImage{Original: processor.CreateTestImage(400, 400)})
+	require.NoError(t, processErr)
+
+	assert.Equal(t, image.Rect(0, 0, 348, 348), result.Squared.Bounds())
+	assert.Len(t, result.Result.Tiles, 9)
+}
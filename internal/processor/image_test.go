@@ -17,6 +17,7 @@ func TestDefaultConfig(t *testing.T) {
 		GridSize:   3,
 		TileSize:   116,
 		Spacing:    15,
+		Device:     processor.DefaultDeviceName,
 	}
 
 	assert.Equal(t, expectedConfig, config)
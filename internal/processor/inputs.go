@@ -0,0 +1,240 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrOutputCollision is returned when several inputs would write the same tile files.
+var ErrOutputCollision = errors.New("inputs write the same tiles")
+
+// ExpandInputs turns image paths, glob patterns and directories into the
+// list of images to process, in the order given and without duplicates.
+// Directories contribute the image files they contain, and those of their
+// subdirectories when recursive is set; glob matches are filtered the same
+// way. Files found this way that are tiles SaveTiles would write for another
+// input are skipped, so that splitting a folder twice does not split the
+// tiles of the first run. Plain paths are returned as given, even when they
+// do not exist, so that the error is reported when the image is loaded.
+func (s *Service) ExpandInputs(args []string, recursive bool) ([]string, error) {
+	var inputs []string
+	discovered := map[string]bool{}
+	for _, arg := range args {
+		if !hasGlobMeta(arg) {
+			info, statErr := s.fileSystem.Stat(arg)
+			if statErr != nil || !info.IsDir() {
+				inputs = append(inputs, arg)
+				continue
+			}
+			files, walkErr := s.imagesIn(arg, recursive)
+			if walkErr != nil {
+				return nil, walkErr
+			}
+			if len(files) == 0 {
+				return nil, fmt.Errorf("no images found in %s", arg)
+			}
+			inputs = appendDiscovered(inputs, discovered, files)
+			continue
+		}
+
+		matches, globErr := s.glob(arg)
+		if globErr != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", arg, globErr)
+		}
+		var files []string
+		for _, match := range matches {
+			info, statErr := s.fileSystem.Stat(match)
+			switch {
+			case statErr != nil:
+				continue
+			case info.IsDir():
+				dirFiles, walkErr := s.imagesIn(match, recursive)
+				if walkErr != nil {
+					return nil, walkErr
+				}
+				files = append(files, dirFiles...)
+			case isImageFile(match):
+				files = append(files, match)
+			}
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no images match %s", arg)
+		}
+		inputs = appendDiscovered(inputs, discovered, files)
+	}
+
+	return s.withoutOutputs(dedupe(inputs), discovered), nil
+}
+
+// CheckOutputCollisions returns an error naming the inputs whose tiles would
+// be written to the same files, such as two images with the same base name in
+// different directories split to one --out-dir, which cannot be written at
+// the same time without mixing their tiles.
+func (s *Service) CheckOutputCollisions(inputs []string) error {
+	owners := map[string]string{}
+	reported := map[[2]string]bool{}
+	var errs []error
+	for _, input := range inputs {
+		for _, coord := range s.config.TileCoordinates() {
+			path := s.TileOutputPath(input, coord)
+			owner, taken := owners[path]
+			if !taken {
+				owners[path] = input
+				continue
+			}
+			if pair := [2]string{owner, input}; owner != input && !reported[pair] {
+				reported[pair] = true
+				errs = append(errs, fmt.Errorf("%s and %s would both write %s", owner, input, path))
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrOutputCollision, errors.Join(errs...))
+}
+
+func appendDiscovered(inputs []string, discovered map[string]bool, files []string) []string {
+	for _, file := range files {
+		discovered[file] = true
+	}
+	return append(inputs, files...)
+}
+
+// dedupe removes repeated paths, keeping the first occurrence.
+func dedupe(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	unique := paths[:0]
+	for _, path := range paths {
+		if !seen[path] {
+			seen[path] = true
+			unique = append(unique, path)
+		}
+	}
+	return unique
+}
+
+// withoutOutputs drops the discovered inputs that are tiles of another input.
+func (s *Service) withoutOutputs(inputs []string, discovered map[string]bool) []string {
+	outputs := map[string]bool{}
+	for _, input := range inputs {
+		for _, coord := range s.config.TileCoordinates() {
+			outputs[s.TileOutputPath(input, coord)] = true
+		}
+	}
+
+	kept := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		if !discovered[input] || !outputs[input] {
+			kept = append(kept, input)
+		}
+	}
+	return kept
+}
+
+// imagesIn returns the image files in dir, sorted by name, skipping hidden
+// entries such as the temporary files of SaveTiles.
+func (s *Service) imagesIn(dir string, recursive bool) ([]string, error) {
+	entries, readErr := s.fileSystem.ReadDir(dir)
+	if readErr != nil {
+		return nil, fmt.Errorf("error reading directory: %w", readErr)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case strings.HasPrefix(entry.Name(), "."):
+			continue
+		case entry.IsDir():
+			if !recursive {
+				continue
+			}
+			nested, nestedErr := s.imagesIn(path, recursive)
+			if nestedErr != nil {
+				return nil, nestedErr
+			}
+			files = append(files, nested...)
+		case isImageFile(path):
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// glob returns the paths matching pattern like filepath.Glob, but through
+// the service file system. Like a shell, '*' does not match a leading dot.
+func (s *Service) glob(pattern string) ([]string, error) {
+	if _, matchErr := filepath.Match(pattern, ""); matchErr != nil {
+		return nil, matchErr
+	}
+
+	dir, file := filepath.Split(pattern)
+	dir = cleanGlobDir(dir)
+	if !hasGlobMeta(dir) {
+		return s.globDir(dir, file), nil
+	}
+	if dir == pattern {
+		return nil, filepath.ErrBadPattern
+	}
+
+	dirs, globErr := s.glob(dir)
+	if globErr != nil {
+		return nil, globErr
+	}
+	var matches []string
+	for _, d := range dirs {
+		matches = append(matches, s.globDir(d, file)...)
+	}
+	return matches, nil
+}
+
+// globDir returns the entries of dir matching pattern. Unreadable
+// directories match nothing, as with filepath.Glob.
+func (s *Service) globDir(dir, pattern string) []string {
+	entries, readErr := s.fileSystem.ReadDir(dir)
+	if readErr != nil {
+		return nil
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(pattern, ".") {
+			continue
+		}
+		if matched, _ := filepath.Match(pattern, name); matched {
+			matches = append(matches, filepath.Join(dir, name))
+		}
+	}
+	return matches
+}
+
+func cleanGlobDir(dir string) string {
+	switch dir {
+	case "":
+		return "."
+	case string(filepath.Separator):
+		return dir
+	default:
+		return strings.TrimSuffix(dir, string(filepath.Separator))
+	}
+}
+
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// isImageFile reports whether path has the extension of a DefaultFormats format.
+func isImageFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, format := range DefaultFormats() {
+		if slices.Contains(format.Extensions, ext) {
+			return true
+		}
+	}
+	return false
+}
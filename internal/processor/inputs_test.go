@@ -0,0 +1,105 @@
+package processor_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func newInputsTestService(t *testing.T, files ...string) *processor.Service {
+	t.Helper()
+
+	fs := processor.NewTestMockFileSystem()
+	for _, file := range files {
+		fs.AddFile(file, []byte("data"))
+	}
+	service, serviceErr := processor.NewServiceWithDeps(fs, nil, processor.NewTestMockImageEncoder(nil),
+		processor.NewTestMockImageResizer(), processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+	return service
+}
+
+func TestService_ExpandInputs(t *testing.T) {
+	service := newInputsTestService(t,
+		"/art/b.png", "/art/a.JPG", "/art/notes.txt", "/art/.hidden.png",
+		"/art/.a.jpg.png.tmp", "/art/nested/c.webp", "/art/nested/deep/d.gif",
+	)
+
+	testCases := []struct {
+		name      string
+		args      []string
+		recursive bool
+		expected  []string
+	}{
+		{"plain paths are kept", []string{"/art/b.png", "/missing.jpg"}, false, []string{"/art/b.png", "/missing.jpg"}},
+		{"directory", []string{"/art"}, false, []string{"/art/a.JPG", "/art/b.png"}},
+		{"recursive directory", []string{"/art"}, true,
+			[]string{"/art/a.JPG", "/art/b.png", "/art/nested/c.webp", "/art/nested/deep/d.gif"}},
+		{"glob", []string{"/art/*.png"}, false, []string{"/art/b.png"}},
+		{"glob in directory part", []string{"/art/*/c.*"}, false, []string{"/art/nested/c.webp"}},
+		{"glob matching directories", []string{"/art/n*"}, true, []string{"/art/nested/c.webp", "/art/nested/deep/d.gif"}},
+		{"duplicates are removed", []string{"/art/b.png", "/art", "/art/*.png"}, false, []string{"/art/b.png", "/art/a.JPG"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inputs, expandErr := service.ExpandInputs(tc.args, tc.recursive)
+
+			require.NoError(t, expandErr)
+			assert.Equal(t, tc.expected, inputs)
+		})
+	}
+}
+
+func TestService_ExpandInputs_SkipsTilesOfOtherInputs(t *testing.T) {
+	service := newInputsTestService(t, "/art/photo.jpg", "/art/photo_1.png", "/art/photo_9.png", "/art/logo.png")
+
+	inputs, expandErr := service.ExpandInputs([]string{"/art"}, false)
+
+	require.NoError(t, expandErr)
+	assert.Equal(t, []string{"/art/logo.png", "/art/photo.jpg"}, inputs)
+}
+
+func TestService_ExpandInputs_Errors(t *testing.T) {
+	service := newInputsTestService(t, "/art/notes.txt")
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"empty directory", []string{"/art"}, "no images found in /art"},
+		{"no match", []string{"/art/*.png"}, "no images match /art/*.png"},
+		{"bad pattern", []string{"/art/[.png"}, `invalid pattern "/art/[.png"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, expandErr := service.ExpandInputs(tc.args, false)
+
+			require.Error(t, expandErr)
+			assert.Contains(t, expandErr.Error(), tc.expected)
+		})
+	}
+}
+
+func TestService_CheckOutputCollisions(t *testing.T) {
+	config := processor.DefaultConfig()
+	config.OutputDir = "/out"
+	service, serviceErr := processor.NewServiceWithDeps(processor.NewTestMockFileSystem(), nil,
+		processor.NewTestMockImageEncoder(nil), processor.NewTestMockImageResizer(), config)
+	require.NoError(t, serviceErr)
+
+	require.NoError(t, service.CheckOutputCollisions([]string{"/one/a.png", "/one/b.png"}))
+
+	collisionErr := service.CheckOutputCollisions([]string{"/one/a.png", "/two/a.png", "/two/a.jpg", "/one/b.png"})
+
+	require.ErrorIs(t, collisionErr, processor.ErrOutputCollision)
+	assert.Contains(t, collisionErr.Error(), "/one/a.png and /two/a.png would both write /out/a_1.png")
+	assert.Contains(t, collisionErr.Error(), "/one/a.png and /two/a.jpg would both write /out/a_1.png")
+	assert.NotContains(t, collisionErr.Error(), "a_2.png", "every pair is reported once")
+	assert.NotContains(t, collisionErr.Error(), "b.png")
+}
@@ -3,12 +3,18 @@ package processor
 import (
 	"image"
 	"io"
+	"io/fs"
 )
 
 // FileSystem abstracts file system operations for testing.
 type FileSystem interface {
 	Open(name string) (io.ReadCloser, error)
 	Create(name string) (io.WriteCloser, error)
+	MkdirAll(path string, perm fs.FileMode) error
+	Rename(oldPath, newPath string) error
+	Remove(name string) error
+	Stat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
 }
 
 // ImageDecoder abstracts image decoding operations.
@@ -21,6 +27,13 @@ type ImageEncoder interface {
 	Encode(w io.Writer, img image.Image) error
 }
 
+// ExtensionEncoder is implemented by encoders that know the file extension
+// of the images they write.
+type ExtensionEncoder interface {
+	ImageEncoder
+	Extension() string
+}
+
 // ImageResizer abstracts image resizing operations.
 type ImageResizer interface {
 	Resize(width, height uint, img image.Image) image.Image
@@ -0,0 +1,109 @@
+package processor
+
+import (
+	"image"
+	"image/color"
+)
+
+// LinearLightResizer is implemented by resizers that can resample in linear
+// light themselves, which LinearLight prefers to converting the image.
+type LinearLightResizer interface {
+	ImageResizer
+	ResizeLinear(width, height uint, img image.Image) image.Image
+}
+
+// LinearLight returns a resizer that resamples sRGB images in linear light
+// with resizer, using its ResizeLinear when it implements LinearLightResizer.
+// Other resizers are given a linear 16-bit copy of the image, and their
+// result is encoded back to sRGB.
+func LinearLight(resizer ImageResizer) ImageResizer {
+	return &linearLightResizer{resizer: resizer}
+}
+
+type linearLightResizer struct {
+	resizer ImageResizer
+}
+
+func (r *linearLightResizer) Resize(width, height uint, img image.Image) image.Image {
+	if linear, ok := r.resizer.(LinearLightResizer); ok {
+		return linear.ResizeLinear(width, height, img)
+	}
+	return r.throughLinear(img, func(linear image.Image) image.Image {
+		return r.resizer.Resize(width, height, linear)
+	})
+}
+
+// ResizeInside resamples in linear light with the ResizeInside of the
+// resizer when it implements InsideResizer, and like Resize otherwise.
+func (r *linearLightResizer) ResizeInside(width, height uint, img image.Image) image.Image {
+	inside, ok := r.resizer.(InsideResizer)
+	if !ok {
+		return r.Resize(width, height, img)
+	}
+	return r.throughLinear(img, func(linear image.Image) image.Image {
+		return inside.ResizeInside(width, height, linear)
+	})
+}
+
+// throughLinear gives resize a linear 16-bit copy of img and encodes its
+// result back to sRGB.
+func (r *linearLightResizer) throughLinear(img image.Image, resize func(linear image.Image) image.Image) image.Image {
+	source := newPixelSource(img, true)
+	bounds := img.Bounds()
+	linear := image.NewRGBA64(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	parallelFor(bounds.Dy(), 0, func(y int) {
+		line := make([]float32, bounds.Dx()*rgbaChannels)
+		source.row(y, line)
+		pix := linear.Pix[y*linear.Stride:]
+		for i, value := range line {
+			v := uint16(value*maxUint16 + 0.5)
+			pix[2*i], pix[2*i+1] = uint8(v>>8), uint8(v) // #nosec G115
+		}
+	})
+
+	resized := resize(linear)
+	size := resized.Bounds().Size()
+	values := make([]float32, size.X*size.Y*rgbaChannels)
+	encoded := newPixelSource(resized, false)
+	parallelFor(size.Y, 0, func(y int) {
+		encoded.row(y, values[y*size.X*rgbaChannels:(y+1)*size.X*rgbaChannels])
+	})
+	return storePixels(values, size.X, size.Y, source.deep, true, 0)
+}
+
+// drawOverLinear draws src over the r area of dst, like draw.Draw with
+// draw.Over, but blends the colors in linear light.
+func drawOverLinear(dst *image.RGBA, r image.Rectangle, src image.Image, sp image.Point) {
+	clipped := r.Intersect(dst.Bounds())
+	sp = sp.Add(clipped.Min.Sub(r.Min))
+	decode := srgbToLinear8()
+
+	for y := clipped.Min.Y; y < clipped.Max.Y; y++ {
+		for x := clipped.Min.X; x < clipped.Max.X; x++ {
+			sr, sg, sb, sa := src.At(sp.X+x-clipped.Min.X, sp.Y+y-clipped.Min.Y).RGBA()
+			switch sa {
+			case 0:
+				continue
+			case maxUint16:
+				dst.SetRGBA(x, y, color.RGBA{R: uint8(sr >> 8), G: uint8(sg >> 8), B: uint8(sb >> 8), A: maxUint8})
+				continue
+			}
+
+			d := dst.RGBAAt(x, y)
+			srcAlpha := float32(sa) / maxUint16
+			dstAlpha := float32(d.A) / maxUint8 * (1 - srcAlpha)
+			alpha := srcAlpha + dstAlpha
+			backdrop := [3]uint8{d.R, d.G, d.B}
+			var out [3]uint8
+			for ch, c := range [3]uint32{sr, sg, sb} {
+				value := srgbToLinear(float32(c)/float32(sa)) * srcAlpha
+				if d.A > 0 {
+					straight := (uint32(backdrop[ch])*maxUint8 + uint32(d.A)/2) / uint32(d.A)
+					value += decode[min(maxUint8, straight)] * dstAlpha
+				}
+				out[ch] = uint8(linearToSRGB(min(1, value/alpha))*alpha*maxUint8 + 0.5)
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: out[0], G: out[1], B: out[2], A: uint8(alpha*maxUint8 + 0.5)})
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package processor_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+// createStripes returns alternating black and white columns, which average
+// to mid grey in linear light: 188 in sRGB rather than 128.
+func createStripes(width, height int) image.Image {
+	stripes := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			if x%2 == 0 {
+				stripes.SetRGBA(x, y, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+			} else {
+				stripes.SetRGBA(x, y, color.RGBA{A: 255})
+			}
+		}
+	}
+	return stripes
+}
+
+func TestLinearLight(t *testing.T) {
+	testCases := []struct {
+		name    string
+		resizer processor.ImageResizer
+	}{
+		{"lanczos", &processor.LanczosResizer{}},
+		{"resample", &processor.ResampleResizer{Resample: processor.ResampleBilinear}},
+		// Hiding ResizeLinear makes LinearLight convert the image itself.
+		{"other resizer", struct{ processor.ImageResizer }{&processor.ConvolutionResizer{Filter: processor.LinearFilter()}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stripes := createStripes(64, 8)
+
+			plain := tc.resizer.Resize(16, 0, stripes)
+			linear := processor.LinearLight(tc.resizer).Resize(16, 0, stripes)
+
+			assert.Equal(t, image.Rect(0, 0, 16, 2), linear.Bounds())
+			assert.InDelta(t, 128, color.RGBAModel.Convert(plain.At(8, 1)).(color.RGBA).R, 2)
+			assert.InDelta(t, 188, color.RGBAModel.Convert(linear.At(8, 1)).(color.RGBA).R, 2)
+		})
+	}
+}
+
+func TestFitToCanvas_LinearLightPadding(t *testing.T) {
+	halfWhite := processor.CreateColoredTestImage(10, 10, color.NRGBA{R: 255, G: 255, B: 255, A: 128})
+	config := processor.Config{FitMode: processor.FitContain, Fill: color.NRGBA{A: 255}}
+	resizer := &processor.LanczosResizer{}
+
+	_, plain, _ := processor.FitToCanvas(halfWhite, 20, 10, config, resizer)
+	config.LinearLight = true
+	_, linear, _ := processor.FitToCanvas(halfWhite, 20, 10, config, resizer)
+
+	assertSameColor(t, color.RGBA{R: 128, G: 128, B: 128, A: 255}, plain.At(10, 5), "blended on sRGB values")
+	assertSameColor(t, color.RGBA{R: 188, G: 188, B: 188, A: 255}, linear.At(10, 5), "blended in linear light")
+	assertSameColor(t, color.RGBA{A: 255}, linear.At(1, 5), "padding")
+}
+
+func TestJoinTiles_LinearLight(t *testing.T) {
+	config := processor.Config{GridSize: 1, TileSize: 4, LinearLight: true}
+	tile := processor.CreateColoredTestImage(4, 4, color.NRGBA{R: 255, A: 128})
+
+	joined, joinErr := processor.JoinTiles([]image.Image{tile}, config, color.NRGBA{B: 255, A: 255})
+
+	assert.NoError(t, joinErr)
+	assertSameColor(t, color.RGBA{R: 188, B: 187, A: 255}, joined.At(2, 2), "red over blue in linear light")
+}
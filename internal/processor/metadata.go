@@ -0,0 +1,251 @@
+package processor
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"slices"
+)
+
+const (
+	// maxMetadataSize is how much of the start of an image file LoadImage
+	// keeps for its metadata, which the formats store before the pixels.
+	maxMetadataSize = 1 << 20
+	// maxProfileSize bounds the size of a decompressed PNG color profile.
+	maxProfileSize = 4 << 20
+
+	pngSignatureSize   = 8
+	pngChunkHeaderSize = 8
+	pngChunkCRCSize    = 4
+	jpegMarkerSize     = 2
+	jpegSegmentHeader  = 4
+	jpegMarkerPrefix   = 0xff
+	jpegSOI            = 0xd8
+	jpegSOS            = 0xda
+	jpegEOI            = 0xd9
+	jpegAPP1           = 0xe1
+	jpegAPP2           = 0xe2
+	iccChunkHeaderSize = 2
+	tiffHeaderSize     = 8
+	tiffMagic          = 42
+	tiffEntrySize      = 12
+	tiffShort          = 3
+	exifOrientationTag = 0x0112
+	// jpegICCSignature starts the APP2 segments that carry an ICC profile.
+	jpegICCSignature = "ICC_PROFILE\x00"
+	// exifSignature starts the APP1 segment that carries the EXIF metadata,
+	// and sometimes the EXIF chunk of WebP images.
+	exifSignature = "Exif\x00\x00"
+)
+
+// headerRecorder keeps the first limit bytes written to it, so that the
+// metadata of an image can be read after it has been decoded.
+type headerRecorder struct {
+	data  []byte
+	limit int
+}
+
+func (h *headerRecorder) Write(p []byte) (int, error) {
+	if room := h.limit - len(h.data); room > 0 {
+		h.data = append(h.data, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// EmbeddedColorProfile returns the ICC profile embedded in the start of an
+// image file of the given format, from the iCCP chunk of a PNG image or the
+// APP2 segments of a JPEG image. It returns nil when there is none or it is
+// truncated.
+func EmbeddedColorProfile(format string, data []byte) []byte {
+	switch format {
+	case "png":
+		return pngColorProfile(data)
+	case "jpeg":
+		return jpegColorProfile(data)
+	default:
+		return nil
+	}
+}
+
+// EmbeddedOrientation returns the EXIF orientation stored in the start of an
+// image file of the given format: in the APP1 segment of a JPEG image, the
+// first directory of a TIFF image, the EXIF chunk of a WebP image or the eXIf
+// chunk of a PNG image. It returns 0 when there is none, and for TIFF images
+// whose first directory comes after the pixels.
+func EmbeddedOrientation(format string, data []byte) Orientation {
+	var exif []byte
+	switch format {
+	case "jpeg":
+		for _, segment := range jpegSegments(data) {
+			if segment.marker == jpegAPP1 && bytes.HasPrefix(segment.data, []byte(exifSignature)) {
+				exif = segment.data[len(exifSignature):]
+				break
+			}
+		}
+	case "tiff":
+		exif = data
+	case "webp":
+		exif = bytes.TrimPrefix(riffChunk(data, "EXIF"), []byte(exifSignature))
+	case "png":
+		exif = pngChunk(data, "eXIf")
+	}
+	return tiffOrientation(exif)
+}
+
+// tiffOrientation returns the Orientation entry of the first directory of
+// TIFF structured data, or 0 when it has none.
+func tiffOrientation(data []byte) Orientation {
+	if len(data) < tiffHeaderSize {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	if order.Uint16(data[2:]) != tiffMagic {
+		return 0
+	}
+
+	directory := int(order.Uint32(data[4:]))
+	if directory < tiffHeaderSize || directory > len(data)-2 {
+		return 0
+	}
+	count := int(order.Uint16(data[directory:]))
+	entries := data[directory+2:]
+	for i := range min(count, len(entries)/tiffEntrySize) {
+		entry := entries[i*tiffEntrySize:]
+		if order.Uint16(entry) != exifOrientationTag || order.Uint16(entry[2:]) != tiffShort {
+			continue
+		}
+		// A single SHORT value is stored at the start of the value field.
+		if orientation := Orientation(order.Uint16(entry[8:])); orientation.Transforms() {
+			return orientation
+		}
+		return OrientationNormal
+	}
+	return 0
+}
+
+// pngChunk returns the data of the first chunk of the given kind that comes
+// before the image data, or nil when there is none.
+func pngChunk(data []byte, kind string) []byte {
+	if len(data) < pngSignatureSize {
+		return nil
+	}
+	for rest := data[pngSignatureSize:]; len(rest) >= pngChunkHeaderSize; {
+		length := int(binary.BigEndian.Uint32(rest))
+		chunkKind := string(rest[4:pngChunkHeaderSize])
+		if chunkKind == "IDAT" || chunkKind == "IEND" || length > len(rest)-pngChunkHeaderSize-pngChunkCRCSize {
+			return nil
+		}
+		if chunkKind == kind {
+			return rest[pngChunkHeaderSize : pngChunkHeaderSize+length]
+		}
+		rest = rest[pngChunkHeaderSize+length+pngChunkCRCSize:]
+	}
+	return nil
+}
+
+// pngColorProfile returns the decompressed profile of the iCCP chunk.
+func pngColorProfile(data []byte) []byte {
+	chunk := pngChunk(data, "iCCP")
+
+	// The profile name is followed by a NUL, the compression method, 0 for
+	// zlib, and the compressed profile.
+	nameEnd := bytes.IndexByte(chunk, 0)
+	if nameEnd < 0 || nameEnd+2 > len(chunk) || chunk[nameEnd+1] != 0 {
+		return nil
+	}
+	zr, zlibErr := zlib.NewReader(bytes.NewReader(chunk[nameEnd+2:]))
+	if zlibErr != nil {
+		return nil
+	}
+	profile, readErr := io.ReadAll(io.LimitReader(zr, maxProfileSize))
+	if readErr != nil {
+		return nil
+	}
+	return profile
+}
+
+// jpegSegment is a marker segment of a JPEG file.
+type jpegSegment struct {
+	marker byte
+	data   []byte
+}
+
+// jpegSegments returns the segments that precede the scan, up to the first
+// truncated one.
+func jpegSegments(data []byte) []jpegSegment {
+	if len(data) < jpegMarkerSize || data[0] != jpegMarkerPrefix || data[1] != jpegSOI {
+		return nil
+	}
+
+	var segments []jpegSegment
+	for rest := data[jpegMarkerSize:]; len(rest) >= jpegSegmentHeader && rest[0] == jpegMarkerPrefix; {
+		marker := rest[1]
+		if marker == jpegSOS || marker == jpegEOI {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(rest[2:]))
+		if length < jpegMarkerSize || length > len(rest)-jpegMarkerSize {
+			break
+		}
+		segments = append(segments, jpegSegment{marker: marker, data: rest[jpegSegmentHeader : jpegMarkerSize+length]})
+		rest = rest[jpegMarkerSize+length:]
+	}
+	return segments
+}
+
+// jpegColorProfile returns the profile stored across the APP2 segments, each
+// holding a numbered part of it.
+func jpegColorProfile(data []byte) []byte {
+	var parts [][]byte
+	for _, segment := range jpegSegments(data) {
+		if segment.marker != jpegAPP2 || !bytes.HasPrefix(segment.data, []byte(jpegICCSignature)) {
+			continue
+		}
+		part := segment.data[len(jpegICCSignature):]
+		if len(part) < iccChunkHeaderSize {
+			return nil
+		}
+		sequence, count := int(part[0]), int(part[1])
+		if parts == nil {
+			parts = make([][]byte, count)
+		}
+		if sequence < 1 || sequence > len(parts) || count != len(parts) {
+			return nil
+		}
+		parts[sequence-1] = part[iccChunkHeaderSize:]
+	}
+
+	if parts == nil || slices.ContainsFunc(parts, func(part []byte) bool { return part == nil }) {
+		return nil
+	}
+	return bytes.Join(parts, nil)
+}
+
+// riffChunk returns the data of the first chunk of a RIFF file, such as a
+// WebP image, with the given four character code, or nil when there is none.
+func riffChunk(data []byte, fourCC string) []byte {
+	if len(data) < riffHeaderSize {
+		return nil
+	}
+	for rest := data[riffHeaderSize:]; len(rest) >= riffChunkHeaderSize; {
+		length := int(binary.LittleEndian.Uint32(rest[4:]))
+		if length > len(rest)-riffChunkHeaderSize {
+			return nil
+		}
+		if string(rest[:4]) == fourCC {
+			return rest[riffChunkHeaderSize : riffChunkHeaderSize+length]
+		}
+		// Chunks are padded to an even length.
+		rest = rest[min(len(rest), riffChunkHeaderSize+length+length%2):]
+	}
+	return nil
+}
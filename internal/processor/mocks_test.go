@@ -4,6 +4,7 @@ import (
 	"errors"
 	"image"
 	"io"
+	"io/fs"
 
 	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
 )
@@ -60,6 +61,39 @@ func (m *MockFileSystem) Create(name string) (io.WriteCloser, error) {
 	}, nil
 }
 
+// MkdirAll implements processor.FileSystem.
+func (m *MockFileSystem) MkdirAll(_ string, _ fs.FileMode) error {
+	return nil
+}
+
+// Rename implements processor.FileSystem.
+func (m *MockFileSystem) Rename(oldPath, newPath string) error {
+	content, exists := m.written[oldPath]
+	if !exists {
+		return fs.ErrNotExist
+	}
+	delete(m.written, oldPath)
+	m.written[newPath] = content
+	return nil
+}
+
+// Remove implements processor.FileSystem.
+func (m *MockFileSystem) Remove(name string) error {
+	delete(m.written, name)
+	delete(m.files, name)
+	return nil
+}
+
+// ReadDir implements processor.FileSystem.
+func (m *MockFileSystem) ReadDir(_ string) ([]fs.DirEntry, error) {
+	return nil, fs.ErrNotExist
+}
+
+// Stat implements processor.FileSystem.
+func (m *MockFileSystem) Stat(_ string) (fs.FileInfo, error) {
+	return nil, fs.ErrNotExist
+}
+
 type mockReadCloser struct {
 	content []byte
 	pos     int
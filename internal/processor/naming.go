@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultFileNameTemplate names tiles <name>_<n>.<ext> next to the input image.
+const DefaultFileNameTemplate = "{name}_{n}.{ext}"
+
+// customDeviceName fills {device} when the configuration is not tied to a device profile.
+const customDeviceName = "custom"
+
+// fileNamePlaceholders returns the placeholders a file name template may use.
+func fileNamePlaceholders() []string {
+	return []string{"name", "n", "row", "col", "device", "ext"}
+}
+
+// TileFileName holds the values substituted into a file name template.
+type TileFileName struct {
+	// Name is the input file name without its extension.
+	Name string
+	// Coord is the tile position; {row} and {col} are 1-based.
+	Coord TileCoordinate
+	// Device is the device profile name.
+	Device string
+	// Ext is the output file extension without the dot.
+	Ext string
+}
+
+// ExpandFileNameTemplate substitutes {name}, {n}, {row}, {col}, {device} and
+// {ext} in template. {row} and {col} count from 1 like {n}.
+func ExpandFileNameTemplate(template string, values TileFileName) (string, error) {
+	replacements := map[string]string{
+		"name":   values.Name,
+		"n":      strconv.Itoa(values.Coord.Number),
+		"row":    strconv.Itoa(values.Coord.Row + 1),
+		"col":    strconv.Itoa(values.Coord.Col + 1),
+		"device": values.Device,
+		"ext":    values.Ext,
+	}
+
+	var b strings.Builder
+	rest := template
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			b.WriteString(rest)
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("file name template %q: unclosed placeholder", template)
+		}
+		key := rest[start+1 : start+end]
+		value, known := replacements[key]
+		if !known {
+			return "", fmt.Errorf("file name template %q: unknown placeholder {%s}, supported are: {%s}",
+				template, key, strings.Join(fileNamePlaceholders(), "}, {"))
+		}
+		b.WriteString(rest[:start])
+		b.WriteString(value)
+		rest = rest[start+end+1:]
+	}
+
+	return b.String(), nil
+}
+
+// validateFileNameTemplate checks that a template expands and gives every
+// tile of a grid its own file.
+func validateFileNameTemplate(template string) error {
+	if _, expandErr := ExpandFileNameTemplate(template, TileFileName{}); expandErr != nil {
+		return expandErr
+	}
+	if !strings.Contains(template, "{n}") && !(strings.Contains(template, "{row}") && strings.Contains(template, "{col}")) {
+		return errors.New("must contain {n} or both {row} and {col} so that every tile gets its own file")
+	}
+	return nil
+}
+
+// TileOutputPath returns the path a tile of originalPath is written to: the
+// FileNameTemplate expanded in OutputDir, or next to the input image when
+// OutputDir is empty. Absolute templates ignore both.
+func (s *Service) TileOutputPath(originalPath string, coord TileCoordinate) string {
+	template := s.config.FileNameTemplate
+	if template == "" {
+		template = DefaultFileNameTemplate
+	}
+	device := s.config.Device
+	if device == "" {
+		device = customDeviceName
+	}
+
+	// The template was checked by Config.Validate, so expansion cannot fail.
+	fileName, _ := ExpandFileNameTemplate(template, TileFileName{
+		Name:   strings.TrimSuffix(filepath.Base(originalPath), filepath.Ext(originalPath)),
+		Coord:  coord,
+		Device: device,
+		Ext:    s.OutputExtension(),
+	})
+	if filepath.IsAbs(fileName) {
+		return filepath.Clean(fileName)
+	}
+
+	baseDir := filepath.Dir(originalPath)
+	if s.config.OutputDir != "" {
+		baseDir = s.config.OutputDir
+	}
+	return filepath.Join(baseDir, fileName)
+}
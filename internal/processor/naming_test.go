@@ -0,0 +1,155 @@
+package processor_test
+
+import (
+	"context"
+	"errors"
+	"image"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestExpandFileNameTemplate(t *testing.T) {
+	values := processor.TileFileName{
+		Name:   "sunset",
+		Coord:  processor.TileCoordinate{Row: 1, Col: 2, Number: 6},
+		Device: "mx-creative-console",
+		Ext:    "png",
+	}
+
+	testCases := []struct {
+		template string
+		expected string
+	}{
+		{processor.DefaultFileNameTemplate, "sunset_6.png"},
+		{"keys/{name}/r{row}c{col}.png", "keys/sunset/r2c3.png"},
+		{"{device}/{n}.{ext}", "mx-creative-console/6.png"},
+		{"static.png", "static.png"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			expanded, expandErr := processor.ExpandFileNameTemplate(tc.template, values)
+
+			require.NoError(t, expandErr)
+			assert.Equal(t, tc.expected, expanded)
+		})
+	}
+}
+
+func TestExpandFileNameTemplate_Invalid(t *testing.T) {
+	_, expandErr := processor.ExpandFileNameTemplate("{name}_{index}.png", processor.TileFileName{})
+	require.Error(t, expandErr)
+	assert.Contains(t, expandErr.Error(), "unknown placeholder {index}")
+
+	_, expandErr = processor.ExpandFileNameTemplate("{name_{n}.png", processor.TileFileName{})
+	require.Error(t, expandErr)
+
+	_, expandErr = processor.ExpandFileNameTemplate("{name}_{n.png", processor.TileFileName{})
+	require.Error(t, expandErr)
+	assert.Contains(t, expandErr.Error(), "unclosed placeholder")
+}
+
+func TestConfig_Validate_FileNameTemplate(t *testing.T) {
+	testCases := []struct {
+		template string
+		valid    bool
+	}{
+		{"{name}_{n}.{ext}", true},
+		{"r{row}c{col}.png", true},
+		{"{name}.png", false},
+		{"r{row}.png", false},
+		{"{name}_{number}.png", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			config := processor.DefaultConfig()
+			config.FileNameTemplate = tc.template
+
+			validateErr := config.Validate()
+
+			if tc.valid {
+				require.NoError(t, validateErr)
+				return
+			}
+			var configErr *processor.ConfigError
+			require.ErrorAs(t, validateErr, &configErr)
+			assert.Equal(t, "FileNameTemplate", configErr.Field)
+		})
+	}
+}
+
+func TestService_TileOutputPath_Template(t *testing.T) {
+	coord := processor.TileCoordinate{Row: 1, Col: 2, Number: 6}
+
+	testCases := []struct {
+		name      string
+		outputDir string
+		template  string
+		device    string
+		expected  string
+	}{
+		{"nested in output directory", "/keys", "{name}/r{row}c{col}.{ext}", "", "/keys/sunset/r2c3.png"},
+		{"next to input", "", "{device}-{n}.{ext}", "desk-pad", "/photos/desk-pad-6.png"},
+		{"without device", "", "{device}-{n}.{ext}", "", "/photos/custom-6.png"},
+		{"absolute template", "/keys", "/tmp/{name}_{n}.png", "", "/tmp/sunset_6.png"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := processor.DefaultConfig()
+			config.OutputDir = tc.outputDir
+			config.FileNameTemplate = tc.template
+			config.Device = tc.device
+			service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, nil, config)
+			require.NoError(t, serviceErr)
+
+			assert.Equal(t, tc.expected, service.TileOutputPath("/photos/sunset.jpg", coord))
+		})
+	}
+}
+
+func TestService_SaveTiles_CreatesDirectories(t *testing.T) {
+	// Setup
+	mockFS := processor.NewTestMockFileSystem()
+	config := processor.DefaultConfig()
+	config.OutputDir = "/keys"
+	config.FileNameTemplate = "{name}/r{row}c{col}.{ext}"
+	service, serviceErr := processor.NewServiceWithDeps(mockFS, nil, processor.NewTestMockImageEncoder(nil), nil, config)
+	require.NoError(t, serviceErr)
+	procImg := &processor.ProcessedImage{
+		Result: processor.ProcessingResult{
+			Tiles:      []image.Image{processor.CreateTestImage(10, 10)},
+			TileCoords: []processor.TileCoordinate{{Row: 0, Col: 0, Number: 1}},
+		},
+	}
+
+	// Execute
+	saveErr := service.SaveTiles(context.Background(), procImg, "/photos/sunset.jpg")
+
+	// Assert
+	require.NoError(t, saveErr)
+	assert.True(t, mockFS.HasDir("/keys/sunset"))
+	_, exists := mockFS.GetWrittenFile("/keys/sunset/r1c1.png")
+	assert.True(t, exists)
+}
+
+func TestService_SaveTile_MkdirError(t *testing.T) {
+	mockFS := processor.NewTestMockFileSystem()
+	mockFS.MkdirAllFunc = func(_ string, _ fs.FileMode) error {
+		return errors.New("read-only file system")
+	}
+	service, serviceErr := processor.NewServiceWithDeps(
+		mockFS, nil, processor.NewTestMockImageEncoder(nil), nil, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+
+	saveErr := service.SaveTile(context.Background(), processor.CreateTestImage(10, 10), "/keys/tile.png")
+
+	require.Error(t, saveErr)
+	assert.Contains(t, saveErr.Error(), "error creating output directory")
+}
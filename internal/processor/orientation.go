@@ -0,0 +1,168 @@
+package processor
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Bytes per pixel of the images whose pixels Orientation.Apply moves directly.
+const (
+	rgba64PixelSize = 8
+	gray16PixelSize = 2
+)
+
+// Orientation is the EXIF orientation of an image: how the stored pixels
+// must be flipped and rotated to appear upright.
+type Orientation int
+
+// The EXIF orientations, named after the transformation that makes the image upright.
+const (
+	// OrientationNormal needs no transformation. The zero value, which
+	// means that the image has no orientation, needs none either.
+	OrientationNormal Orientation = iota + 1
+	// OrientationFlipHorizontal mirrors the image left to right.
+	OrientationFlipHorizontal
+	// OrientationRotate180 turns the image upside down.
+	OrientationRotate180
+	// OrientationFlipVertical mirrors the image top to bottom.
+	OrientationFlipVertical
+	// OrientationTranspose mirrors the image along its main diagonal.
+	OrientationTranspose
+	// OrientationRotate90 rotates the image 90° clockwise.
+	OrientationRotate90
+	// OrientationTransverse mirrors the image along its anti-diagonal.
+	OrientationTransverse
+	// OrientationRotate270 rotates the image 90° counterclockwise.
+	OrientationRotate270
+)
+
+// String describes the transformation, e.g. "rotated 90° clockwise".
+func (o Orientation) String() string {
+	switch o {
+	case OrientationFlipHorizontal:
+		return "flipped horizontally"
+	case OrientationRotate180:
+		return "rotated 180°"
+	case OrientationFlipVertical:
+		return "flipped vertically"
+	case OrientationTranspose:
+		return "transposed"
+	case OrientationRotate90:
+		return "rotated 90° clockwise"
+	case OrientationTransverse:
+		return "transversed"
+	case OrientationRotate270:
+		return "rotated 90° counterclockwise"
+	default:
+		return "upright"
+	}
+}
+
+// Transforms reports whether the orientation changes the image.
+func (o Orientation) Transforms() bool {
+	return o > OrientationNormal && o <= OrientationRotate270
+}
+
+// swapsAxes reports whether the orientation turns the width into the height.
+func (o Orientation) swapsAxes() bool {
+	return o >= OrientationTranspose && o <= OrientationRotate270
+}
+
+// source returns the pixel of a w x h source image that ends up at x, y.
+func (o Orientation) source(x, y, w, h int) (int, int) {
+	switch o {
+	case OrientationFlipHorizontal:
+		return w - 1 - x, y
+	case OrientationRotate180:
+		return w - 1 - x, h - 1 - y
+	case OrientationFlipVertical:
+		return x, h - 1 - y
+	case OrientationTranspose:
+		return y, x
+	case OrientationRotate90:
+		return y, h - 1 - x
+	case OrientationTransverse:
+		return w - 1 - y, h - 1 - x
+	case OrientationRotate270:
+		return w - 1 - y, x
+	default:
+		return x, y
+	}
+}
+
+// Apply returns the image transformed by the orientation, with its bounds at
+// the origin. The pixels of RGBA, NRGBA, Gray and their 16-bit variants are
+// moved as they are; other images are converted to RGBA, or RGBA64 when they
+// have 16 bits per channel, first. The image is returned unchanged when the
+// orientation does not transform it.
+func (o Orientation) Apply(img image.Image) image.Image {
+	if !o.Transforms() {
+		return img
+	}
+
+	bounds := img.Bounds()
+	size := bounds.Size()
+	if o.swapsAxes() {
+		size.X, size.Y = size.Y, size.X
+	}
+	rect := image.Rectangle{Max: size}
+
+	switch src := img.(type) {
+	case *image.RGBA:
+		dst := image.NewRGBA(rect)
+		o.movePixels(src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y):], src.Stride,
+			dst.Pix, dst.Stride, bounds.Size(), rgbaChannels)
+		return dst
+	case *image.NRGBA:
+		dst := image.NewNRGBA(rect)
+		o.movePixels(src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y):], src.Stride,
+			dst.Pix, dst.Stride, bounds.Size(), rgbaChannels)
+		return dst
+	case *image.RGBA64:
+		dst := image.NewRGBA64(rect)
+		o.movePixels(src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y):], src.Stride,
+			dst.Pix, dst.Stride, bounds.Size(), rgba64PixelSize)
+		return dst
+	case *image.NRGBA64:
+		dst := image.NewNRGBA64(rect)
+		o.movePixels(src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y):], src.Stride,
+			dst.Pix, dst.Stride, bounds.Size(), rgba64PixelSize)
+		return dst
+	case *image.Gray:
+		dst := image.NewGray(rect)
+		o.movePixels(src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y):], src.Stride,
+			dst.Pix, dst.Stride, bounds.Size(), 1)
+		return dst
+	case *image.Gray16:
+		dst := image.NewGray16(rect)
+		o.movePixels(src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y):], src.Stride,
+			dst.Pix, dst.Stride, bounds.Size(), gray16PixelSize)
+		return dst
+	}
+
+	var converted draw.Image
+	if newPixelSource(img, false).deep {
+		converted = image.NewRGBA64(image.Rectangle{Max: bounds.Size()})
+	} else {
+		converted = image.NewRGBA(image.Rectangle{Max: bounds.Size()})
+	}
+	draw.Draw(converted, converted.Bounds(), img, bounds.Min, draw.Src)
+	return o.Apply(converted)
+}
+
+// movePixels copies the pixels of a source of the given size, starting at
+// src, to their place in dst, pixelSize bytes at a time.
+func (o Orientation) movePixels(src []byte, srcStride int, dst []byte, dstStride int, size image.Point, pixelSize int) {
+	w, h := size.X, size.Y
+	dstWidth, dstHeight := w, h
+	if o.swapsAxes() {
+		dstWidth, dstHeight = h, w
+	}
+	parallelFor(dstHeight, 0, func(y int) {
+		row := dst[y*dstStride : y*dstStride+dstWidth*pixelSize]
+		for x := range dstWidth {
+			sx, sy := o.source(x, y, w, h)
+			copy(row[x*pixelSize:(x+1)*pixelSize], src[sy*srcStride+sx*pixelSize:])
+		}
+	})
+}
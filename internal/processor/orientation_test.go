@@ -0,0 +1,116 @@
+package processor_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+// createIndexedImage returns a width x height image whose pixels encode
+// their coordinates in the red and green channels.
+func createIndexedImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), A: 255}) // #nosec G115
+		}
+	}
+	return img
+}
+
+func TestOrientation_Apply(t *testing.T) {
+	// The source pixel that ends up at the top-left and top-right corners of
+	// the upright version of a 3x2 image.
+	testCases := []struct {
+		orientation processor.Orientation
+		size        image.Point
+		topLeft     image.Point
+		topRight    image.Point
+	}{
+		{processor.OrientationFlipHorizontal, image.Pt(3, 2), image.Pt(2, 0), image.Pt(0, 0)},
+		{processor.OrientationRotate180, image.Pt(3, 2), image.Pt(2, 1), image.Pt(0, 1)},
+		{processor.OrientationFlipVertical, image.Pt(3, 2), image.Pt(0, 1), image.Pt(2, 1)},
+		{processor.OrientationTranspose, image.Pt(2, 3), image.Pt(0, 0), image.Pt(0, 1)},
+		{processor.OrientationRotate90, image.Pt(2, 3), image.Pt(0, 1), image.Pt(0, 0)},
+		{processor.OrientationTransverse, image.Pt(2, 3), image.Pt(2, 1), image.Pt(2, 0)},
+		{processor.OrientationRotate270, image.Pt(2, 3), image.Pt(2, 0), image.Pt(2, 1)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.orientation.String(), func(t *testing.T) {
+			source := createIndexedImage(3, 2)
+
+			upright := tc.orientation.Apply(source)
+
+			assert.Equal(t, image.Rectangle{Max: tc.size}, upright.Bounds())
+			assert.Equal(t, source.At(tc.topLeft.X, tc.topLeft.Y), upright.At(0, 0))
+			assert.Equal(t, source.At(tc.topRight.X, tc.topRight.Y), upright.At(tc.size.X-1, 0))
+		})
+	}
+}
+
+func TestOrientation_Apply_ImageTypes(t *testing.T) {
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	deep := image.NewNRGBA64(image.Rect(0, 0, 4, 2))
+	deep.SetNRGBA64(3, 0, color.NRGBA64{R: 0x1234, A: 0xffff})
+	offset := createIndexedImage(6, 4).SubImage(image.Rect(2, 2, 6, 4))
+
+	assert.IsType(t, &image.RGBA{}, processor.OrientationRotate90.Apply(ycbcr))
+	assert.Equal(t, color.NRGBA64{R: 0x1234, A: 0xffff}, processor.OrientationRotate90.Apply(deep).At(1, 3))
+	assert.Equal(t, color.NRGBA{R: 5, G: 2, A: 255}, processor.OrientationRotate90.Apply(offset).At(1, 3))
+	assert.Same(t, ycbcr, processor.OrientationNormal.Apply(ycbcr))
+	assert.Same(t, ycbcr, processor.Orientation(0).Apply(ycbcr))
+}
+
+func TestEmbeddedOrientation(t *testing.T) {
+	// A little-endian TIFF header and directory with an Orientation of 8.
+	tiffData := []byte("II\x2a\x00\x08\x00\x00\x00\x02\x00" +
+		"\x00\x01\x03\x00\x01\x00\x00\x00\x10\x00\x00\x00" +
+		"\x12\x01\x03\x00\x01\x00\x00\x00\x08\x00\x00\x00" +
+		"\x00\x00\x00\x00")
+	exifChunk := append([]byte("EXIF"), binary.LittleEndian.AppendUint32(nil, uint32(len(tiffData)))...)
+	webpData := append([]byte("RIFF\x00\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00"), make([]byte, 10)...)
+	webpData = append(append(webpData, exifChunk...), tiffData...)
+	var jpegData bytes.Buffer
+	require.NoError(t, jpeg.Encode(&jpegData, processor.CreateTestImage(4, 2), nil))
+
+	assert.Equal(t, processor.OrientationRotate270, processor.EmbeddedOrientation("tiff", tiffData))
+	assert.Equal(t, processor.OrientationRotate270, processor.EmbeddedOrientation("webp", webpData))
+	assert.Equal(t, processor.OrientationRotate90, processor.EmbeddedOrientation("jpeg",
+		processor.EmbedEXIFOrientation(jpegData.Bytes(), processor.OrientationRotate90)))
+	assert.Equal(t, processor.Orientation(0), processor.EmbeddedOrientation("jpeg", jpegData.Bytes()))
+	assert.Equal(t, processor.Orientation(0), processor.EmbeddedOrientation("tiff", tiffData[:20]))
+}
+
+func TestService_LoadImage_EXIFOrientation(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, jpeg.Encode(&encoded, processor.CreateTestImage(40, 20), nil))
+	fs := processor.NewTestMockFileSystem()
+	fs.AddFile("/test/phone.jpg", processor.EmbedEXIFOrientation(encoded.Bytes(), processor.OrientationRotate90))
+	service, serviceErr := processor.NewServiceWithDeps(fs, processor.NewDefaultDecoderRegistry(),
+		processor.NewTestMockImageEncoder(nil), processor.NewTestMockImageResizer(), processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+
+	procImg, loadErr := service.LoadImage(context.Background(), "/test/phone.jpg")
+	require.NoError(t, loadErr)
+	assert.Equal(t, image.Rect(0, 0, 20, 40), procImg.Original.Bounds())
+	assert.Equal(t, processor.OrientationRotate90, procImg.Orientation)
+
+	config := processor.DefaultConfig()
+	config.IgnoreEXIF = true
+	service, serviceErr = service.WithConfig(config)
+	require.NoError(t, serviceErr)
+	procImg, loadErr = service.LoadImage(context.Background(), "/test/phone.jpg")
+	require.NoError(t, loadErr)
+	assert.Equal(t, image.Rect(0, 0, 40, 20), procImg.Original.Bounds())
+	assert.Equal(t, processor.Orientation(0), procImg.Orientation)
+}
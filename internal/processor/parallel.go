@@ -0,0 +1,39 @@
+package processor
+
+import (
+	"runtime"
+	"sync"
+)
+
+// parallelFor calls fn for every index from 0 to n-1 using at most jobs
+// goroutines, or one per CPU when jobs is 0, and returns once all calls
+// have finished.
+func parallelFor(n, jobs int, fn func(i int)) {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	jobs = min(jobs, n)
+	if jobs <= 1 {
+		for i := range n {
+			fn(i)
+		}
+		return
+	}
+
+	indexes := make(chan int)
+	var workers sync.WaitGroup
+	for range jobs {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		indexes <- i
+	}
+	close(indexes)
+	workers.Wait()
+}
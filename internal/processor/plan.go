@@ -0,0 +1,31 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// PlannedTile describes a tile file SaveTiles would write.
+type PlannedTile struct {
+	Coord TileCoordinate
+	Path  string
+	// Exists reports whether the file is already there and would be replaced,
+	// or make SaveTiles fail when Config.NoClobber is set.
+	Exists bool
+}
+
+// PlanTiles returns the files SaveTiles would write for a processed image,
+// checking which of them exist, without writing anything.
+func (s *Service) PlanTiles(procImg *ProcessedImage, originalPath string) ([]PlannedTile, error) {
+	plan := make([]PlannedTile, 0, len(procImg.Result.TileCoords))
+	for _, coord := range procImg.Result.TileCoords {
+		path := s.TileOutputPath(originalPath, coord)
+		_, statErr := s.fileSystem.Stat(path)
+		if statErr != nil && !errors.Is(statErr, fs.ErrNotExist) {
+			return nil, fmt.Errorf("error checking %s: %w", path, statErr)
+		}
+		plan = append(plan, PlannedTile{Coord: coord, Path: path, Exists: statErr == nil})
+	}
+	return plan, nil
+}
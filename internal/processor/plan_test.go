@@ -0,0 +1,50 @@
+package processor_test
+
+import (
+	"errors"
+	iofs "io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestService_PlanTiles(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	fs.AddFile("/test/image_2.png", []byte("existing tile"))
+	service, procImg := newSaveTestService(t, fs, processor.NewTestMockImageEncoder(nil), processor.DefaultConfig())
+
+	plan, planErr := service.PlanTiles(procImg, "/test/image.jpg")
+
+	require.NoError(t, planErr)
+	require.Len(t, plan, 9)
+	assert.Equal(t, processor.PlannedTile{
+		Coord:  processor.TileCoordinate{Row: 0, Col: 1, Number: 2},
+		Path:   "/test/image_2.png",
+		Exists: true,
+	}, plan[1])
+	assert.False(t, plan[0].Exists)
+	assert.Equal(t, "/test/image_9.png", plan[8].Path)
+	assert.Empty(t, fs.WrittenFiles(), "planning must not write")
+}
+
+func TestService_PlanTiles_StatError(t *testing.T) {
+	fs := &statErrorFileSystem{TestMockFileSystem: processor.NewTestMockFileSystem()}
+	service, procImg := newSaveTestService(t, fs, processor.NewTestMockImageEncoder(nil), processor.DefaultConfig())
+
+	_, planErr := service.PlanTiles(procImg, "/test/image.jpg")
+
+	require.Error(t, planErr)
+	assert.Contains(t, planErr.Error(), "error checking /test/image_1.png: permission denied")
+}
+
+// statErrorFileSystem fails every Stat call with something other than ErrNotExist.
+type statErrorFileSystem struct {
+	*processor.TestMockFileSystem
+}
+
+func (f *statErrorFileSystem) Stat(_ string) (iofs.FileInfo, error) {
+	return nil, errors.New("permission denied")
+}
@@ -0,0 +1,141 @@
+package processor
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+)
+
+const (
+	// MinPaletteColors and MaxPaletteColors bound the palette size of a
+	// quantized PNG, which stores at most 256 colors.
+	MinPaletteColors = 2
+	MaxPaletteColors = 256
+	rgbaChannels     = 4
+)
+
+// Quantize reduces an image to at most colors colors chosen by median cut
+// and maps it to that palette with Floyd-Steinberg dithering. Images that
+// already use no more colors keep them exactly.
+func Quantize(img image.Image, colors int) *image.Paletted {
+	bounds := img.Bounds()
+	paletted := image.NewPaletted(bounds, MedianCutPalette(img, colors))
+	draw.FloydSteinberg.Draw(paletted, bounds, img, bounds.Min)
+	return paletted
+}
+
+// weightedColor is a distinct color of an image and how many pixels use it.
+type weightedColor struct {
+	channels [rgbaChannels]uint8
+	count    int
+}
+
+// colorBox is a range of the distinct colors sorted along one channel.
+type colorBox []weightedColor
+
+// MedianCutPalette returns a palette of at most colors colors for img.
+// The distinct colors are split repeatedly at the weighted median of the
+// channel with the widest range, and each box contributes its average color.
+func MedianCutPalette(img image.Image, colors int) color.Palette {
+	distinct := distinctColors(img)
+	if len(distinct) == 0 {
+		return color.Palette{color.NRGBA{}}
+	}
+
+	boxes := []colorBox{distinct}
+	for len(boxes) < colors {
+		// Split the box with the most pixels among those that can be split.
+		index, pixels := -1, 0
+		for i, box := range boxes {
+			if count := box.pixels(); len(box) > 1 && count > pixels {
+				index, pixels = i, count
+			}
+		}
+		if index < 0 {
+			break
+		}
+
+		low, high := boxes[index].split()
+		boxes[index] = low
+		boxes = append(boxes, high)
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		palette = append(palette, box.average())
+	}
+	return palette
+}
+
+func distinctColors(img image.Image) colorBox {
+	bounds := img.Bounds()
+	counts := map[color.NRGBA]int{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			counts[color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)]++
+		}
+	}
+
+	box := make(colorBox, 0, len(counts))
+	for c, count := range counts {
+		box = append(box, weightedColor{channels: [rgbaChannels]uint8{c.R, c.G, c.B, c.A}, count: count})
+	}
+	// Map iteration order is random; sort so the palette is deterministic.
+	slices.SortFunc(box, func(a, b weightedColor) int {
+		return slices.Compare(a.channels[:], b.channels[:])
+	})
+	return box
+}
+
+func (b colorBox) pixels() int {
+	total := 0
+	for _, c := range b {
+		total += c.count
+	}
+	return total
+}
+
+// split sorts the box along its widest channel and cuts it at the weighted median.
+func (b colorBox) split() (colorBox, colorBox) {
+	channel, widest := 0, -1
+	for ch := range rgbaChannels {
+		low, high := uint8(255), uint8(0)
+		for _, c := range b {
+			low, high = min(low, c.channels[ch]), max(high, c.channels[ch])
+		}
+		if spread := int(high) - int(low); spread > widest {
+			channel, widest = ch, spread
+		}
+	}
+
+	slices.SortStableFunc(b, func(x, y weightedColor) int {
+		return int(x.channels[channel]) - int(y.channels[channel])
+	})
+
+	middle, seen := b.pixels()/half, 0
+	for i, c := range b[:len(b)-1] {
+		seen += c.count
+		if seen >= middle {
+			return b[:i+1], b[i+1:]
+		}
+	}
+	return b[:len(b)-1], b[len(b)-1:]
+}
+
+func (b colorBox) average() color.NRGBA {
+	var sums [rgbaChannels]int
+	total := 0
+	for _, c := range b {
+		for ch := range rgbaChannels {
+			sums[ch] += int(c.channels[ch]) * c.count
+		}
+		total += c.count
+	}
+
+	var channels [rgbaChannels]uint8
+	for ch := range rgbaChannels {
+		channels[ch] = uint8((sums[ch] + total/half) / total) // #nosec G115
+	}
+	return color.NRGBA{R: channels[0], G: channels[1], B: channels[2], A: channels[3]}
+}
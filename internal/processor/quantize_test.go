@@ -0,0 +1,55 @@
+package processor_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestMedianCutPalette_KeepsFewColorsExactly(t *testing.T) {
+	img := createPatchedImage(16, 16, image.Rect(0, 0, 8, 16), checkerboard)
+
+	palette := processor.MedianCutPalette(img, 16)
+
+	assert.ElementsMatch(t, color.Palette{
+		color.NRGBA{R: 128, G: 128, B: 128, A: 255},
+		color.NRGBA{A: 255},
+		color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+	}, palette)
+}
+
+func TestMedianCutPalette_LimitsColors(t *testing.T) {
+	img := createNoiseImage(40, 40)
+
+	for _, colors := range []int{2, 16, 256} {
+		palette := processor.MedianCutPalette(img, colors)
+
+		assert.Len(t, palette, colors)
+	}
+}
+
+func TestMedianCutPalette_Deterministic(t *testing.T) {
+	img := createCoordinateImage(64, 64)
+
+	assert.Equal(t, processor.MedianCutPalette(img, 8), processor.MedianCutPalette(img, 8))
+}
+
+func TestQuantize_UsesPalette(t *testing.T) {
+	img := createCoordinateImage(64, 64)
+
+	quantized := processor.Quantize(img, 4)
+
+	require.Len(t, quantized.Palette, 4)
+	assert.Equal(t, img.Bounds(), quantized.Bounds())
+	used := map[uint8]bool{}
+	for _, index := range quantized.Pix {
+		used[index] = true
+	}
+	// Dithering mixes the palette colors rather than collapsing to one.
+	assert.Greater(t, len(used), 1)
+}
@@ -0,0 +1,195 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// Resample selects the algorithm images are resized with.
+type Resample string
+
+const (
+	// ResampleLanczos is the sharpest filter and the default.
+	ResampleLanczos Resample = "lanczos"
+	// ResampleMitchell is the Mitchell-Netravali cubic filter, softer than
+	// Lanczos with less ringing around hard edges.
+	ResampleMitchell Resample = "mitchell"
+	// ResampleBicubic is the Catmull-Rom cubic filter.
+	ResampleBicubic Resample = "bicubic"
+	// ResampleBilinear interpolates linearly between neighbouring pixels, which is fast.
+	ResampleBilinear Resample = "bilinear"
+	// ResampleNearest copies the nearest source pixel, keeping edges hard.
+	ResampleNearest Resample = "nearest"
+	// ResampleNearestInteger copies the nearest source pixel at a whole
+	// multiple of the source size, so that every source pixel becomes a
+	// square of the same size; see IntegerScaleResizer.
+	ResampleNearestInteger Resample = "nearest-integer"
+)
+
+// Resamples returns the supported resampling algorithms.
+func Resamples() []Resample {
+	return []Resample{
+		ResampleLanczos, ResampleMitchell, ResampleBicubic, ResampleBilinear, ResampleNearest, ResampleNearestInteger,
+	}
+}
+
+// ParseResample returns the resampling algorithm with the given name, ignoring case.
+func ParseResample(value string) (Resample, error) {
+	names := make([]string, 0, len(Resamples()))
+	for _, resample := range Resamples() {
+		if strings.EqualFold(value, string(resample)) {
+			return resample, nil
+		}
+		names = append(names, string(resample))
+	}
+	return "", fmt.Errorf("invalid resampling %q, supported are: %s", value, strings.Join(names, ", "))
+}
+
+// NewResizer returns the ImageResizer for a resampling algorithm.
+func NewResizer(resample Resample) (ImageResizer, error) {
+	switch resample {
+	case ResampleLanczos:
+		return &LanczosResizer{}, nil
+	case ResampleNearestInteger:
+		return &IntegerScaleResizer{}, nil
+	case ResampleMitchell, ResampleBicubic, ResampleBilinear, ResampleNearest:
+		return &ResampleResizer{Resample: resample}, nil
+	default:
+		_, parseErr := ParseResample(string(resample))
+		return nil, parseErr
+	}
+}
+
+// ResampleResizer implements ImageResizer with the interpolation of a
+// resampling algorithm. Resample must not be ResampleNearestInteger.
+type ResampleResizer struct {
+	Resample Resample
+}
+
+// Resize resizes an image like LanczosResizer, with the interpolation of Resample.
+func (r *ResampleResizer) Resize(width, height uint, img image.Image) image.Image {
+	return r.convolution().Resize(width, height, img)
+}
+
+// ResizeLinear resizes an image like Resize, in linear light.
+func (r *ResampleResizer) ResizeLinear(width, height uint, img image.Image) image.Image {
+	return r.convolution().ResizeLinear(width, height, img)
+}
+
+func (r *ResampleResizer) convolution() *ConvolutionResizer {
+	filter := LanczosFilter()
+	switch r.Resample {
+	case ResampleMitchell:
+		filter = MitchellFilter()
+	case ResampleBicubic:
+		filter = CatmullRomFilter()
+	case ResampleBilinear:
+		filter = LinearFilter()
+	case ResampleNearest:
+		filter = NearestFilter()
+	}
+	return &ConvolutionResizer{Filter: filter}
+}
+
+// IntegerScaleResizer implements ImageResizer for pixel art. Instead of the
+// requested size, it scales by the smallest whole multiple of the source
+// size that reaches it, or divides by the largest whole divisor that stays
+// above it, copying the nearest source pixel. The result of Resize is
+// therefore up to one scale step larger than requested, which FitCover
+// crops. ResizeInside rounds the other way and stays within the requested
+// size, so that FitContain pads the rest instead of cropping the image.
+type IntegerScaleResizer struct{}
+
+// Resize resizes an image by whole factors. As with LanczosResizer, a zero
+// width or height keeps the aspect ratio, and both zero keeps the size.
+func (r *IntegerScaleResizer) Resize(width, height uint, img image.Image) image.Image {
+	return r.resize(width, height, img, false)
+}
+
+// ResizeInside resizes an image like Resize, by the largest whole multiple
+// that does not exceed the requested size or the smallest whole divisor that
+// brings the image within it.
+func (r *IntegerScaleResizer) ResizeInside(width, height uint, img image.Image) image.Image {
+	return r.resize(width, height, img, true)
+}
+
+// resize resizes an image by the whole factors that reach the requested size,
+// or that stay within it when inside is set.
+func (r *IntegerScaleResizer) resize(width, height uint, img image.Image, inside bool) image.Image {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return image.NewRGBA(image.Rect(0, 0, 0, 0))
+	}
+	srcWidth, srcHeight := uint(bounds.Dx()), uint(bounds.Dy()) // #nosec G115
+
+	var x, y integerScale
+	switch {
+	case width == 0 && height == 0:
+		x, y = integerScale{1, 1}, integerScale{1, 1}
+	case width == 0:
+		y = newIntegerScale(srcHeight, height, inside)
+		x = y
+	case height == 0:
+		x = newIntegerScale(srcWidth, width, inside)
+		y = x
+	default:
+		x, y = newIntegerScale(srcWidth, width, inside), newIntegerScale(srcHeight, height, inside)
+	}
+
+	dstWidth, dstHeight := x.apply(bounds.Dx()), y.apply(bounds.Dy())
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	rowBytes := dstWidth * rgbaChannels
+	previous := -1
+	for dy := range dstHeight {
+		row := dst.Pix[dy*dst.Stride : dy*dst.Stride+rowBytes]
+		sy := y.source(dy)
+		if sy == previous {
+			copy(row, dst.Pix[(dy-1)*dst.Stride:])
+			continue
+		}
+		previous = sy
+		for dx := range dstWidth {
+			c := color.RGBAModel.Convert(img.At(bounds.Min.X+x.source(dx), bounds.Min.Y+sy)).(color.RGBA)
+			dst.SetRGBA(dx, dy, c)
+		}
+	}
+	return dst
+}
+
+// ResizeLinear resizes an image like Resize: copying the nearest pixel
+// blends no colors, so linear light makes no difference.
+func (r *IntegerScaleResizer) ResizeLinear(width, height uint, img image.Image) image.Image {
+	return r.Resize(width, height, img)
+}
+
+// integerScale is a scale factor of up/down, where one of them is 1.
+type integerScale struct {
+	up, down int
+}
+
+// newIntegerScale returns the whole factor that brings size closest to target
+// without going below it, or without going above it when inside is set.
+func newIntegerScale(size, target uint, inside bool) integerScale {
+	switch {
+	case target >= size && inside:
+		return integerScale{up: int(target / size), down: 1} // #nosec G115
+	case target >= size:
+		return integerScale{up: int((target + size - 1) / size), down: 1} // #nosec G115
+	case inside:
+		return integerScale{up: 1, down: int((size + max(1, target) - 1) / max(1, target))} // #nosec G115
+	default:
+		return integerScale{up: 1, down: int(size / target)} // #nosec G115
+	}
+}
+
+func (s integerScale) apply(size int) int {
+	return max(1, size*s.up/s.down)
+}
+
+// source returns the source coordinate of a destination pixel, the center
+// of the block it covers when scaling down.
+func (s integerScale) source(dst int) int {
+	return (dst*s.down + s.down/half) / s.up
+}
@@ -0,0 +1,136 @@
+package processor_test
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestParseResample(t *testing.T) {
+	resample, parseErr := processor.ParseResample("Nearest-Integer")
+	require.NoError(t, parseErr)
+	assert.Equal(t, processor.ResampleNearestInteger, resample)
+
+	_, parseErr = processor.ParseResample("sinc")
+	require.Error(t, parseErr)
+	assert.Contains(t, parseErr.Error(), `invalid resampling "sinc", supported are: lanczos, mitchell`)
+}
+
+func TestNewResizer(t *testing.T) {
+	for _, resample := range processor.Resamples() {
+		t.Run(string(resample), func(t *testing.T) {
+			resizer, resizerErr := processor.NewResizer(resample)
+			require.NoError(t, resizerErr)
+
+			resized := resizer.Resize(0, 150, processor.CreateTestImage(400, 300))
+
+			assert.Equal(t, 150, resized.Bounds().Dy())
+			assert.Equal(t, 200, resized.Bounds().Dx())
+		})
+	}
+
+	_, resizerErr := processor.NewResizer("sinc")
+	require.Error(t, resizerErr)
+}
+
+func TestResampleResizer_NearestKeepsHardEdges(t *testing.T) {
+	source := createPatchedImage(8, 8, image.Rect(0, 0, 8, 8), checkerboard)
+
+	resized := (&processor.ResampleResizer{Resample: processor.ResampleNearest}).Resize(16, 16, source)
+
+	for y := range 16 {
+		for x := range 16 {
+			r, _, _, _ := resized.At(x, y).RGBA()
+			assert.True(t, r == 0 || r == 0xffff, "pixel %d,%d is blended", x, y)
+		}
+	}
+}
+
+func TestIntegerScaleResizer_ScalesUpByWholeMultiples(t *testing.T) {
+	source := createCoordinateImage(4, 3)
+
+	// 10 is not a multiple of 4; the next multiple, 3x, covers it.
+	resized := (&processor.IntegerScaleResizer{}).Resize(10, 0, source)
+
+	require.Equal(t, image.Rect(0, 0, 12, 9), resized.Bounds())
+	for y := range 9 {
+		for x := range 12 {
+			want := color.RGBAModel.Convert(source.At(x/3, y/3))
+			assert.Equal(t, want, resized.At(x, y), "pixel %d,%d", x, y)
+		}
+	}
+}
+
+func TestIntegerScaleResizer_ScalesDownByWholeDivisors(t *testing.T) {
+	source := createCoordinateImage(10, 10)
+
+	// Dividing by 2 keeps the image above 4 pixels, dividing by 3 would not.
+	resized := (&processor.IntegerScaleResizer{}).Resize(0, 4, source)
+
+	require.Equal(t, image.Rect(0, 0, 5, 5), resized.Bounds())
+	for y := range 5 {
+		for x := range 5 {
+			want := color.RGBAModel.Convert(source.At(x*2+1, y*2+1))
+			assert.Equal(t, want, resized.At(x, y), "pixel %d,%d", x, y)
+		}
+	}
+}
+
+func TestIntegerScaleResizer_ScalesAxesIndependently(t *testing.T) {
+	resized := (&processor.IntegerScaleResizer{}).Resize(8, 9, createCoordinateImage(4, 3))
+
+	assert.Equal(t, image.Rect(0, 0, 8, 9), resized.Bounds())
+}
+
+func TestIntegerScaleResizer_ResizeInside(t *testing.T) {
+	resizer := &processor.IntegerScaleResizer{}
+
+	// 2x stays within 10 pixels, 3x would not.
+	assert.Equal(t, image.Rect(0, 0, 8, 6), resizer.ResizeInside(10, 0, createCoordinateImage(4, 3)).Bounds())
+	// Dividing by 3 brings 10 pixels within 4, dividing by 2 would not.
+	assert.Equal(t, image.Rect(0, 0, 3, 3), resizer.ResizeInside(0, 4, createCoordinateImage(10, 10)).Bounds())
+}
+
+func TestService_WithResizer_Contain(t *testing.T) {
+	config := processor.DefaultConfig()
+	config.FitMode = processor.FitContain
+	config.Fill = color.NRGBA{A: 255}
+	service, serviceErr := processor.NewServiceWithDeps(processor.NewTestMockFileSystem(),
+		processor.NewTestMockImageDecoder(nil, "", nil), processor.NewTestMockImageEncoder(nil),
+		&processor.IntegerScaleResizer{}, config)
+	require.NoError(t, serviceErr)
+
+	source := createCoordinateImage(1000, 1000)
+	procImg, processErr := service.ProcessImageData(context.Background(),
+		&processor.ProcessedImage{Original: source})
+
+	require.NoError(t, processErr)
+	// Dividing by 2 would give 500 pixels and crop 122 of them; 3 fits in 378.
+	assert.Equal(t, image.Rect(0, 0, 333, 333), procImg.Resized.Bounds())
+	assert.Equal(t, image.Rect(0, 0, 378, 378), procImg.Squared.Bounds())
+	assert.True(t, procImg.Resized.Bounds().In(procImg.Crop), "the whole image is on the canvas")
+	assertSameColor(t, config.Fill, procImg.Squared.At(10, 10), "the rest is padded")
+	assertSameColor(t, source.At(1, 1), procImg.Squared.At(22, 22), "the image starts after the padding")
+}
+
+func TestService_WithResizer(t *testing.T) {
+	service, serviceErr := processor.NewServiceWithDeps(processor.NewTestMockFileSystem(),
+		processor.NewTestMockImageDecoder(nil, "", nil), processor.NewTestMockImageEncoder(nil),
+		processor.NewTestMockImageResizer(), processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+
+	pixelArt := service.WithResizer(&processor.IntegerScaleResizer{})
+	procImg, processErr := pixelArt.ProcessImageData(context.Background(),
+		&processor.ProcessedImage{Original: createCoordinateImage(32, 32)})
+
+	require.NoError(t, processErr)
+	// 378 is not a multiple of 32; 12x gives 384.
+	assert.Equal(t, image.Rect(0, 0, 384, 384), procImg.Resized.Bounds())
+	assert.Equal(t, image.Rect(0, 0, 378, 378), procImg.Squared.Bounds())
+}
@@ -0,0 +1,401 @@
+package processor
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	lanczosLobes = 3
+	cubicSupport = 2
+	// sizeRounding rounds a derived width or height the way nfnt/resize did,
+	// so that switching resizers does not change the tile geometry.
+	sizeRounding = 0.7
+	maxUint8     = 0xff
+	maxUint16    = 0xffff
+)
+
+// ResampleFilter is the kernel a ConvolutionResizer weighs source pixels with.
+type ResampleFilter struct {
+	// Support is the radius of the kernel, in source pixels when enlarging
+	// and in destination pixels when reducing. 0 selects the nearest pixel.
+	Support float64
+	// Kernel returns the weight of a pixel at distance x from the sample point.
+	Kernel func(x float64) float64
+}
+
+// LanczosFilter returns the three-lobed Lanczos filter, the sharpest one.
+func LanczosFilter() ResampleFilter {
+	return ResampleFilter{Support: lanczosLobes, Kernel: lanczos}
+}
+
+// CatmullRomFilter returns the Catmull-Rom cubic spline, sharp with little ringing.
+func CatmullRomFilter() ResampleFilter {
+	return ResampleFilter{Support: cubicSupport, Kernel: cubic(0, 0.5)}
+}
+
+// MitchellFilter returns the Mitchell-Netravali cubic, softer than Catmull-Rom.
+func MitchellFilter() ResampleFilter {
+	return ResampleFilter{Support: cubicSupport, Kernel: cubic(1.0/3, 1.0/3)}
+}
+
+// LinearFilter returns the filter that interpolates linearly, or averages
+// like a tent when reducing.
+func LinearFilter() ResampleFilter {
+	return ResampleFilter{Support: 1, Kernel: func(x float64) float64 { return max(0, 1-math.Abs(x)) }}
+}
+
+// NearestFilter returns the filter that copies the nearest source pixel.
+func NearestFilter() ResampleFilter {
+	return ResampleFilter{}
+}
+
+func lanczos(x float64) float64 {
+	x = math.Abs(x)
+	switch {
+	case x == 0:
+		return 1
+	case x >= lanczosLobes:
+		return 0
+	default:
+		px := math.Pi * x
+		return lanczosLobes * math.Sin(px) * math.Sin(px/lanczosLobes) / (px * px)
+	}
+}
+
+// cubic returns the Mitchell-Netravali family of cubic kernels with
+// parameters b and c.
+func cubic(b, c float64) func(float64) float64 {
+	return func(x float64) float64 {
+		x = math.Abs(x)
+		switch {
+		case x < 1:
+			return ((12-9*b-6*c)*x*x*x + (-18+12*b+6*c)*x*x + (6 - 2*b)) / 6
+		case x < cubicSupport:
+			return ((-b-6*c)*x*x*x + (6*b+30*c)*x*x + (-12*b-48*c)*x + (8*b + 24*c)) / 6
+		default:
+			return 0
+		}
+	}
+}
+
+// ConvolutionResizer implements ImageResizer by separable convolution: rows
+// are filtered horizontally, then columns vertically, with the rows of each
+// pass spread over several goroutines. Pixels are filtered as premultiplied
+// floating-point values, so transparent pixels do not bleed their color, and
+// images with 16 bits per channel keep their precision.
+type ConvolutionResizer struct {
+	// Filter weighs the source pixels. The zero value is NearestFilter().
+	Filter ResampleFilter
+	// GammaCorrect filters in linear light rather than on sRGB values, which
+	// keeps fine bright detail and the edges between contrasting colors from
+	// darkening.
+	GammaCorrect bool
+	// Jobs is the number of rows filtered at the same time. 0 means one per CPU.
+	Jobs int
+}
+
+// Resize resizes an image to width x height. When one of them is 0 it is
+// derived from the aspect ratio, and when both are 0 or the image already
+// has the requested size, the image is returned unchanged.
+func (r *ConvolutionResizer) Resize(width, height uint, img image.Image) image.Image {
+	bounds := img.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	dstWidth, dstHeight, scaleX, scaleY := resizeDimensions(width, height, srcWidth, srcHeight)
+	if srcWidth <= 0 || srcHeight <= 0 || (dstWidth == srcWidth && dstHeight == srcHeight) {
+		return img
+	}
+	if dstWidth <= 0 || dstHeight <= 0 {
+		return image.NewRGBA(image.Rect(0, 0, max(0, dstWidth), max(0, dstHeight)))
+	}
+
+	source := newPixelSource(img, r.GammaCorrect)
+	columns := newResampleTaps(srcWidth, dstWidth, scaleX, r.Filter)
+	rows := newResampleTaps(srcHeight, dstHeight, scaleY, r.Filter)
+
+	// Horizontal pass: every source row is reduced or enlarged to dstWidth.
+	stride := dstWidth * rgbaChannels
+	temp := make([]float32, srcHeight*stride)
+	parallelFor(srcHeight, r.Jobs, func(y int) {
+		line := make([]float32, srcWidth*rgbaChannels)
+		source.row(y, line)
+		out := temp[y*stride : (y+1)*stride]
+		for x, taps := range columns {
+			var sum [rgbaChannels]float32
+			for i, weight := range taps.weights {
+				pixel := line[(taps.start+i)*rgbaChannels:]
+				for ch := range rgbaChannels {
+					sum[ch] += weight * pixel[ch]
+				}
+			}
+			copy(out[x*rgbaChannels:], sum[:])
+		}
+	})
+
+	// Vertical pass: every destination row is a weighted sum of temp rows.
+	result := make([]float32, dstHeight*stride)
+	parallelFor(dstHeight, r.Jobs, func(y int) {
+		out := result[y*stride : (y+1)*stride]
+		taps := rows[y]
+		for i, weight := range taps.weights {
+			in := temp[(taps.start+i)*stride : (taps.start+i+1)*stride]
+			for x, value := range in {
+				out[x] += weight * value
+			}
+		}
+	})
+
+	return storePixels(result, dstWidth, dstHeight, source.deep, r.GammaCorrect, r.Jobs)
+}
+
+// ResizeLinear resizes an image like Resize with GammaCorrect set, which
+// makes ConvolutionResizer a LinearLightResizer.
+func (r *ConvolutionResizer) ResizeLinear(width, height uint, img image.Image) image.Image {
+	linear := *r
+	linear.GammaCorrect = true
+	return linear.Resize(width, height, img)
+}
+
+// resizeDimensions returns the destination size for a Resize call and the
+// number of source pixels per destination pixel along each axis. A zero width
+// or height is derived from the aspect ratio of the source, and both axes
+// then share the scale of the other one, as with nfnt/resize, so that the
+// sampling grid stays square even when the derived size is rounded.
+func resizeDimensions(width, height uint, srcWidth, srcHeight int) (int, int, float64, float64) {
+	switch {
+	case width == 0 && height == 0:
+		return srcWidth, srcHeight, 1, 1
+	case width == 0:
+		scale := float64(srcHeight) / float64(height)
+		return int(sizeRounding + float64(srcWidth)/scale), int(height), scale, scale // #nosec G115
+	case height == 0:
+		scale := float64(srcWidth) / float64(width)
+		return int(width), int(sizeRounding + float64(srcHeight)/scale), scale, scale // #nosec G115
+	default:
+		return int(width), int(height), float64(srcWidth) / float64(width), float64(srcHeight) / float64(height) // #nosec G115
+	}
+}
+
+// resampleTaps are the normalized weights of the consecutive source pixels,
+// from start, that make up a destination pixel.
+type resampleTaps struct {
+	start   int
+	weights []float32
+}
+
+// newResampleTaps computes the taps of every destination pixel along an axis
+// with scale source pixels per destination pixel. Taps that would fall
+// outside the image are dropped and the others are renormalized, so that
+// edges keep their color.
+func newResampleTaps(srcSize, dstSize int, scale float64, filter ResampleFilter) []resampleTaps {
+	taps := make([]resampleTaps, dstSize)
+
+	if filter.Support == 0 || filter.Kernel == nil {
+		for i := range taps {
+			nearest := max(0, min(srcSize-1, int((float64(i)+0.5)*scale)))
+			taps[i] = resampleTaps{start: nearest, weights: []float32{1}}
+		}
+		return taps
+	}
+
+	// When reducing, the kernel is stretched to cover the source pixels that
+	// fall into a destination pixel.
+	filterScale := max(scale, 1)
+	support := filter.Support * filterScale
+	for i := range taps {
+		center := (float64(i) + 0.5) * scale
+		low := min(srcSize-1, max(0, int(math.Floor(center-support))))
+		high := max(low+1, min(srcSize, int(math.Ceil(center+support))))
+
+		weights := make([]float64, high-low)
+		total := 0.0
+		for j := range weights {
+			weights[j] = filter.Kernel((float64(low+j) + 0.5 - center) / filterScale)
+			total += weights[j]
+		}
+		normalized := make([]float32, len(weights))
+		if total == 0 {
+			// Every tap fell on a zero of the kernel; use the nearest pixel.
+			normalized[min(len(weights)-1, max(0, int(center)-low))] = 1
+		}
+		for j, weight := range weights {
+			if total != 0 {
+				normalized[j] = float32(weight / total)
+			}
+		}
+		taps[i] = resampleTaps{start: low, weights: normalized}
+	}
+	return taps
+}
+
+// pixelSource reads the rows of an image as premultiplied RGBA values from 0
+// to 1, in linear light when linear is set.
+type pixelSource struct {
+	img    image.Image
+	linear bool
+	// deep reports whether the image has more than 8 bits per channel.
+	deep bool
+	// decode is srgbToLinear8 when linear is set.
+	decode *[maxUint8 + 1]float32
+}
+
+func newPixelSource(img image.Image, linear bool) *pixelSource {
+	model := img.ColorModel()
+	deep := model == color.RGBA64Model || model == color.NRGBA64Model || model == color.Gray16Model
+	source := &pixelSource{img: img, linear: linear, deep: deep}
+	if linear {
+		source.decode = srgbToLinear8()
+	}
+	return source
+}
+
+// row reads row y, counted from the top of the image, into line.
+func (s *pixelSource) row(y int, line []float32) {
+	bounds := s.img.Bounds()
+	y += bounds.Min.Y
+	width := bounds.Dx()
+
+	switch src := s.img.(type) {
+	case *image.RGBA:
+		pix := src.Pix[src.PixOffset(bounds.Min.X, y):]
+		for x := range width {
+			p := pix[x*rgbaChannels:]
+			s.set8(line[x*rgbaChannels:], p[0], p[1], p[2], p[3], true)
+		}
+	case *image.NRGBA:
+		pix := src.Pix[src.PixOffset(bounds.Min.X, y):]
+		for x := range width {
+			p := pix[x*rgbaChannels:]
+			s.set8(line[x*rgbaChannels:], p[0], p[1], p[2], p[3], false)
+		}
+	case *image.YCbCr:
+		for x := range width {
+			yi, ci := src.YOffset(bounds.Min.X+x, y), src.COffset(bounds.Min.X+x, y)
+			r, g, b := color.YCbCrToRGB(src.Y[yi], src.Cb[ci], src.Cr[ci])
+			s.set8(line[x*rgbaChannels:], r, g, b, maxUint8, false)
+		}
+	case *image.Gray:
+		pix := src.Pix[src.PixOffset(bounds.Min.X, y):]
+		for x := range width {
+			s.set8(line[x*rgbaChannels:], pix[x], pix[x], pix[x], maxUint8, false)
+		}
+	default:
+		for x := range width {
+			r, g, b, a := s.img.At(bounds.Min.X+x, y).RGBA()
+			s.set16(line[x*rgbaChannels:], r, g, b, a)
+		}
+	}
+}
+
+// set8 stores a pixel with 8 bits per channel, given with straight or
+// premultiplied alpha.
+func (s *pixelSource) set8(dst []float32, r, g, b, a uint8, premultiplied bool) {
+	alpha := float32(a) / maxUint8
+	channels := [3]uint8{r, g, b}
+	for ch, c := range channels {
+		switch {
+		case !s.linear && premultiplied:
+			dst[ch] = float32(c) / maxUint8
+		case !s.linear:
+			dst[ch] = float32(c) / maxUint8 * alpha
+		case a == 0:
+			dst[ch] = 0
+		case premultiplied && a != maxUint8:
+			dst[ch] = srgbToLinear(float32(c)/float32(a)) * alpha
+		default:
+			dst[ch] = s.decode[c] * alpha
+		}
+	}
+	dst[3] = alpha
+}
+
+// set16 stores a pixel given as premultiplied 16-bit values, as returned by color.Color.
+func (s *pixelSource) set16(dst []float32, r, g, b, a uint32) {
+	alpha := float32(a) / maxUint16
+	channels := [3]uint32{r, g, b}
+	for ch, c := range channels {
+		switch {
+		case !s.linear:
+			dst[ch] = float32(c) / maxUint16
+		case a == 0:
+			dst[ch] = 0
+		default:
+			dst[ch] = srgbToLinear(float32(c)/float32(a)) * alpha
+		}
+	}
+	dst[3] = alpha
+}
+
+// srgbToLinear8 returns the linear light value of every 8-bit sRGB value.
+func srgbToLinear8() *[maxUint8 + 1]float32 {
+	var table [maxUint8 + 1]float32
+	for c := range table {
+		table[c] = srgbToLinear(float32(c) / maxUint8)
+	}
+	return &table
+}
+
+// storePixels converts premultiplied values back to an *image.RGBA, or an
+// *image.RGBA64 when deep is set, clamping the overshoot of the filter.
+func storePixels(values []float32, width, height int, deep, linear bool, jobs int) image.Image {
+	var rgba *image.RGBA
+	var rgba64 *image.RGBA64
+	if deep {
+		rgba64 = image.NewRGBA64(image.Rect(0, 0, width, height))
+	} else {
+		rgba = image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+
+	parallelFor(height, jobs, func(y int) {
+		for x := range width {
+			in := values[(y*width+x)*rgbaChannels:]
+			alpha := min(1, max(0, in[3]))
+			var out [rgbaChannels]float32
+			out[3] = alpha
+			if alpha > 0 {
+				for ch := range 3 {
+					c := min(1, max(0, in[ch]/alpha))
+					if linear {
+						c = linearToSRGB(c)
+					}
+					out[ch] = c * alpha
+				}
+			}
+
+			if deep {
+				i := rgba64.PixOffset(x, y)
+				for ch, value := range out {
+					v := uint16(value*maxUint16 + 0.5)
+					rgba64.Pix[i+2*ch], rgba64.Pix[i+2*ch+1] = uint8(v>>8), uint8(v) // #nosec G115
+				}
+			} else {
+				i := rgba.PixOffset(x, y)
+				for ch, value := range out {
+					rgba.Pix[i+ch] = uint8(value*maxUint8 + 0.5)
+				}
+			}
+		}
+	})
+
+	if deep {
+		return rgba64
+	}
+	return rgba
+}
+
+// srgbToLinear converts an sRGB encoded value to linear light.
+func srgbToLinear(c float32) float32 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return float32(math.Pow((float64(c)+0.055)/1.055, 2.4))
+}
+
+// linearToSRGB converts a linear light value to its sRGB encoding.
+func linearToSRGB(c float32) float32 {
+	if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return float32(1.055*math.Pow(float64(c), 1/2.4) - 0.055)
+}
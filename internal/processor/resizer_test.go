@@ -0,0 +1,259 @@
+package processor_test
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+// resizeGoldenCases were resized with the Lanczos3 resizer of
+// github.com/nfnt/resize, which LanczosResizer used before, into
+// testdata/resize/<name>.png. The golden images hold the premultiplied RGBA
+// bytes as they were, stored as NRGBA so that PNG keeps them unchanged.
+type resizeGoldenCase struct {
+	name          string
+	width, height int
+	transparent   bool
+	// smooth selects createSmoothSource rather than createResizeSource.
+	smooth       bool
+	resizeWidth  uint
+	resizeHeight uint
+}
+
+func resizeGoldenCases() []resizeGoldenCase {
+	return []resizeGoldenCase{
+		{name: "down4x", width: 480, height: 320, resizeHeight: 80},
+		{name: "down-cover", width: 400, height: 300, resizeWidth: 378},
+		{name: "up", width: 60, height: 40, resizeWidth: 150},
+		{name: "stretch", width: 200, height: 200, resizeWidth: 116, resizeHeight: 90},
+		{name: "transparent", width: 300, height: 200, transparent: true, resizeHeight: 116},
+		{name: "smooth-down", width: 480, height: 360, smooth: true, resizeWidth: 160},
+		{name: "smooth-up", width: 96, height: 72, smooth: true, resizeWidth: 288},
+	}
+}
+
+func (tc resizeGoldenCase) source() image.Image {
+	if tc.smooth {
+		return createSmoothSource(tc.width, tc.height)
+	}
+	return createResizeSource(tc.width, tc.height, tc.transparent)
+}
+
+// createSmoothSource samples a few slow waves at the pixel centers of a
+// width x height image, so that an ideal resizer turns the image of one size
+// into the image of another.
+func createSmoothSource(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			u, v := (float64(x)+0.5)/float64(width), (float64(y)+0.5)/float64(height)
+			wave := func(fu, fv, phase float64) uint8 {
+				return uint8(math.Round(127.5 + 100*math.Sin(2*math.Pi*(fu*u+fv*v)+phase)))
+			}
+			img.SetRGBA(x, y, color.RGBA{R: wave(3, 1, 0), G: wave(1, 4, 1), B: wave(5, 3, 2), A: 255})
+		}
+	}
+	return img
+}
+
+// createResizeSource returns an image mixing gradients, a sine pattern, a
+// hard-edged disc and fine stripes, with an alpha gradient when transparent.
+func createResizeSource(width, height int, transparent bool) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	radius := float64(min(width, height)) / 4
+	for y := range height {
+		for x := range width {
+			u, v := float64(x)/float64(width), float64(y)/float64(height)
+			c := color.NRGBA{
+				R: uint8(255 * u),
+				G: uint8(255 * v),
+				B: uint8(128 + 127*math.Sin(2*math.Pi*float64(x+y)/23)),
+				A: 255,
+			}
+			dx, dy := float64(x)-float64(width)/2, float64(y)-float64(height)/2
+			switch {
+			case math.Hypot(dx, dy) < radius:
+				c.R, c.G, c.B = 255, 255, 255
+			case x < width/3 && y < height/3 && (x/3)%2 == 0:
+				c.R, c.G, c.B = 0, 0, 0
+			}
+			if transparent {
+				c.A = uint8(255 - 200*u)
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	if transparent {
+		return img
+	}
+
+	// Opaque sources are RGBA, like most decoded images.
+	rgba := image.NewRGBA(img.Bounds())
+	for y := range height {
+		for x := range width {
+			rgba.Set(x, y, img.At(x, y))
+		}
+	}
+	return rgba
+}
+
+// psnr returns the peak signal-to-noise ratio between two images of the same
+// size in decibels, comparing premultiplied 8-bit channels.
+func psnr(t *testing.T, a, b image.Image) float64 {
+	t.Helper()
+	require.Equal(t, a.Bounds().Size(), b.Bounds().Size())
+
+	sum, n := 0.0, 0
+	for y := range a.Bounds().Dy() {
+		for x := range a.Bounds().Dx() {
+			ca := color.RGBAModel.Convert(a.At(a.Bounds().Min.X+x, a.Bounds().Min.Y+y)).(color.RGBA)
+			cb := color.RGBAModel.Convert(b.At(b.Bounds().Min.X+x, b.Bounds().Min.Y+y)).(color.RGBA)
+			for _, d := range []float64{
+				float64(ca.R) - float64(cb.R), float64(ca.G) - float64(cb.G),
+				float64(ca.B) - float64(cb.B), float64(ca.A) - float64(cb.A),
+			} {
+				sum += d * d
+				n++
+			}
+		}
+	}
+	if sum == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255/(sum/float64(n)))
+}
+
+func loadGolden(t *testing.T, name string) image.Image {
+	t.Helper()
+	file, openErr := os.Open(filepath.Join("testdata", "resize", name+".png"))
+	require.NoError(t, openErr)
+	defer file.Close()
+	img, decodeErr := png.Decode(file)
+	require.NoError(t, decodeErr)
+
+	// Opaque images are written as RGB, which decodes as RGBA.
+	if raw, ok := img.(*image.NRGBA); ok {
+		return &image.RGBA{Pix: raw.Pix, Stride: raw.Stride, Rect: raw.Rect}
+	}
+	return img
+}
+
+func TestConvolutionResizer_MatchesLanczosGolden(t *testing.T) {
+	for _, tc := range resizeGoldenCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			golden := loadGolden(t, tc.name)
+
+			resized := (&processor.ConvolutionResizer{Filter: processor.LanczosFilter()}).Resize(
+				tc.resizeWidth, tc.resizeHeight, tc.source())
+
+			require.Equal(t, golden.Bounds(), resized.Bounds())
+			// The golden images were filtered with 8-bit weights and clamped
+			// between passes, so they differ slightly around hard edges.
+			assert.Greater(t, psnr(t, golden, resized), 45.0)
+		})
+	}
+}
+
+func TestConvolutionResizer_AtLeastAsAccurateAsGolden(t *testing.T) {
+	for _, tc := range resizeGoldenCases() {
+		if !tc.smooth {
+			continue
+		}
+		t.Run(tc.name, func(t *testing.T) {
+			resized := (&processor.ConvolutionResizer{Filter: processor.LanczosFilter()}).Resize(
+				tc.resizeWidth, tc.resizeHeight, tc.source())
+			ideal := createSmoothSource(resized.Bounds().Dx(), resized.Bounds().Dy())
+
+			assert.GreaterOrEqual(t, psnr(t, ideal, resized), psnr(t, ideal, loadGolden(t, tc.name)))
+		})
+	}
+}
+
+func TestConvolutionResizer_Filters(t *testing.T) {
+	source := createSmoothSource(240, 180)
+	for name, filter := range map[string]processor.ResampleFilter{
+		"lanczos":     processor.LanczosFilter(),
+		"catmull-rom": processor.CatmullRomFilter(),
+		"mitchell":    processor.MitchellFilter(),
+		"linear":      processor.LinearFilter(),
+		"nearest":     processor.NearestFilter(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, size := range [][2]uint{{160, 0}, {0, 300}, {50, 70}} {
+				resized := (&processor.ConvolutionResizer{Filter: filter}).Resize(size[0], size[1], source)
+				ideal := createSmoothSource(resized.Bounds().Dx(), resized.Bounds().Dy())
+				assert.Greater(t, psnr(t, ideal, resized), 25.0, "resized to %v", resized.Bounds())
+			}
+		})
+	}
+}
+
+func TestConvolutionResizer_GammaCorrect(t *testing.T) {
+	stripes := createStripes(64, 8)
+
+	plain := (&processor.ConvolutionResizer{Filter: processor.LinearFilter()}).Resize(16, 2, stripes)
+	linear := (&processor.ConvolutionResizer{Filter: processor.LinearFilter(), GammaCorrect: true}).Resize(16, 2, stripes)
+
+	assert.InDelta(t, 128, color.RGBAModel.Convert(plain.At(8, 1)).(color.RGBA).R, 1)
+	assert.InDelta(t, 188, color.RGBAModel.Convert(linear.At(8, 1)).(color.RGBA).R, 1)
+}
+
+func TestConvolutionResizer_KeepsSixteenBitPrecision(t *testing.T) {
+	deep := image.NewRGBA64(image.Rect(0, 0, 40, 40))
+	for y := range 40 {
+		for x := range 40 {
+			deep.SetRGBA64(x, y, color.RGBA64{R: uint16(x * 1000), G: 0x1234, B: uint16(y * 1000), A: 0xffff})
+		}
+	}
+
+	resized := (&processor.ConvolutionResizer{Filter: processor.LanczosFilter()}).Resize(20, 20, deep)
+
+	require.IsType(t, &image.RGBA64{}, resized)
+	_, g, _, _ := resized.At(10, 10).RGBA()
+	assert.InDelta(t, 0x1234, g, 1, "a flat 16-bit channel must not be rounded to 8 bits")
+}
+
+func TestConvolutionResizer_SourceTypes(t *testing.T) {
+	rgba := createSmoothSource(120, 90).(*image.RGBA)
+	ycbcr := image.NewYCbCr(rgba.Bounds(), image.YCbCrSubsampleRatio444)
+	nrgba := image.NewNRGBA(rgba.Bounds())
+	for y := range 90 {
+		for x := range 120 {
+			c := rgba.RGBAAt(x, y)
+			yy, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			ycbcr.Y[ycbcr.YOffset(x, y)], ycbcr.Cb[ycbcr.COffset(x, y)], ycbcr.Cr[ycbcr.COffset(x, y)] = yy, cb, cr
+			nrgba.Set(x, y, c)
+		}
+	}
+	resizer := &processor.ConvolutionResizer{Filter: processor.CatmullRomFilter()}
+	want := resizer.Resize(50, 0, rgba)
+
+	assert.Equal(t, want, resizer.Resize(50, 0, nrgba))
+	assert.Greater(t, psnr(t, want, resizer.Resize(50, 0, ycbcr)), 40.0)
+	assert.Greater(t, psnr(t, want, resizer.Resize(50, 0, rgba.SubImage(rgba.Bounds()))), 99.0)
+}
+
+func TestConvolutionResizer_ParallelRowsAreDeterministic(t *testing.T) {
+	source := createResizeSource(300, 200, true)
+
+	sequential := (&processor.ConvolutionResizer{Filter: processor.LanczosFilter(), Jobs: 1}).Resize(0, 90, source)
+	parallel := (&processor.ConvolutionResizer{Filter: processor.LanczosFilter(), Jobs: 8}).Resize(0, 90, source)
+
+	assert.Equal(t, sequential, parallel)
+}
+
+func TestConvolutionResizer_KeepsImageOfRequestedSize(t *testing.T) {
+	source := processor.CreateTestImage(40, 30)
+
+	assert.Same(t, source, (&processor.ConvolutionResizer{}).Resize(40, 0, source))
+	assert.Same(t, source, (&processor.ConvolutionResizer{}).Resize(0, 0, source))
+}
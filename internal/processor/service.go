@@ -1,10 +1,18 @@
 package processor
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image"
+	"io"
+	"io/fs"
 	"path/filepath"
-	"strings"
+)
+
+const (
+	defaultOutputExtension = "png"
+	outputDirPerm          = 0o755
 )
 
 // Service handles image processing with configurable dependencies.
@@ -20,7 +28,7 @@ type Service struct {
 func NewService() *Service {
 	return &Service{
 		fileSystem: &OSFileSystem{},
-		decoder:    &StandardImageDecoder{},
+		decoder:    NewDefaultDecoderRegistry(),
 		encoder:    &PNGEncoder{},
 		resizer:    &LanczosResizer{},
 		config:     DefaultConfig(),
@@ -28,92 +36,433 @@ func NewService() *Service {
 }
 
 // NewServiceWithDeps creates a new processor service with custom dependencies.
-func NewServiceWithDeps(fs FileSystem, decoder ImageDecoder, encoder ImageEncoder, resizer ImageResizer, config Config) *Service {
+// It returns an error when the configuration does not pass Config.Validate.
+func NewServiceWithDeps(
+	fs FileSystem, decoder ImageDecoder, encoder ImageEncoder, resizer ImageResizer, config Config,
+) (*Service, error) {
+	if validateErr := config.Validate(); validateErr != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", validateErr)
+	}
+
 	return &Service{
 		fileSystem: fs,
 		decoder:    decoder,
 		encoder:    encoder,
 		resizer:    resizer,
-		config:     config,
+		config:     config.Normalized(),
+	}, nil
+}
+
+// Config returns the processing configuration used by the service.
+func (s *Service) Config() Config {
+	return s.config
+}
+
+// WithConfig returns a copy of the service that shares its dependencies
+// but uses the given configuration, which must pass Config.Validate.
+func (s *Service) WithConfig(config Config) (*Service, error) {
+	if validateErr := config.Validate(); validateErr != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", validateErr)
 	}
+
+	clone := *s
+	clone.config = config.Normalized()
+	return &clone, nil
+}
+
+// WithEncoder returns a copy of the service that shares its dependencies
+// but writes images with the given encoder.
+func (s *Service) WithEncoder(encoder ImageEncoder) *Service {
+	clone := *s
+	clone.encoder = encoder
+	return &clone
+}
+
+// WithResizer returns a copy of the service that shares its dependencies
+// but resizes images with the given resizer.
+func (s *Service) WithResizer(resizer ImageResizer) *Service {
+	clone := *s
+	clone.resizer = resizer
+	return &clone
+}
+
+// OutputExtension returns the file extension, without the dot, of the
+// images the service writes.
+func (s *Service) OutputExtension() string {
+	return OutputExtension(s.encoder)
 }
 
-// ProcessImage processes an image file and splits it into tiles.
-func (s *Service) ProcessImage(imagePath string) error {
-	img, loadErr := s.LoadImage(imagePath)
+// ProcessImage processes an image file and splits it into tiles. It stops
+// with the context error when ctx is cancelled, leaving no tile written.
+func (s *Service) ProcessImage(ctx context.Context, imagePath string) error {
+	img, loadErr := s.LoadImage(ctx, imagePath)
 	if loadErr != nil {
 		return fmt.Errorf("failed to load image: %w", loadErr)
 	}
 
-	processedImg := s.ProcessImageData(img)
+	processedImg, processErr := s.ProcessImageData(ctx, img)
+	if processErr != nil {
+		return fmt.Errorf("failed to process image: %w", processErr)
+	}
 
-	if saveErr := s.SaveTiles(processedImg, imagePath); saveErr != nil {
+	if saveErr := s.SaveTiles(ctx, processedImg, imagePath); saveErr != nil {
 		return fmt.Errorf("failed to save tiles: %w", saveErr)
 	}
 
 	return nil
 }
 
-// LoadImage loads and decodes an image from file.
-func (s *Service) LoadImage(imagePath string) (*ProcessedImage, error) {
+// LoadImage loads and decodes an image from file. Decoding stops at the next
+// read once ctx is cancelled. Images with an embedded ICC profile other than
+// sRGB, such as Display P3 or Adobe RGB, are converted to sRGB, and images
+// with an EXIF orientation are turned upright unless Config.IgnoreEXIF is set.
+func (s *Service) LoadImage(ctx context.Context, imagePath string) (*ProcessedImage, error) {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+
 	file, openErr := s.fileSystem.Open(imagePath)
 	if openErr != nil {
 		return nil, fmt.Errorf("error opening image: %w", openErr)
 	}
 	defer file.Close()
 
-	img, _, decodeErr := s.decoder.Decode(file)
+	header := &headerRecorder{limit: maxMetadataSize}
+	img, format, decodeErr := s.decoder.Decode(io.TeeReader(&contextReader{ctx: ctx, r: file}, header))
 	if decodeErr != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
 		return nil, fmt.Errorf("error decoding image: %w", decodeErr)
 	}
 
-	return &ProcessedImage{Original: img}, nil
+	procImg := &ProcessedImage{Original: img, Format: format}
+	if data := EmbeddedColorProfile(format, header.data); data != nil {
+		// Profiles that cannot be converted from are ignored, and the
+		// pixels taken as sRGB as with untagged images.
+		if profile, profileErr := ParseColorProfile(data); profileErr == nil && !profile.IsSRGB() {
+			procImg.Original = profile.ConvertToSRGB(img)
+			procImg.ColorProfile = profile.Description
+		}
+	}
+	if orientation := EmbeddedOrientation(format, header.data); orientation.Transforms() && !s.config.IgnoreEXIF {
+		procImg.Original = orientation.Apply(procImg.Original)
+		procImg.Orientation = orientation
+	}
+	return procImg, nil
 }
 
 // ProcessedImage holds an image and its processed versions.
 type ProcessedImage struct {
-	Original image.Image
-	Resized  image.Image
-	Squared  image.Image
-	Result   ProcessingResult
+	Format string
+	// ColorProfile is the description of the ICC profile Original was
+	// converted to sRGB from, empty when it needed no conversion.
+	ColorProfile string
+	// Orientation is the EXIF orientation Original was turned upright from,
+	// 0 when it needed no transformation.
+	Orientation Orientation
+	Original    image.Image
+	// Transformed is Original cropped to Config.SourceRect and transformed by
+	// Config.Transform, nil when the configuration has neither.
+	Transformed image.Image
+	// Transform reports the transform applied to Transformed.
+	Transform *TransformReport
+	Resized   image.Image
+	// Squared is the resized image cropped or padded to the canvas, which is
+	// square for square grids and follows the grid aspect ratio otherwise,
+	// with Config.Adjustments applied to all but the padding.
+	Squared image.Image
+	// Crop is the area of Resized that Squared was cut from.
+	Crop image.Rectangle
+	// Gutters reports the gutter optimization, when Config.OptimizeGutters is set.
+	Gutters *GutterReport
+	Result  ProcessingResult
+	// Saved lists the tile files written by SaveTiles, in tile order.
+	Saved []SavedTile
+}
+
+// Source returns the image that is fitted to the canvas: Transformed when the
+// image was cropped or transformed, otherwise Original.
+func (p *ProcessedImage) Source() image.Image {
+	if p.Transformed != nil {
+		return p.Transformed
+	}
+	return p.Original
+}
+
+// SavedTile describes a tile file written by SaveTiles.
+type SavedTile struct {
+	Coord TileCoordinate
+	Path  string
+	// Size is the file size in bytes.
+	Size int64
 }
 
-// ProcessImageData handles the core image processing logic.
-func (s *Service) ProcessImageData(procImg *ProcessedImage) *ProcessedImage {
-	procImg.Resized = ResizeImage(procImg.Original, s.config.TargetSize, s.resizer)
-	procImg.Squared = CropToSquare(procImg.Resized, s.config.TargetSize)
+// ProcessImageData handles the core image processing logic. It returns the
+// context error when ctx is cancelled before it is done.
+func (s *Service) ProcessImageData(ctx context.Context, procImg *ProcessedImage) (*ProcessedImage, error) {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+
+	resizer := &contextResizer{ctx: ctx, resizer: s.resizer}
+	if s.config.LinearLight {
+		resizer.resizer = LinearLight(s.resizer)
+	}
+	procImg.Transformed, procImg.Transform = nil, nil
+	if s.config.SourceRect != nil {
+		cropped, cropErr := CropSourceRect(procImg.Original, *s.config.SourceRect)
+		if cropErr != nil {
+			return nil, cropErr
+		}
+		procImg.Transformed = cropped
+	}
+	if !s.config.Transform.IsZero() {
+		transformed, report := s.config.Transform.Apply(procImg.Source(), s.config.Fill)
+		procImg.Transformed, procImg.Transform = transformed, &report
+	}
+
+	canvas := s.config.CanvasSize()
+	procImg.Resized, procImg.Squared, procImg.Crop = FitToCanvas(
+		procImg.Source(), canvas.X, canvas.Y, s.config, resizer)
+	if s.config.OptimizeGutters {
+		var report GutterReport
+		procImg.Resized, procImg.Crop, report = OptimizeGutters(
+			procImg.Source(), procImg.Resized, procImg.Crop, s.config, resizer)
+		if report.Moved() {
+			procImg.Squared = CropAt(procImg.Resized, canvas.X, canvas.Y, procImg.Crop.Min)
+		}
+		procImg.Gutters = &report
+	}
+	// The resizes of a cancelled context return blank images, so the result
+	// above cannot be used.
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+
+	// The padding keeps Config.Fill: only the area of Resized is adjusted.
+	area := procImg.Resized.Bounds().Sub(procImg.Crop.Min).Add(procImg.Squared.Bounds().Min)
+	procImg.Squared = s.config.Adjustments.applyWithin(procImg.Squared, area)
 	procImg.Result = SplitIntoTiles(procImg.Squared, s.config)
-	return procImg
+	return procImg, nil
 }
 
-// SaveTiles saves all tiles to disk.
-func (s *Service) SaveTiles(procImg *ProcessedImage, originalPath string) error {
-	baseDir := filepath.Dir(originalPath)
-	fileName := strings.TrimSuffix(filepath.Base(originalPath), filepath.Ext(originalPath))
+// SaveTiles saves all tiles to disk and records them in procImg.Saved.
+// Up to Config.EncodeJobs tiles are encoded at the same time, to temporary
+// files that are renamed into place only once all of them succeeded, so a
+// failure leaves existing tiles untouched. A failed rename restores the
+// tiles already replaced, so the tiles on disk are never a mix of old and
+// new ones. The error joins the failure of every tile, in tile order.
+// Cancelling ctx while the tiles are encoded removes the temporary files and
+// returns the context error; the renames are not interrupted.
+//
+// Config.NoClobber is checked before the tiles are encoded, so a tile file
+// that another process creates in the meantime is still replaced.
+func (s *Service) SaveTiles(ctx context.Context, procImg *ProcessedImage, originalPath string) error {
+	coords := procImg.Result.TileCoords
+	paths := make([]string, len(procImg.Result.Tiles))
+	temps := make([]string, len(paths))
+	for i, coord := range coords {
+		paths[i] = s.TileOutputPath(originalPath, coord)
+		temps[i] = temporaryPath(paths[i])
+	}
+	if clobberErr := s.checkClobber(paths...); clobberErr != nil {
+		return clobberErr
+	}
 
-	for i, tile := range procImg.Result.Tiles {
-		coord := procImg.Result.TileCoords[i]
-		outputPath := filepath.Join(baseDir, fmt.Sprintf("%s_%d.png", fileName, coord.Number))
+	sizes := make([]int64, len(paths))
+	saveErrs := make([]error, len(paths))
+	parallelFor(len(paths), s.config.EncodeJobs, func(i int) {
+		sizes[i], saveErrs[i] = s.writeImage(ctx, procImg.Result.Tiles[i], temps[i], "tile")
+	})
 
-		if saveErr := s.SaveTile(tile, outputPath); saveErr != nil {
-			return fmt.Errorf("error saving tile %d: %w", coord.Number, saveErr)
+	var failures []error
+	for i, saveErr := range saveErrs {
+		if saveErr != nil {
+			failures = append(failures, fmt.Errorf("error saving tile %d: %w", coords[i].Number, saveErr))
+		}
+	}
+	ctxErr := ctx.Err()
+	if len(failures) > 0 || ctxErr != nil {
+		s.discard(temps...)
+		if ctxErr != nil {
+			return ctxErr
 		}
+		return errors.Join(failures...)
 	}
 
+	if commitErr := s.commit(temps, paths); commitErr != nil {
+		return fmt.Errorf("error saving tiles: %w", commitErr)
+	}
+	saved := make([]SavedTile, len(paths))
+	for i, coord := range coords {
+		saved[i] = SavedTile{Coord: coord, Path: paths[i], Size: sizes[i]}
+	}
+	procImg.Saved = saved
 	return nil
 }
 
 // SaveTile saves a single tile to disk.
-func (s *Service) SaveTile(tile image.Image, outputPath string) error {
+func (s *Service) SaveTile(ctx context.Context, tile image.Image, outputPath string) error {
+	return s.saveFile(ctx, tile, outputPath, "tile")
+}
+
+// SaveImage saves a composed image, such as a preview or a joined grid, to disk.
+func (s *Service) SaveImage(ctx context.Context, img image.Image, outputPath string) error {
+	return s.saveFile(ctx, img, outputPath, "image")
+}
+
+// saveFile writes img to a temporary file and renames it to outputPath.
+func (s *Service) saveFile(ctx context.Context, img image.Image, outputPath, kind string) error {
+	if clobberErr := s.checkClobber(outputPath); clobberErr != nil {
+		return clobberErr
+	}
+
+	temp := temporaryPath(outputPath)
+	if _, saveErr := s.writeImage(ctx, img, temp, kind); saveErr != nil {
+		s.discard(temp)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return saveErr
+	}
+	return s.commit([]string{temp}, []string{outputPath})
+}
+
+// checkClobber fails with fs.ErrExist when Config.NoClobber is set and one
+// of the paths exists.
+func (s *Service) checkClobber(paths ...string) error {
+	if !s.config.NoClobber {
+		return nil
+	}
+	for _, path := range paths {
+		_, statErr := s.fileSystem.Stat(path)
+		switch {
+		case statErr == nil:
+			return fmt.Errorf("refusing to overwrite %s: %w", path, fs.ErrExist)
+		case !errors.Is(statErr, fs.ErrNotExist):
+			return fmt.Errorf("error checking %s: %w", path, statErr)
+		}
+	}
+	return nil
+}
+
+// commit renames the temporary files to their final paths as a set: the
+// existing files are first moved aside to backups, and when a rename fails the
+// files already renamed are removed and the backups are moved back, so the
+// paths end up holding either every new file or every old one. The remaining
+// temporary files are removed on failure, and the backups on success.
+func (s *Service) commit(temps, paths []string) error {
+	backups := make([]string, len(paths))
+	for i, path := range paths {
+		_, moveErr := s.fileSystem.Stat(path)
+		if errors.Is(moveErr, fs.ErrNotExist) {
+			continue
+		}
+		if moveErr == nil {
+			moveErr = s.fileSystem.Rename(path, backupPath(path))
+		}
+		if moveErr != nil {
+			return s.rollback(temps, paths, backups, 0, fmt.Errorf("error moving %s aside: %w", path, moveErr))
+		}
+		backups[i] = backupPath(path)
+	}
+
+	for i, temp := range temps {
+		if renameErr := s.fileSystem.Rename(temp, paths[i]); renameErr != nil {
+			return s.rollback(temps, paths, backups, i, fmt.Errorf("error renaming output file: %w", renameErr))
+		}
+	}
+	for _, backup := range backups {
+		if backup != "" {
+			s.discard(backup)
+		}
+	}
+	return nil
+}
+
+// rollback undoes a commit that failed with commitErr once the first renamed
+// temporary files were in place: it removes those that had no backup, moves
+// the backups back and removes the other temporary files. It returns
+// commitErr joined with every failure to restore a file.
+func (s *Service) rollback(temps, paths, backups []string, renamed int, commitErr error) error {
+	errs := []error{commitErr}
+	for i := range renamed {
+		if backups[i] == "" {
+			if removeErr := s.fileSystem.Remove(paths[i]); removeErr != nil {
+				errs = append(errs, fmt.Errorf("error removing %s: %w", paths[i], removeErr))
+			}
+		}
+	}
+	for i, backup := range backups {
+		if backup == "" {
+			continue
+		}
+		if restoreErr := s.fileSystem.Rename(backup, paths[i]); restoreErr != nil {
+			errs = append(errs, fmt.Errorf("error restoring %s from %s: %w", paths[i], backup, restoreErr))
+		}
+	}
+	s.discard(temps[renamed:]...)
+	return errors.Join(errs...)
+}
+
+// discard removes temporary files, ignoring errors since they may not exist.
+func (s *Service) discard(temps ...string) {
+	for _, temp := range temps {
+		_ = s.fileSystem.Remove(temp)
+	}
+}
+
+// temporaryPath returns the hidden file next to path an image is encoded to
+// before it is renamed into place.
+func temporaryPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+}
+
+// backupPath returns the hidden file next to path an existing file is moved
+// to while it is replaced.
+func backupPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".bak")
+}
+
+// writeImage encodes img to outputPath and returns the number of bytes
+// written. The encoder fails at its next write once ctx is cancelled.
+func (s *Service) writeImage(ctx context.Context, img image.Image, outputPath, kind string) (int64, error) {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return 0, ctxErr
+	}
+	if dir := filepath.Dir(outputPath); dir != "." {
+		if mkdirErr := s.fileSystem.MkdirAll(dir, outputDirPerm); mkdirErr != nil {
+			return 0, fmt.Errorf("error creating output directory: %w", mkdirErr)
+		}
+	}
+
 	outputFile, createErr := s.fileSystem.Create(outputPath)
 	if createErr != nil {
-		return fmt.Errorf("error creating output file: %w", createErr)
+		return 0, fmt.Errorf("error creating output file: %w", createErr)
 	}
-	defer outputFile.Close()
 
-	if encodeErr := s.encoder.Encode(outputFile, tile); encodeErr != nil {
-		return fmt.Errorf("error encoding tile: %w", encodeErr)
+	counter := &countingWriter{w: &contextWriter{ctx: ctx, w: outputFile}}
+	if encodeErr := s.encoder.Encode(counter, img); encodeErr != nil {
+		_ = outputFile.Close()
+		return 0, fmt.Errorf("error encoding %s: %w", kind, encodeErr)
+	}
+	// A failed close can mean the data never reached the disk.
+	if closeErr := outputFile.Close(); closeErr != nil {
+		return 0, fmt.Errorf("error writing output file: %w", closeErr)
 	}
 
-	return nil
+	return counter.n, nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, writeErr := c.w.Write(p)
+	c.n += int64(n)
+	return n, writeErr
 }
@@ -1,6 +1,7 @@
 package processor_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"image"
@@ -18,18 +19,20 @@ func TestService_LoadImage_Success(t *testing.T) {
 	fs := processor.NewTestMockFileSystem()
 	testImg := processor.CreateTestImage(100, 100)
 	decoder := processor.NewTestMockImageDecoder(testImg, "jpeg", nil)
-	service := processor.NewServiceWithDeps(fs, decoder, nil, nil, processor.DefaultConfig())
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, nil, nil, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
 
 	fs.AddFile("/test/image.jpg", []byte("fake jpeg data"))
 
 	// Execute
-	result, loadErr := service.LoadImage("/test/image.jpg")
+	result, loadErr := service.LoadImage(context.Background(), "/test/image.jpg")
 
 	// Assert
 	require.NoError(t, loadErr)
 	assert.NotNil(t, result)
 	assert.NotNil(t, result.Original)
 	assert.Equal(t, testImg, result.Original)
+	assert.Equal(t, "jpeg", result.Format)
 }
 
 func TestService_LoadImage_FileNotFound(t *testing.T) {
@@ -39,10 +42,11 @@ func TestService_LoadImage_FileNotFound(t *testing.T) {
 		return nil, errors.New("file not found")
 	}
 	decoder := processor.NewTestMockImageDecoder(nil, "", nil)
-	service := processor.NewServiceWithDeps(fs, decoder, nil, nil, processor.DefaultConfig())
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, nil, nil, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
 
 	// Execute
-	result, loadErr := service.LoadImage("/nonexistent/image.jpg")
+	result, loadErr := service.LoadImage(context.Background(), "/nonexistent/image.jpg")
 
 	// Assert
 	require.Error(t, loadErr)
@@ -54,12 +58,13 @@ func TestService_LoadImage_DecodeError(t *testing.T) {
 	// Setup
 	fs := processor.NewTestMockFileSystem()
 	decoder := processor.NewTestMockImageDecoder(nil, "", errors.New("invalid image format"))
-	service := processor.NewServiceWithDeps(fs, decoder, nil, nil, processor.DefaultConfig())
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, nil, nil, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
 
 	fs.AddFile("/test/corrupt.jpg", []byte("corrupt data"))
 
 	// Execute
-	result, loadErr := service.LoadImage("/test/corrupt.jpg")
+	result, loadErr := service.LoadImage(context.Background(), "/test/corrupt.jpg")
 
 	// Assert
 	require.Error(t, loadErr)
@@ -76,12 +81,14 @@ func TestService_ProcessImageData(t *testing.T) {
 		TileSize:   90,
 		Spacing:    10,
 	}
-	service := processor.NewServiceWithDeps(nil, nil, nil, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, resizer, config)
+	require.NoError(t, serviceErr)
 	testImg := processor.CreateTestImage(300, 200)
 	procImg := &processor.ProcessedImage{Original: testImg}
 
 	// Execute
-	result := service.ProcessImageData(procImg)
+	result, processErr := service.ProcessImageData(context.Background(), procImg)
+	require.NoError(t, processErr)
 
 	// Assert
 	assert.NotNil(t, result)
@@ -95,11 +102,12 @@ func TestService_SaveTile_Success(t *testing.T) {
 	// Setup
 	fs := processor.NewTestMockFileSystem()
 	encoder := processor.NewTestMockImageEncoder(nil)
-	service := processor.NewServiceWithDeps(fs, nil, encoder, nil, processor.DefaultConfig())
+	service, serviceErr := processor.NewServiceWithDeps(fs, nil, encoder, nil, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
 	testImg := processor.CreateTestImage(100, 100)
 
 	// Execute
-	saveErr := service.SaveTile(testImg, "/test/tile.png")
+	saveErr := service.SaveTile(context.Background(), testImg, "/test/tile.png")
 
 	// Assert
 	require.NoError(t, saveErr)
@@ -115,11 +123,12 @@ func TestService_SaveTile_CreateFileError(t *testing.T) {
 		return nil, errors.New("permission denied")
 	}
 	encoder := processor.NewTestMockImageEncoder(nil)
-	service := processor.NewServiceWithDeps(fs, nil, encoder, nil, processor.DefaultConfig())
+	service, serviceErr := processor.NewServiceWithDeps(fs, nil, encoder, nil, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
 	testImg := processor.CreateTestImage(100, 100)
 
 	// Execute
-	saveErr := service.SaveTile(testImg, "/test/tile.png")
+	saveErr := service.SaveTile(context.Background(), testImg, "/test/tile.png")
 
 	// Assert
 	require.Error(t, saveErr)
@@ -130,11 +139,12 @@ func TestService_SaveTile_EncodeError(t *testing.T) {
 	// Setup
 	fs := processor.NewTestMockFileSystem()
 	encoder := processor.NewTestMockImageEncoder(errors.New("encoding failed"))
-	service := processor.NewServiceWithDeps(fs, nil, encoder, nil, processor.DefaultConfig())
+	service, serviceErr := processor.NewServiceWithDeps(fs, nil, encoder, nil, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
 	testImg := processor.CreateTestImage(100, 100)
 
 	// Execute
-	saveErr := service.SaveTile(testImg, "/test/tile.png")
+	saveErr := service.SaveTile(context.Background(), testImg, "/test/tile.png")
 
 	// Assert
 	require.Error(t, saveErr)
@@ -145,7 +155,8 @@ func TestService_SaveTiles_Success(t *testing.T) {
 	// Setup
 	fs := processor.NewTestMockFileSystem()
 	encoder := processor.NewTestMockImageEncoder(nil)
-	service := processor.NewServiceWithDeps(fs, nil, encoder, nil, processor.DefaultConfig())
+	service, serviceErr := processor.NewServiceWithDeps(fs, nil, encoder, nil, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
 
 	// Create processed image with 2x2 grid of tiles
 	procImg := &processor.ProcessedImage{
@@ -166,7 +177,7 @@ func TestService_SaveTiles_Success(t *testing.T) {
 	}
 
 	// Execute
-	saveErr := service.SaveTiles(procImg, "/test/original.jpg")
+	saveErr := service.SaveTiles(context.Background(), procImg, "/test/original.jpg")
 
 	// Assert
 	require.NoError(t, saveErr)
@@ -189,7 +200,8 @@ func TestService_SaveTiles_TileError(t *testing.T) {
 	// Setup
 	fs := processor.NewTestMockFileSystem()
 	encoder := processor.NewTestMockImageEncoder(errors.New("encoding failed"))
-	service := processor.NewServiceWithDeps(fs, nil, encoder, nil, processor.DefaultConfig())
+	service, serviceErr := processor.NewServiceWithDeps(fs, nil, encoder, nil, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
 
 	procImg := &processor.ProcessedImage{
 		Result: processor.ProcessingResult{
@@ -201,7 +213,7 @@ func TestService_SaveTiles_TileError(t *testing.T) {
 	}
 
 	// Execute
-	saveErr := service.SaveTiles(procImg, "/test/original.jpg")
+	saveErr := service.SaveTiles(context.Background(), procImg, "/test/original.jpg")
 
 	// Assert
 	require.Error(t, saveErr)
@@ -217,11 +229,12 @@ func TestService_ProcessImage_FullWorkflow(t *testing.T) {
 	resizer := processor.NewTestMockImageResizer()
 	config := processor.DefaultConfig()
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 	fs.AddFile("/test/image.jpg", []byte("fake jpeg data"))
 
 	// Execute
-	processErr := service.ProcessImage("/test/image.jpg")
+	processErr := service.ProcessImage(context.Background(), "/test/image.jpg")
 
 	// Assert
 	require.NoError(t, processErr)
@@ -234,3 +247,73 @@ func TestService_ProcessImage_FullWorkflow(t *testing.T) {
 		assert.Equal(t, "fake png data", string(data))
 	}
 }
+
+func TestService_WithConfig(t *testing.T) {
+	// Setup
+	fs := processor.NewTestMockFileSystem()
+	service, serviceErr := processor.NewServiceWithDeps(fs, nil, nil, nil, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+	config := processor.DefaultConfig()
+	config.GridSize = 2
+
+	// Execute
+	configured, configErr := service.WithConfig(config)
+
+	// Assert - the original service is left untouched
+	require.NoError(t, configErr)
+	assert.Equal(t, 2, configured.Config().GridSize)
+	assert.Equal(t, 3, service.Config().GridSize)
+}
+
+func TestService_TileOutputPath(t *testing.T) {
+	coord := processor.TileCoordinate{Row: 1, Col: 2, Number: 6}
+
+	testCases := []struct {
+		name      string
+		outputDir string
+		expected  string
+	}{
+		{"next to input", "", "/photos/sunset_6.png"},
+		{"output directory", "/keys", "/keys/sunset_6.png"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := processor.DefaultConfig()
+			config.OutputDir = tc.outputDir
+			service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, nil, config)
+			require.NoError(t, serviceErr)
+
+			assert.Equal(t, tc.expected, service.TileOutputPath("/photos/sunset.jpg", coord))
+		})
+	}
+}
+
+func TestService_SaveImage_EncodeError(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	encoder := processor.NewTestMockImageEncoder(errors.New("encoding failed"))
+	service, serviceErr := processor.NewServiceWithDeps(fs, nil, encoder, nil, processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+
+	saveErr := service.SaveImage(context.Background(), processor.CreateTestImage(10, 10), "/test/preview.png")
+
+	require.Error(t, saveErr)
+	assert.Contains(t, saveErr.Error(), "error encoding image")
+}
+
+func TestService_ProcessImageData_RectangularGrid(t *testing.T) {
+	// Setup
+	config := processor.Config{Columns: 5, Rows: 3, TileSize: 72, Spacing: 24}
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, &processor.LanczosResizer{}, config)
+	require.NoError(t, serviceErr)
+	procImg := &processor.ProcessedImage{Original: processor.CreateTestImage(1920, 1080)}
+
+	// Execute
+	result, processErr := service.ProcessImageData(context.Background(), procImg)
+	require.NoError(t, processErr)
+
+	// Assert
+	assert.Equal(t, image.Rect(0, 0, 470, 264), result.Resized.Bounds())
+	assert.Equal(t, image.Rect(0, 0, 456, 264), result.Squared.Bounds())
+	assert.Len(t, result.Result.Tiles, 15)
+}
@@ -1,8 +1,13 @@
 package processor_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"image"
 	"io"
+	iofs "io/fs"
+	"path"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -23,7 +28,8 @@ func TestNewServiceWithDeps(t *testing.T) {
 	resizer := processor.NewTestMockImageResizer()
 	config := processor.DefaultConfig()
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 	assert.NotNil(t, service)
 }
 
@@ -39,9 +45,10 @@ func TestService_ProcessImage_Success(t *testing.T) {
 	// Add a fake image file
 	fs.AddFile("/test/image.jpg", []byte("fake jpeg data"))
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 
-	err := service.ProcessImage("/test/image.jpg")
+	err := service.ProcessImage(context.Background(), "/test/image.jpg")
 	require.NoError(t, err)
 
 	// Verify that 9 tile files were created
@@ -74,9 +81,10 @@ func TestService_ProcessImage_FileOpenError(t *testing.T) {
 	resizer := processor.NewTestMockImageResizer()
 	config := processor.DefaultConfig()
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 
-	err := service.ProcessImage("/nonexistent/image.jpg")
+	err := service.ProcessImage(context.Background(), "/nonexistent/image.jpg")
 
 	require.Error(t, err)
 
@@ -93,9 +101,10 @@ func TestService_ProcessImage_DecodeError(t *testing.T) {
 	resizer := processor.NewTestMockImageResizer()
 	config := processor.DefaultConfig()
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 
-	err := service.ProcessImage("/test/image.jpg")
+	err := service.ProcessImage(context.Background(), "/test/image.jpg")
 
 	require.Error(t, err)
 
@@ -113,9 +122,10 @@ func TestService_ProcessImage_EncodeError(t *testing.T) {
 
 	fs.AddFile("/test/image.jpg", []byte("fake jpeg data"))
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 
-	err := service.ProcessImage("/test/image.jpg")
+	err := service.ProcessImage(context.Background(), "/test/image.jpg")
 
 	require.Error(t, err)
 
@@ -137,9 +147,10 @@ func TestService_ProcessImage_CreateFileError(t *testing.T) {
 
 	fs.AddFile("/test/image.jpg", []byte("fake jpeg data"))
 
-	service := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, resizer, config)
+	require.NoError(t, serviceErr)
 
-	err := service.ProcessImage("/test/image.jpg")
+	err := service.ProcessImage(context.Background(), "/test/image.jpg")
 
 	require.Error(t, err)
 
@@ -150,3 +161,221 @@ func TestService_ProcessImage_CreateFileError(t *testing.T) {
 // Skip testing loadImage since it's an internal method and covered by ProcessImage tests
 
 // Skip testing processImage since it's an internal method and covered by ProcessImage tests
+
+func newSaveTestService(t *testing.T, fs processor.FileSystem, encoder processor.ImageEncoder, config processor.Config) (
+	*processor.Service, *processor.ProcessedImage,
+) {
+	t.Helper()
+
+	decoder := processor.NewTestMockImageDecoder(processor.CreateTestImage(400, 300), "jpeg", nil)
+	service, serviceErr := processor.NewServiceWithDeps(fs, decoder, encoder, processor.NewTestMockImageResizer(), config)
+	require.NoError(t, serviceErr)
+	procImg, processErr := service.ProcessImageData(context.Background(),
+		&processor.ProcessedImage{Original: processor.CreateTestImage(400, 300)})
+	require.NoError(t, processErr)
+	return service, procImg
+}
+
+func TestService_SaveTiles_FailureKeepsExistingTiles(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	encoder := processor.NewTestMockImageEncoder(nil)
+	service, procImg := newSaveTestService(t, fs, encoder, processor.DefaultConfig())
+	require.NoError(t, service.SaveTiles(context.Background(), procImg, "/test/image.jpg"))
+	previous := fs.WrittenFiles()
+
+	// The second run fails on the fifth tile.
+	failing := image.NewGray(image.Rect(0, 0, 1, 1))
+	procImg.Result.Tiles[4] = failing
+	encoder.EncodeFunc = func(w io.Writer, img image.Image) error {
+		if img == failing {
+			return errors.New("disk full")
+		}
+		_, writeErr := w.Write([]byte("new tile"))
+		return writeErr
+	}
+	saveErr := service.SaveTiles(context.Background(), procImg, "/test/image.jpg")
+
+	require.Error(t, saveErr)
+	assert.Contains(t, saveErr.Error(), "error saving tile 5")
+	assert.Equal(t, previous, fs.WrittenFiles(), "temporary files must be removed")
+	for _, name := range previous {
+		content, _ := fs.GetWrittenFile(name)
+		assert.Equal(t, "fake png data", string(content), "%s must keep its previous content", name)
+	}
+}
+
+func TestService_SaveTiles_ParallelJoinsErrorsInTileOrder(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	encoder := processor.NewTestMockImageEncoder(nil)
+	config := processor.DefaultConfig()
+	config.EncodeJobs = 4
+	service, procImg := newSaveTestService(t, fs, encoder, config)
+
+	// Tiles 2 and 7 fail, and tile 2 waits for tile 7 to fail first.
+	failing := map[image.Image]int{}
+	for _, number := range []int{2, 7} {
+		tile := image.NewGray(image.Rect(0, 0, 1, 1))
+		procImg.Result.Tiles[number-1] = tile
+		failing[tile] = number
+	}
+	tile7Failed := make(chan struct{})
+	encoder.EncodeFunc = func(w io.Writer, img image.Image) error {
+		switch failing[img] {
+		case 2:
+			<-tile7Failed
+			return errors.New("bad tile 2")
+		case 7:
+			close(tile7Failed)
+			return errors.New("bad tile 7")
+		}
+		_, writeErr := w.Write([]byte("tile"))
+		return writeErr
+	}
+	saveErr := service.SaveTiles(context.Background(), procImg, "/test/image.jpg")
+	require.Error(t, saveErr)
+	assert.Equal(t, "error saving tile 2: error encoding tile: bad tile 2\n"+
+		"error saving tile 7: error encoding tile: bad tile 7", saveErr.Error())
+	assert.Empty(t, fs.WrittenFiles(), "temporary files must be removed")
+	assert.Empty(t, procImg.Saved)
+}
+
+func TestService_SaveTiles_ParallelRecordsTilesInOrder(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	encoder := processor.NewTestMockImageEncoder(nil)
+	config := processor.DefaultConfig()
+	config.EncodeJobs = 4
+	service, procImg := newSaveTestService(t, fs, encoder, config)
+	// Every tile is written with its own size, so that sizes mixed up
+	// between tiles would show.
+	sizes := map[image.Image]int{}
+	for i := range procImg.Result.Tiles {
+		procImg.Result.Tiles[i] = image.NewGray(image.Rect(0, 0, 1, 1))
+		sizes[procImg.Result.Tiles[i]] = i + 1
+	}
+	encoder.EncodeFunc = func(w io.Writer, img image.Image) error {
+		_, writeErr := w.Write(make([]byte, sizes[img]))
+		return writeErr
+	}
+
+	require.NoError(t, service.SaveTiles(context.Background(), procImg, "/test/image.jpg"))
+
+	require.Len(t, procImg.Saved, 9)
+	for i, saved := range procImg.Saved {
+		assert.Equal(t, i+1, saved.Coord.Number)
+		assert.Equal(t, fmt.Sprintf("/test/image_%d.png", i+1), saved.Path)
+		assert.Equal(t, int64(i+1), saved.Size)
+		content, _ := fs.GetWrittenFile(saved.Path)
+		assert.Len(t, content, i+1)
+	}
+}
+
+func TestService_SaveTiles_RenameFailureRemovesTemporaryFiles(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	fs.RenameFunc = func(_, _ string) error { return errors.New("cross-device link") }
+	service, procImg := newSaveTestService(t, fs, processor.NewTestMockImageEncoder(nil), processor.DefaultConfig())
+
+	saveErr := service.SaveTiles(context.Background(), procImg, "/test/image.jpg")
+
+	require.Error(t, saveErr)
+	assert.Contains(t, saveErr.Error(), "cross-device link")
+	assert.Empty(t, fs.WrittenFiles())
+	assert.Empty(t, procImg.Saved)
+}
+
+// failingRenameFileSystem fails to rename the file whose base name is failOn.
+type failingRenameFileSystem struct {
+	*processor.TestMockFileSystem
+	failOn string
+}
+
+func (f *failingRenameFileSystem) Rename(oldPath, newPath string) error {
+	if path.Base(oldPath) == f.failOn {
+		return errors.New("disk full")
+	}
+	return f.TestMockFileSystem.Rename(oldPath, newPath)
+}
+
+func TestService_SaveTiles_PartialRenameFailureKeepsPreviousTiles(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	for i := 1; i <= 8; i++ {
+		fs.AddFile(fmt.Sprintf("/test/image_%d.png", i), []byte(fmt.Sprintf("old tile %d", i)))
+	}
+	// The fifth tile fails to replace its target.
+	failing := &failingRenameFileSystem{TestMockFileSystem: fs, failOn: ".image_5.png.tmp"}
+	service, procImg := newSaveTestService(t, failing, processor.NewTestMockImageEncoder(nil), processor.DefaultConfig())
+
+	saveErr := service.SaveTiles(context.Background(), procImg, "/test/image.jpg")
+
+	require.Error(t, saveErr)
+	assert.Contains(t, saveErr.Error(), "disk full")
+	for i := 1; i <= 8; i++ {
+		content, exists := fs.GetWrittenFile(fmt.Sprintf("/test/image_%d.png", i))
+		require.True(t, exists, "tile %d is restored", i)
+		assert.Equal(t, fmt.Sprintf("old tile %d", i), string(content))
+	}
+	_, statErr := fs.Stat("/test/image_9.png")
+	require.ErrorIs(t, statErr, iofs.ErrNotExist, "a tile that did not exist is removed again")
+	assert.Len(t, fs.WrittenFiles(), 8, "no temporary or backup file is left")
+	assert.Empty(t, procImg.Saved)
+}
+
+func TestService_SaveTiles_NoClobber(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	fs.AddFile("/test/image_5.png", []byte("existing tile"))
+	config := processor.DefaultConfig()
+	config.NoClobber = true
+	service, procImg := newSaveTestService(t, fs, processor.NewTestMockImageEncoder(nil), config)
+
+	saveErr := service.SaveTiles(context.Background(), procImg, "/test/image.jpg")
+
+	require.ErrorIs(t, saveErr, iofs.ErrExist)
+	assert.Contains(t, saveErr.Error(), "refusing to overwrite /test/image_5.png")
+	assert.Empty(t, fs.WrittenFiles(), "no tile may be written when one exists")
+}
+
+func TestService_SaveImage_NoClobber(t *testing.T) {
+	fs := processor.NewTestMockFileSystem()
+	config := processor.DefaultConfig()
+	config.NoClobber = true
+	service, _ := newSaveTestService(t, fs, processor.NewTestMockImageEncoder(nil), config)
+
+	require.NoError(t, service.SaveImage(context.Background(), processor.CreateTestImage(4, 4), "/test/preview.png"))
+	saveErr := service.SaveImage(context.Background(), processor.CreateTestImage(4, 4), "/test/preview.png")
+
+	require.ErrorIs(t, saveErr, iofs.ErrExist)
+	assert.Equal(t, []string{"/test/preview.png"}, fs.WrittenFiles())
+}
+
+func BenchmarkService_SaveTiles(b *testing.B) {
+	config := processor.DefaultConfig()
+	config.TileSize = 400
+	config.TargetSize = processor.AutoTargetSize
+	canvas := config.Normalized().CanvasSize()
+	tiles := processor.SplitIntoTiles(createNoiseImage(canvas.X, canvas.Y), config.Normalized())
+
+	for _, bc := range []struct {
+		name string
+		jobs int
+	}{
+		{name: "sequential", jobs: 1},
+		{name: "parallel", jobs: 0},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			config.EncodeJobs = bc.jobs
+			service, serviceErr := processor.NewServiceWithDeps(processor.NewTestMockFileSystem(),
+				processor.NewTestMockImageDecoder(nil, "", nil), &processor.PNGEncoder{},
+				processor.NewTestMockImageResizer(), config)
+			require.NoError(b, serviceErr)
+			procImg := &processor.ProcessedImage{Result: tiles}
+
+			// Throughput is reported in MB/s of RGBA pixels encoded.
+			b.SetBytes(int64(len(tiles.Tiles) * config.TileSize * config.TileSize * 4))
+			b.ResetTimer()
+			for range b.N {
+				if saveErr := service.SaveTiles(context.Background(), procImg, "/bench/image.png"); saveErr != nil {
+					b.Fatal(saveErr)
+				}
+			}
+		})
+	}
+}
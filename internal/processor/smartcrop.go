@@ -0,0 +1,244 @@
+package processor
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+const (
+	// smartCropAnalysisSize is the longer side, in cells, of the grid the
+	// image is averaged into before candidate windows are scored.
+	smartCropAnalysisSize = 256
+	// smartCropBins is the number of luminance buckets used for entropy.
+	smartCropBins = 16
+	// maxGradient is the largest central-difference gradient of a luminance in [0, 1].
+	maxGradient = 2.0
+	// scoreEpsilon makes near-equal scores tie so the centered window wins.
+	scoreEpsilon = 1e-9
+
+	// Rec. 601 luma coefficients.
+	lumaRed   = 0.299
+	lumaGreen = 0.587
+	lumaBlue  = 0.114
+
+	defaultEdgeWeight       = 1.0
+	defaultEntropyWeight    = 1.0
+	defaultSaturationWeight = 0.5
+
+	debugDimDivisor     = 3
+	debugOutlineDivisor = 200
+)
+
+// SmartCropper is a CropStrategy that scores every candidate window by its
+// edge density, luminance entropy and colour saturation and keeps the most
+// interesting one. Ties go to the window closest to the center.
+type SmartCropper struct {
+	EdgeWeight       float64
+	EntropyWeight    float64
+	SaturationWeight float64
+}
+
+// NewSmartCropper creates a SmartCropper with the default weights.
+func NewSmartCropper() *SmartCropper {
+	return &SmartCropper{
+		EdgeWeight:       defaultEdgeWeight,
+		EntropyWeight:    defaultEntropyWeight,
+		SaturationWeight: defaultSaturationWeight,
+	}
+}
+
+// CropOrigin implements CropStrategy.
+func (c *SmartCropper) CropOrigin(img image.Image, width, height int) image.Point {
+	bounds := img.Bounds()
+	if bounds.Dx() <= width && bounds.Dy() <= height {
+		return GravityOrigin(bounds, width, height, GravityCenter)
+	}
+
+	features := analyzeImage(img)
+	step := features.step
+	windowCols := max(1, min(features.cols, width/step))
+	windowRows := max(1, min(features.rows, height/step))
+	centerX := float64(features.cols-windowCols) / centerDivisor
+	centerY := float64(features.rows-windowRows) / centerDivisor
+
+	// Start from the centered window so that flat images crop like GravityCenter.
+	center := image.Point{X: int(math.Round(centerX)), Y: int(math.Round(centerY))}
+	best, bestScore, bestDistance := center, c.score(features, windowAt(center, windowCols, windowRows)), 0.0
+	for y := 0; y+windowRows <= features.rows; y++ {
+		for x := 0; x+windowCols <= features.cols; x++ {
+			score := c.score(features, windowAt(image.Point{X: x, Y: y}, windowCols, windowRows))
+			distance := math.Hypot(float64(x)-centerX, float64(y)-centerY)
+			if score > bestScore+scoreEpsilon || (score > bestScore-scoreEpsilon && distance < bestDistance) {
+				best, bestScore, bestDistance = image.Point{X: x, Y: y}, score, distance
+			}
+		}
+	}
+
+	if best == center {
+		return GravityOrigin(bounds, width, height, GravityCenter)
+	}
+	return clampOrigin(bounds, width, height, bounds.Min.Add(best.Mul(step)))
+}
+
+func windowAt(origin image.Point, cols, rows int) image.Rectangle {
+	return image.Rect(origin.X, origin.Y, origin.X+cols, origin.Y+rows)
+}
+
+func (c *SmartCropper) score(features *imageFeatures, window image.Rectangle) float64 {
+	cells := float64(window.Dx() * window.Dy())
+
+	entropy := 0.0
+	for bin := range smartCropBins {
+		if count := features.bins[bin].sum(window); count > 0 {
+			p := count / cells
+			entropy -= p * math.Log2(p)
+		}
+	}
+
+	return c.EdgeWeight*features.edges.sum(window)/cells/maxGradient +
+		c.EntropyWeight*entropy/math.Log2(smartCropBins) +
+		c.SaturationWeight*features.saturation.sum(window)/cells
+}
+
+// imageFeatures holds summed-area tables of per-cell features of an image
+// averaged into cells of step x step pixels.
+type imageFeatures struct {
+	step       int
+	cols, rows int
+	edges      summedArea
+	saturation summedArea
+	bins       [smartCropBins]summedArea
+}
+
+func analyzeImage(img image.Image) *imageFeatures {
+	bounds := img.Bounds()
+	step := max(1, (max(bounds.Dx(), bounds.Dy())+smartCropAnalysisSize-1)/smartCropAnalysisSize)
+	cols := (bounds.Dx() + step - 1) / step
+	rows := (bounds.Dy() + step - 1) / step
+
+	luminance := make([]float64, cols*rows)
+	saturation := make([]float64, cols*rows)
+	counts := make([]float64, cols*rows)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			cell := (y-bounds.Min.Y)/step*cols + (x-bounds.Min.X)/step
+			pixel := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			r, g, b := float64(pixel.R)/math.MaxUint8, float64(pixel.G)/math.MaxUint8, float64(pixel.B)/math.MaxUint8
+			alpha := float64(pixel.A) / math.MaxUint8
+
+			luminance[cell] += pixelLuma(pixel)
+			if high := max(r, g, b); high > 0 {
+				saturation[cell] += (high - min(r, g, b)) / high * alpha
+			}
+			counts[cell]++
+		}
+	}
+	for cell, count := range counts {
+		luminance[cell] /= count
+		saturation[cell] /= count
+	}
+
+	features := &imageFeatures{step: step, cols: cols, rows: rows}
+	lum := func(x, y int) float64 {
+		return luminance[min(max(y, 0), rows-1)*cols+min(max(x, 0), cols-1)]
+	}
+	edges := make([]float64, cols*rows)
+	bins := make([][]float64, smartCropBins)
+	for bin := range bins {
+		bins[bin] = make([]float64, cols*rows)
+	}
+	for y := range rows {
+		for x := range cols {
+			cell := y*cols + x
+			edges[cell] = math.Abs(lum(x+1, y)-lum(x-1, y)) + math.Abs(lum(x, y+1)-lum(x, y-1))
+			bins[min(int(luminance[cell]*smartCropBins), smartCropBins-1)][cell] = 1
+		}
+	}
+
+	features.edges = newSummedArea(edges, cols, rows)
+	features.saturation = newSummedArea(saturation, cols, rows)
+	for bin := range bins {
+		features.bins[bin] = newSummedArea(bins[bin], cols, rows)
+	}
+	return features
+}
+
+// pixelLuma returns the luminance of a color from 0 to 1, transparent pixels
+// counting as black.
+func pixelLuma(c color.Color) float64 {
+	r, g, b, _ := c.RGBA() // premultiplied by alpha
+	return (lumaRed*float64(r) + lumaGreen*float64(g) + lumaBlue*float64(b)) / math.MaxUint16
+}
+
+// summedArea is a summed-area table answering rectangle sums in constant time.
+type summedArea struct {
+	cols   int
+	values []float64
+}
+
+func newSummedArea(values []float64, cols, rows int) summedArea {
+	table := summedArea{cols: cols + 1, values: make([]float64, (cols+1)*(rows+1))}
+	for y := range rows {
+		rowSum := 0.0
+		for x := range cols {
+			rowSum += values[y*cols+x]
+			table.values[(y+1)*table.cols+x+1] = table.values[y*table.cols+x+1] + rowSum
+		}
+	}
+	return table
+}
+
+func (t summedArea) sum(r image.Rectangle) float64 {
+	at := func(x, y int) float64 { return t.values[y*t.cols+x] }
+	return at(r.Max.X, r.Max.Y) - at(r.Min.X, r.Max.Y) - at(r.Max.X, r.Min.Y) + at(r.Min.X, r.Min.Y)
+}
+
+// CropDebugImage returns the image fitted to the canvas, see
+// ProcessedImage.Source, with everything outside the crop window dimmed and
+// the window outlined, to show what the keypad will display.
+func CropDebugImage(procImg *ProcessedImage, outline color.Color) image.Image {
+	original := procImg.Source().Bounds()
+	resized := procImg.Resized.Bounds()
+
+	scale := func(value, from, to, fromSize, toSize int) int {
+		return to + (value-from)*toSize/fromSize
+	}
+	window := image.Rect(
+		scale(procImg.Crop.Min.X, resized.Min.X, original.Min.X, resized.Dx(), original.Dx()),
+		scale(procImg.Crop.Min.Y, resized.Min.Y, original.Min.Y, resized.Dy(), original.Dy()),
+		scale(procImg.Crop.Max.X, resized.Min.X, original.Min.X, resized.Dx(), original.Dx()),
+		scale(procImg.Crop.Max.Y, resized.Min.Y, original.Min.Y, resized.Dy(), original.Dy()),
+	)
+
+	debug := image.NewRGBA(original)
+	draw.Draw(debug, original, procImg.Source(), original.Min, draw.Src)
+	for y := original.Min.Y; y < original.Max.Y; y++ {
+		for x := original.Min.X; x < original.Max.X; x++ {
+			if !(image.Point{X: x, Y: y}).In(window) {
+				pixel := debug.RGBAAt(x, y)
+				debug.SetRGBA(x, y, color.RGBA{
+					R: pixel.R / debugDimDivisor,
+					G: pixel.G / debugDimDivisor,
+					B: pixel.B / debugDimDivisor,
+					A: pixel.A,
+				})
+			}
+		}
+	}
+
+	thickness := max(1, max(original.Dx(), original.Dy())/debugOutlineDivisor)
+	fill := image.NewUniform(outline)
+	visible := window.Intersect(original)
+	for _, edge := range []image.Rectangle{
+		image.Rect(visible.Min.X, visible.Min.Y, visible.Max.X, visible.Min.Y+thickness),
+		image.Rect(visible.Min.X, visible.Max.Y-thickness, visible.Max.X, visible.Max.Y),
+		image.Rect(visible.Min.X, visible.Min.Y, visible.Min.X+thickness, visible.Max.Y),
+		image.Rect(visible.Max.X-thickness, visible.Min.Y, visible.Max.X, visible.Max.Y),
+	} {
+		draw.Draw(debug, edge, fill, image.Point{}, draw.Src)
+	}
+
+	return debug
+}
@@ -0,0 +1,138 @@
+package processor_test
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+// createPatchedImage returns a flat grey image with a patch drawn by fill.
+func createPatchedImage(width, height int, patch image.Rectangle, fill func(x, y int) color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			c := color.Color(color.RGBA{R: 128, G: 128, B: 128, A: 255})
+			if (image.Point{X: x, Y: y}).In(patch) {
+				c = fill(x, y)
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func checkerboard(x, y int) color.Color {
+	if (x/4+y/4)%2 == 0 {
+		return color.White
+	}
+	return color.Black
+}
+
+func TestSmartCropper_FindsDetail(t *testing.T) {
+	testCases := []struct {
+		name     string
+		width    int
+		height   int
+		patch    image.Rectangle
+		expected image.Point
+	}{
+		{"right end of a panorama", 600, 200, image.Rect(420, 20, 580, 180), image.Pt(400, 0)},
+		{"left end of a panorama", 600, 200, image.Rect(0, 0, 150, 200), image.Pt(0, 0)},
+		{"bottom of a portrait", 200, 600, image.Rect(20, 450, 180, 600), image.Pt(0, 400)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			img := createPatchedImage(tc.width, tc.height, tc.patch, checkerboard)
+
+			origin := processor.NewSmartCropper().CropOrigin(img, 200, 200)
+
+			assert.InDelta(t, tc.expected.X, origin.X, 20)
+			assert.InDelta(t, tc.expected.Y, origin.Y, 20)
+			assert.True(t, image.Rect(0, 0, 200, 200).Add(origin).In(img.Bounds()))
+		})
+	}
+}
+
+func TestSmartCropper_PrefersSaturation(t *testing.T) {
+	img := createPatchedImage(600, 200, image.Rect(30, 50, 130, 150), func(_, _ int) color.Color {
+		return color.RGBA{R: 255, G: 40, A: 255}
+	})
+
+	origin := processor.NewSmartCropper().CropOrigin(img, 200, 200)
+
+	assert.Less(t, origin.X, 100)
+}
+
+func TestSmartCropper_UniformImageIsCentered(t *testing.T) {
+	origin := processor.NewSmartCropper().CropOrigin(processor.CreateTestImage(600, 200), 200, 200)
+
+	assert.Equal(t, image.Pt(200, 0), origin)
+}
+
+func TestSmartCropper_NoSlack(t *testing.T) {
+	img := processor.CreateTestImage(200, 200).(*image.RGBA).SubImage(image.Rect(50, 50, 150, 150))
+
+	assert.Equal(t, image.Pt(50, 50), processor.NewSmartCropper().CropOrigin(img, 100, 100))
+}
+
+func TestService_ProcessImageData_SmartCrop(t *testing.T) {
+	// Setup - detail at the right end of a 3:1 panorama
+	config := processor.Config{GridSize: 2, TileSize: 90, Spacing: 10, CropMode: processor.CropSmart}
+	service, serviceErr := processor.NewServiceWithDeps(nil, nil, nil, &processor.LanczosResizer{}, config)
+	require.NoError(t, serviceErr)
+	original := createPatchedImage(1200, 400, image.Rect(850, 50, 1150, 350), checkerboard)
+
+	// Execute
+	result, processErr := service.ProcessImageData(context.Background(), &processor.ProcessedImage{Original: original})
+	require.NoError(t, processErr)
+
+	// Assert
+	assert.Equal(t, image.Rect(0, 0, 570, 190), result.Resized.Bounds())
+	assert.InDelta(t, 380, result.Crop.Min.X, 20)
+	assert.Equal(t, 190, result.Crop.Dx())
+}
+
+func TestFitToCanvas_CropWindow(t *testing.T) {
+	resizer := &processor.LanczosResizer{}
+
+	_, _, cover := processor.FitToCanvas(processor.CreateTestImage(400, 100), 378, 378, processor.Config{}, resizer)
+	assert.Equal(t, image.Rect(567, 0, 945, 378), cover)
+
+	contain := processor.Config{FitMode: processor.FitContain}
+	_, _, padded := processor.FitToCanvas(processor.CreateTestImage(400, 100), 378, 378, contain, resizer)
+	assert.Equal(t, image.Rect(0, -141, 378, 237), padded)
+}
+
+func TestCropDebugImage(t *testing.T) {
+	procImg := &processor.ProcessedImage{
+		Original: processor.CreateColoredTestImage(400, 100, color.RGBA{R: 240, G: 150, B: 90, A: 255}),
+		Resized:  image.NewRGBA(image.Rect(0, 0, 800, 200)),
+		Crop:     image.Rect(400, 0, 600, 200),
+	}
+	outline := color.NRGBA{B: 255, A: 255}
+
+	debug := processor.CropDebugImage(procImg, outline)
+
+	assert.Equal(t, image.Rect(0, 0, 400, 100), debug.Bounds())
+	assertSameColor(t, color.RGBA{R: 80, G: 50, B: 30, A: 255}, debug.At(100, 50), "outside the window is dimmed")
+	assertSameColor(t, color.RGBA{R: 240, G: 150, B: 90, A: 255}, debug.At(250, 50), "inside the window is kept")
+	assertSameColor(t, outline, debug.At(200, 50), "left edge of the window")
+	assertSameColor(t, outline, debug.At(250, 0), "top edge of the window")
+}
+
+func TestParseCropMode(t *testing.T) {
+	mode, parseErr := processor.ParseCropMode("Smart")
+	require.NoError(t, parseErr)
+	assert.Equal(t, processor.CropSmart, mode)
+
+	_, parseErr = processor.ParseCropMode("magic")
+	require.Error(t, parseErr)
+	assert.Contains(t, parseErr.Error(), "supported are: gravity, smart")
+}
@@ -1,10 +1,21 @@
 package processor
 
 import (
-	"errors"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
 	"image"
 	"image/color"
 	"io"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
 const redColor = 255
@@ -25,10 +36,14 @@ func CreateTestImage(width, height int) image.Image {
 
 // TestMockFileSystem implements FileSystem for testing across packages.
 type TestMockFileSystem struct {
-	OpenFunc   func(name string) (io.ReadCloser, error)
-	CreateFunc func(name string) (io.WriteCloser, error)
-	files      map[string][]byte
-	written    map[string][]byte
+	OpenFunc     func(name string) (io.ReadCloser, error)
+	CreateFunc   func(name string) (io.WriteCloser, error)
+	MkdirAllFunc func(path string, perm fs.FileMode) error
+	RenameFunc   func(oldPath, newPath string) error
+	mu           sync.Mutex
+	files        map[string][]byte
+	written      map[string][]byte
+	dirs         map[string]bool
 }
 
 // NewTestMockFileSystem creates a mock filesystem for testing.
@@ -36,16 +51,23 @@ func NewTestMockFileSystem() *TestMockFileSystem {
 	return &TestMockFileSystem{
 		files:   make(map[string][]byte),
 		written: make(map[string][]byte),
+		dirs:    make(map[string]bool),
 	}
 }
 
 // AddFile adds a file to the mock filesystem.
 func (m *TestMockFileSystem) AddFile(name string, content []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.files[name] = content
 }
 
 // GetWrittenFile returns the content written to a file.
 func (m *TestMockFileSystem) GetWrittenFile(name string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	content, exists := m.written[name]
 	return content, exists
 }
@@ -56,9 +78,12 @@ func (m *TestMockFileSystem) Open(name string) (io.ReadCloser, error) {
 		return m.OpenFunc(name)
 	}
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	content, exists := m.files[name]
 	if !exists {
-		return nil, errors.New("file not found")
+		return nil, fmt.Errorf("file not found: %w", fs.ErrNotExist)
 	}
 	return &testMockReadCloser{content: content}, nil
 }
@@ -75,6 +100,155 @@ func (m *TestMockFileSystem) Create(name string) (io.WriteCloser, error) {
 	}, nil
 }
 
+// MkdirAll implements FileSystem.
+func (m *TestMockFileSystem) MkdirAll(path string, perm fs.FileMode) error {
+	if m.MkdirAllFunc != nil {
+		return m.MkdirAllFunc(path, perm)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.dirs[path] = true
+	return nil
+}
+
+// HasDir reports whether MkdirAll was called for a directory.
+func (m *TestMockFileSystem) HasDir(path string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.dirs[path]
+}
+
+// WrittenFiles returns the sorted names of the files written and not removed.
+func (m *TestMockFileSystem) WrittenFiles() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.written))
+	for name := range m.written {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Rename implements FileSystem, moving a file added or written. The moved
+// file counts as written.
+func (m *TestMockFileSystem) Rename(oldPath, newPath string) error {
+	if m.RenameFunc != nil {
+		return m.RenameFunc(oldPath, newPath)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	content, exists := m.written[oldPath]
+	if !exists {
+		content, exists = m.files[oldPath]
+	}
+	if !exists {
+		return fmt.Errorf("rename %s: %w", oldPath, fs.ErrNotExist)
+	}
+	delete(m.written, oldPath)
+	delete(m.files, oldPath)
+	delete(m.files, newPath)
+	m.written[newPath] = content
+	return nil
+}
+
+// Remove implements FileSystem.
+func (m *TestMockFileSystem) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, written := m.written[name]
+	_, added := m.files[name]
+	if !written && !added {
+		return fmt.Errorf("remove %s: %w", name, fs.ErrNotExist)
+	}
+	delete(m.written, name)
+	delete(m.files, name)
+	return nil
+}
+
+// Stat implements FileSystem for files added or written.
+func (m *TestMockFileSystem) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	content, exists := m.written[name]
+	if !exists {
+		content, exists = m.files[name]
+	}
+	if exists {
+		return testMockFileInfo{name: path.Base(name), size: int64(len(content))}, nil
+	}
+	if len(m.children(name)) > 0 {
+		return testMockFileInfo{name: path.Base(name), dir: true}, nil
+	}
+	return nil, fmt.Errorf("stat %s: %w", name, fs.ErrNotExist)
+}
+
+// ReadDir implements FileSystem, listing the files added or written below
+// dir and the directories that contain them.
+func (m *TestMockFileSystem) ReadDir(dir string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	children := m.children(dir)
+	if len(children) == 0 {
+		return nil, fmt.Errorf("open %s: %w", dir, fs.ErrNotExist)
+	}
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, name := range slices.Sorted(maps.Keys(children)) {
+		info := children[name]
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	return entries, nil
+}
+
+// children returns the entries directly below dir, by name.
+func (m *TestMockFileSystem) children(dir string) map[string]testMockFileInfo {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	children := map[string]testMockFileInfo{}
+	for _, files := range []map[string][]byte{m.files, m.written} {
+		for name, content := range files {
+			rest, found := strings.CutPrefix(name, prefix)
+			if !found {
+				continue
+			}
+			if child, _, nested := strings.Cut(rest, "/"); nested {
+				children[child] = testMockFileInfo{name: child, dir: true}
+			} else {
+				children[rest] = testMockFileInfo{name: rest, size: int64(len(content))}
+			}
+		}
+	}
+	return children
+}
+
+// testMockFileInfo describes a file or directory of the mock filesystem.
+type testMockFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i testMockFileInfo) Name() string       { return i.name }
+func (i testMockFileInfo) Size() int64        { return i.size }
+func (i testMockFileInfo) ModTime() time.Time { return time.Time{} }
+func (i testMockFileInfo) IsDir() bool        { return i.dir }
+func (i testMockFileInfo) Sys() any           { return nil }
+
+func (i testMockFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+
 type testMockReadCloser struct {
 	content []byte
 	pos     int
@@ -105,6 +279,9 @@ func (m *testMockWriteCloser) Write(p []byte) (int, error) {
 }
 
 func (m *testMockWriteCloser) Close() error {
+	m.fs.mu.Lock()
+	defer m.fs.mu.Unlock()
+
 	m.fs.written[m.name] = m.buffer
 	return nil
 }
@@ -191,3 +368,34 @@ func CreateColoredTestImage(width, height int, c color.Color) image.Image {
 
 	return img
 }
+
+// EmbedPNGColorProfile returns a copy of a PNG file with an iCCP chunk
+// holding profile, for testing color management across packages.
+func EmbedPNGColorProfile(data, profile []byte) []byte {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	_, _ = zw.Write(profile)
+	_ = zw.Close()
+	body := append([]byte("iCCP"+"test\x00\x00"), compressed.Bytes()...)
+
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(body)-4)) // #nosec G115
+	chunk = append(chunk, body...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(body))
+
+	// The chunk goes right after the signature and the IHDR chunk.
+	const ihdrEnd = 8 + 8 + 13 + 4
+	return slices.Concat(data[:ihdrEnd], chunk, data[ihdrEnd:])
+}
+
+// EmbedEXIFOrientation returns a copy of a JPEG file with an APP1 segment
+// holding an EXIF orientation, for testing across packages.
+func EmbedEXIFOrientation(data []byte, orientation Orientation) []byte {
+	// A big-endian TIFF header followed by a directory with a single
+	// Orientation entry of one SHORT.
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01")
+	exif = binary.BigEndian.AppendUint16(exif, uint16(orientation)) // #nosec G115
+	exif = append(exif, 0, 0, 0, 0, 0, 0)
+
+	segment := binary.BigEndian.AppendUint16([]byte{0xff, 0xe1}, uint16(len(exif)+2)) // #nosec G115
+	return slices.Concat(data[:2], segment, exif, data[2:])
+}
@@ -0,0 +1,261 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	fullTurn    = 360
+	quarterTurn = 90
+	// rightAngleTolerance is how close to a multiple of 90° a rotation must
+	// be for the pixels to be moved rather than interpolated.
+	rightAngleTolerance = 1e-9
+)
+
+// Flip selects how an image is mirrored.
+type Flip string
+
+const (
+	// FlipHorizontal mirrors the image left to right.
+	FlipHorizontal Flip = "horizontal"
+	// FlipVertical mirrors the image top to bottom.
+	FlipVertical Flip = "vertical"
+	// FlipBoth mirrors the image both ways, which turns it upside down.
+	FlipBoth Flip = "both"
+)
+
+// Flips returns the supported flips.
+func Flips() []Flip {
+	return []Flip{FlipHorizontal, FlipVertical, FlipBoth}
+}
+
+// ParseFlip returns the flip with the given name, ignoring case.
+func ParseFlip(value string) (Flip, error) {
+	names := make([]string, 0, len(Flips()))
+	for _, flip := range Flips() {
+		if strings.EqualFold(value, string(flip)) {
+			return flip, nil
+		}
+		names = append(names, string(flip))
+	}
+	return "", fmt.Errorf("invalid flip %q, supported are: %s", value, strings.Join(names, ", "))
+}
+
+func (f Flip) valid() bool {
+	for _, flip := range Flips() {
+		if f == flip {
+			return true
+		}
+	}
+	return false
+}
+
+// orientation returns the Orientation that mirrors an image like the flip.
+func (f Flip) orientation() Orientation {
+	switch f {
+	case FlipHorizontal:
+		return OrientationFlipHorizontal
+	case FlipVertical:
+		return OrientationFlipVertical
+	case FlipBoth:
+		return OrientationRotate180
+	default:
+		return OrientationNormal
+	}
+}
+
+// Transform is applied to the original image before it is fitted to the
+// canvas: it is flipped, then rotated, then zoomed. The zero value leaves the
+// image unchanged.
+type Transform struct {
+	// Flip mirrors the image. Empty means no flip.
+	Flip Flip
+	// Rotate turns the image clockwise by this many degrees. Multiples of
+	// 90° move the pixels; other angles interpolate them and enlarge the
+	// image to fit the rotated corners, exposing areas filled with Config.Fill.
+	Rotate float64
+	// Zoom magnifies the image by keeping 1/Zoom of its width and height.
+	// 0 and 1 keep the whole image.
+	Zoom float64
+	// Pan is the point of the flipped and rotated image the zoomed area is
+	// centered on, shifted as needed to keep the area inside the image. Nil
+	// means the center of the image.
+	Pan *FocalPoint
+}
+
+// TransformReport describes the Transform applied by ProcessImageData.
+type TransformReport struct {
+	Transform Transform
+	// Rotated is the size of the image once flipped and rotated.
+	Rotated image.Point
+	// Region is the area of the flipped and rotated image kept by the zoom.
+	Region image.Rectangle
+}
+
+// String describes the transform, e.g. "flipped horizontally, rotated 90°, zoomed 2x on 50%,40%".
+func (t Transform) String() string {
+	var parts []string
+	switch t.Flip {
+	case FlipHorizontal, FlipVertical:
+		parts = append(parts, "flipped "+string(t.Flip)+"ly")
+	case FlipBoth:
+		parts = append(parts, "flipped both ways")
+	}
+	if rotation := t.rotation(); rotation != 0 {
+		parts = append(parts, "rotated "+strconv.FormatFloat(rotation, 'f', -1, 64)+"°")
+	}
+	if t.zooms() {
+		pan := "the center"
+		if t.Pan != nil {
+			pan = t.Pan.String()
+		}
+		parts = append(parts, fmt.Sprintf("zoomed %sx on %s", strconv.FormatFloat(t.Zoom, 'f', -1, 64), pan))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// IsZero reports whether the transform leaves images unchanged.
+func (t Transform) IsZero() bool {
+	return t.Flip == "" && t.rotation() == 0 && !t.zooms()
+}
+
+// rotation returns Rotate normalized to [0, 360).
+func (t Transform) rotation() float64 {
+	rotation := math.Mod(t.Rotate, fullTurn)
+	if rotation < 0 {
+		rotation += fullTurn
+	}
+	return rotation
+}
+
+func (t Transform) zooms() bool {
+	return t.Zoom > 1
+}
+
+// validate reports every invalid field of the transform as a *ConfigError.
+func (t Transform) validate() []error {
+	var errs []error
+	if t.Flip != "" && !t.Flip.valid() {
+		errs = append(errs, &ConfigError{
+			Field:       "Transform.Flip",
+			Value:       fmt.Sprintf("%q", t.Flip),
+			Requirement: "must be horizontal, vertical or both",
+		})
+	}
+	if math.IsNaN(t.Rotate) || math.IsInf(t.Rotate, 0) {
+		errs = append(errs, &ConfigError{Field: "Transform.Rotate", Value: t.Rotate, Requirement: "must be finite"})
+	}
+	if math.IsNaN(t.Zoom) || math.IsInf(t.Zoom, 0) || (t.Zoom != 0 && t.Zoom < 1) {
+		errs = append(errs, &ConfigError{
+			Field:       "Transform.Zoom",
+			Value:       t.Zoom,
+			Requirement: "must be at least 1 (use 0 for no zoom)",
+		})
+	}
+	if t.Pan != nil {
+		if panErr := t.Pan.validate(); panErr != nil {
+			errs = append(errs, &ConfigError{Field: "Transform.Pan", Value: t.Pan, Requirement: panErr.Error()})
+		}
+	}
+	return errs
+}
+
+// Apply transforms an image, filling the areas exposed by a rotation that is
+// not a multiple of 90° with fill, and reports what it did. The result has
+// its bounds at the origin.
+func (t Transform) Apply(img image.Image, fill color.Color) (image.Image, TransformReport) {
+	img = t.Flip.orientation().Apply(img)
+
+	rotation := t.rotation()
+	quarters := math.Round(rotation / quarterTurn)
+	if math.Abs(rotation-quarters*quarterTurn) > rightAngleTolerance {
+		img = rotate(img, rotation, fill)
+	} else {
+		turns := []Orientation{OrientationNormal, OrientationRotate90, OrientationRotate180, OrientationRotate270}
+		img = turns[int(quarters)%len(turns)].Apply(img)
+	}
+
+	bounds := img.Bounds()
+	report := TransformReport{Transform: t, Rotated: bounds.Size(), Region: bounds.Sub(bounds.Min)}
+	if !t.zooms() {
+		return img, report
+	}
+
+	width := max(1, int(math.Round(float64(bounds.Dx())/t.Zoom)))
+	height := max(1, int(math.Round(float64(bounds.Dy())/t.Zoom)))
+	pan := FocalPoint{X: percentScale / half, Y: percentScale / half, Percent: true}
+	if t.Pan != nil {
+		pan = *t.Pan
+	}
+	origin := FocalOrigin(bounds, width, height, pan.In(bounds))
+	report.Region = image.Rectangle{Min: origin, Max: origin.Add(image.Pt(width, height))}.Sub(bounds.Min)
+	return CropAt(img, width, height, origin), report
+}
+
+// rotate turns an image clockwise by degrees with bilinear interpolation,
+// onto a canvas that fits the rotated corners and is filled with fill.
+func rotate(img image.Image, degrees float64, fill color.Color) image.Image {
+	sin, cos := math.Sincos(degrees * math.Pi / (fullTurn / 2))
+	bounds := img.Bounds()
+	srcWidth, srcHeight := float64(bounds.Dx()), float64(bounds.Dy())
+	width := max(1, int(math.Ceil(math.Abs(srcWidth*cos)+math.Abs(srcHeight*sin)-rightAngleTolerance)))
+	height := max(1, int(math.Ceil(math.Abs(srcWidth*sin)+math.Abs(srcHeight*cos)-rightAngleTolerance)))
+
+	source, ok := img.(*image.RGBA64)
+	if !ok {
+		source = image.NewRGBA64(image.Rectangle{Max: bounds.Size()})
+		draw.Draw(source, source.Bounds(), img, bounds.Min, draw.Src)
+	}
+	sample := func(x, y int) [rgbaChannels]float64 {
+		if !(image.Point{X: x, Y: y}).In(image.Rectangle{Max: bounds.Size()}) {
+			return [rgbaChannels]float64{}
+		}
+		c := source.RGBA64At(source.Rect.Min.X+x, source.Rect.Min.Y+y)
+		return [rgbaChannels]float64{float64(c.R), float64(c.G), float64(c.B), float64(c.A)}
+	}
+	fr, fg, fb, fa := fill.RGBA()
+	background := [rgbaChannels]float64{float64(fr), float64(fg), float64(fb), float64(fa)}
+
+	var dst draw.Image
+	if newPixelSource(img, false).deep {
+		dst = image.NewRGBA64(image.Rect(0, 0, width, height))
+	} else {
+		dst = image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+	parallelFor(height, 0, func(y int) {
+		for x := range width {
+			// Turn the center of the destination pixel back into the source.
+			dx, dy := float64(x)+0.5-float64(width)/2, float64(y)+0.5-float64(height)/2
+			u := dx*cos + dy*sin + srcWidth/2 - 0.5
+			v := -dx*sin + dy*cos + srcHeight/2 - 0.5
+			x0, y0 := int(math.Floor(u)), int(math.Floor(v))
+			fx, fy := u-float64(x0), v-float64(y0)
+
+			top, bottom := sample(x0, y0), sample(x0, y0+1)
+			topRight, bottomRight := sample(x0+1, y0), sample(x0+1, y0+1)
+			var pixel [rgbaChannels]float64
+			for ch := range pixel {
+				pixel[ch] = (top[ch]*(1-fx)+topRight[ch]*fx)*(1-fy) + (bottom[ch]*(1-fx)+bottomRight[ch]*fx)*fy
+			}
+
+			// Premultiplied pixels go over the fill as they are.
+			cover := 1 - pixel[3]/maxUint16
+			var out color.RGBA64
+			channels := [rgbaChannels]*uint16{&out.R, &out.G, &out.B, &out.A}
+			for ch, value := range pixel {
+				*channels[ch] = uint16(min(maxUint16, value+background[ch]*cover) + 0.5)
+			}
+			dst.Set(x, y, out)
+		}
+	})
+	return dst
+}
@@ -0,0 +1,161 @@
+package processor_test
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestParseFlip(t *testing.T) {
+	flip, parseErr := processor.ParseFlip("Horizontal")
+	require.NoError(t, parseErr)
+	assert.Equal(t, processor.FlipHorizontal, flip)
+
+	_, parseErr = processor.ParseFlip("diagonal")
+	require.Error(t, parseErr)
+	assert.Contains(t, parseErr.Error(), "supported are: horizontal, vertical, both")
+}
+
+func TestTransform_Apply_RightAngles(t *testing.T) {
+	// The Orientation each transform of a 3x2 image amounts to.
+	testCases := []struct {
+		name      string
+		transform processor.Transform
+		same      processor.Orientation
+	}{
+		{"flip horizontal", processor.Transform{Flip: processor.FlipHorizontal}, processor.OrientationFlipHorizontal},
+		{"flip both", processor.Transform{Flip: processor.FlipBoth}, processor.OrientationRotate180},
+		{"rotate 90", processor.Transform{Rotate: 90}, processor.OrientationRotate90},
+		{"rotate -90", processor.Transform{Rotate: -90}, processor.OrientationRotate270},
+		{"rotate 540", processor.Transform{Rotate: 540}, processor.OrientationRotate180},
+		{
+			"flip then rotate",
+			processor.Transform{Flip: processor.FlipHorizontal, Rotate: 90},
+			processor.OrientationTransverse,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := createIndexedImage(3, 2)
+
+			transformed, report := tc.transform.Apply(source, color.Black)
+
+			assert.Equal(t, tc.same.Apply(source), transformed)
+			assert.Equal(t, transformed.Bounds().Size(), report.Rotated)
+			assert.Equal(t, transformed.Bounds(), report.Region, "the whole image is kept without a zoom")
+		})
+	}
+}
+
+func TestTransform_Apply_ArbitraryAngle(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+
+	transformed, report := processor.Transform{Rotate: 45}.Apply(processor.CreateColoredTestImage(10, 10, red), blue)
+
+	assert.Equal(t, image.Pt(15, 15), report.Rotated, "the canvas fits the rotated corners")
+	assert.Equal(t, image.Rect(0, 0, 15, 15), transformed.Bounds())
+	assert.Equal(t, color.RGBAModel.Convert(red), color.RGBAModel.Convert(transformed.At(7, 7)))
+	assert.Equal(t, color.RGBAModel.Convert(blue), color.RGBAModel.Convert(transformed.At(0, 0)),
+		"the exposed corners are filled")
+	edge := color.RGBAModel.Convert(transformed.At(1, 6)).(color.RGBA)
+	assert.Positive(t, edge.R, "the edge blends the image")
+	assert.Positive(t, edge.B, "and the fill")
+}
+
+func TestTransform_Apply_KeepsDepth(t *testing.T) {
+	deep := image.NewRGBA64(image.Rect(0, 0, 8, 8))
+	transformed, _ := processor.Transform{Rotate: 30}.Apply(deep, color.Transparent)
+	assert.IsType(t, &image.RGBA64{}, transformed)
+
+	transformed, _ = processor.Transform{Rotate: 30}.Apply(processor.CreateTestImage(8, 8), color.Transparent)
+	assert.IsType(t, &image.RGBA{}, transformed)
+}
+
+func TestTransform_Apply_Zoom(t *testing.T) {
+	testCases := []struct {
+		name   string
+		pan    *processor.FocalPoint
+		region image.Rectangle
+	}{
+		{"center", nil, image.Rect(25, 20, 75, 60)},
+		{"pixels", &processor.FocalPoint{X: 70, Y: 30}, image.Rect(45, 10, 95, 50)},
+		{"clamped", &processor.FocalPoint{X: 100, Y: 100, Percent: true}, image.Rect(50, 40, 100, 80)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := createIndexedImage(100, 80)
+
+			zoomed, report := processor.Transform{Zoom: 2, Pan: tc.pan}.Apply(source, color.Black)
+
+			assert.Equal(t, tc.region, report.Region)
+			assert.Equal(t, image.Pt(100, 80), report.Rotated)
+			assert.Equal(t, image.Rect(0, 0, 50, 40), zoomed.Bounds())
+			assert.Equal(t, color.RGBAModel.Convert(source.At(tc.region.Min.X, tc.region.Min.Y)),
+				color.RGBAModel.Convert(zoomed.At(0, 0)))
+		})
+	}
+}
+
+func TestTransform_String(t *testing.T) {
+	assert.Equal(t, "none", processor.Transform{Rotate: 360, Zoom: 1}.String())
+	assert.True(t, processor.Transform{Rotate: -720}.IsZero())
+	assert.Equal(t, "flipped vertically, rotated 270°, zoomed 1.5x on the center",
+		processor.Transform{Flip: processor.FlipVertical, Rotate: -90, Zoom: 1.5}.String())
+	assert.Equal(t, "zoomed 2x on 10%,20%",
+		processor.Transform{Zoom: 2, Pan: &processor.FocalPoint{X: 10, Y: 20, Percent: true}}.String())
+}
+
+func TestConfig_Validate_Transform(t *testing.T) {
+	config := processor.DefaultConfig()
+	config.Transform = processor.Transform{
+		Flip:   "diagonal",
+		Rotate: math.NaN(),
+		Zoom:   0.5,
+		Pan:    &processor.FocalPoint{X: -1, Y: 0},
+	}
+
+	validateErr := config.Validate()
+
+	require.Error(t, validateErr)
+	for _, field := range []string{"Transform.Flip", "Transform.Rotate", "Transform.Zoom", "Transform.Pan"} {
+		assert.Contains(t, validateErr.Error(), "invalid "+field)
+	}
+}
+
+func TestService_ProcessImageData_Transform(t *testing.T) {
+	config := processor.DefaultConfig()
+	config.Transform = processor.Transform{Rotate: 90, Zoom: 2, Pan: &processor.FocalPoint{}}
+	service, serviceErr := processor.NewServiceWithDeps(processor.NewTestMockFileSystem(),
+		processor.NewDefaultDecoderRegistry(), processor.NewTestMockImageEncoder(nil),
+		&processor.LanczosResizer{}, config)
+	require.NoError(t, serviceErr)
+
+	procImg, processErr := service.ProcessImageData(context.Background(),
+		&processor.ProcessedImage{Original: processor.CreateTestImage(400, 300)})
+
+	require.NoError(t, processErr)
+	require.NotNil(t, procImg.Transform)
+	assert.Equal(t, image.Pt(300, 400), procImg.Transform.Rotated)
+	assert.Equal(t, image.Rect(0, 0, 150, 200), procImg.Transform.Region)
+	assert.Equal(t, image.Rect(0, 0, 150, 200), procImg.Transformed.Bounds())
+	assert.Same(t, procImg.Transformed, procImg.Source())
+
+	service, serviceErr = service.WithConfig(processor.DefaultConfig())
+	require.NoError(t, serviceErr)
+	procImg, processErr = service.ProcessImageData(context.Background(), procImg)
+
+	require.NoError(t, processErr)
+	assert.Nil(t, procImg.Transform, "a config without a transform clears the previous one")
+	assert.Nil(t, procImg.Transformed)
+	assert.Same(t, procImg.Original, procImg.Source())
+}
@@ -0,0 +1,347 @@
+package processor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"slices"
+)
+
+// Constants of the WebP lossless bitstream, see
+// https://developers.google.com/speed/webp/docs/webp_lossless_bitstream_specification.
+const (
+	vp8lSignature         = 0x2f
+	vp8lMaxDimension      = 1 << 14
+	vp8lDimensionBits     = 14
+	vp8lVersionBits       = 3
+	vp8lTransformBits     = 2
+	vp8lSubtractGreen     = 2
+	vp8lCodeLengthBits    = 3
+	vp8lNumCodesBits      = 4
+	vp8lMinCodeLengths    = 4
+	vp8lMaxCodeLength     = 15
+	vp8lMaxCodeLengthCode = 7
+	vp8lLiteralCodes      = 256
+	vp8lLengthCodes       = 24
+	vp8lDistanceCodes     = 40
+	vp8lWideSymbolBits    = 8
+	riffHeaderSize        = 12
+	riffChunkHeaderSize   = 8
+)
+
+// WebPEncoder implements ImageEncoder for lossless WebP (VP8L). Pixels are
+// stored as Huffman-coded literals after the subtract-green transform, which
+// keeps the writer small while still compressing flat tiles well.
+type WebPEncoder struct{}
+
+// Extension returns the file extension of WebP images.
+func (e *WebPEncoder) Extension() string {
+	return "webp"
+}
+
+// Encode encodes an image as lossless WebP.
+func (e *WebPEncoder) Encode(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > vp8lMaxDimension || height > vp8lMaxDimension {
+		return fmt.Errorf("webp: image size %dx%d is outside 1x1 to %dx%d",
+			width, height, vp8lMaxDimension, vp8lMaxDimension)
+	}
+
+	pixels := make([]color.NRGBA, 0, width*height)
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			// Subtract green: red and blue are stored relative to green.
+			pixel.R -= pixel.G
+			pixel.B -= pixel.G
+			opaque = opaque && pixel.A == 0xff
+			pixels = append(pixels, pixel)
+		}
+	}
+
+	green := make([]int, vp8lLiteralCodes+vp8lLengthCodes)
+	red := make([]int, vp8lLiteralCodes)
+	blue := make([]int, vp8lLiteralCodes)
+	alpha := make([]int, vp8lLiteralCodes)
+	for _, pixel := range pixels {
+		green[pixel.G]++
+		red[pixel.R]++
+		blue[pixel.B]++
+		alpha[pixel.A]++
+	}
+
+	bw := &bitWriter{}
+	bw.write(vp8lSignature, 8)
+	bw.write(uint32(width-1), vp8lDimensionBits)  // #nosec G115
+	bw.write(uint32(height-1), vp8lDimensionBits) // #nosec G115
+	bw.writeBool(!opaque)
+	bw.write(0, vp8lVersionBits)
+
+	bw.writeBool(true)
+	bw.write(vp8lSubtractGreen, vp8lTransformBits)
+	bw.writeBool(false) // no further transforms
+
+	bw.writeBool(false) // no color cache
+	bw.writeBool(false) // a single prefix code group
+	greenCode := writePrefixCode(bw, green)
+	redCode := writePrefixCode(bw, red)
+	blueCode := writePrefixCode(bw, blue)
+	alphaCode := writePrefixCode(bw, alpha)
+	writePrefixCode(bw, make([]int, vp8lDistanceCodes))
+
+	for _, pixel := range pixels {
+		greenCode.write(bw, int(pixel.G))
+		redCode.write(bw, int(pixel.R))
+		blueCode.write(bw, int(pixel.B))
+		alphaCode.write(bw, int(pixel.A))
+	}
+
+	return writeRIFF(w, "VP8L", bw.bytes())
+}
+
+// writeRIFF writes a WebP file holding a single chunk.
+func writeRIFF(w io.Writer, fourCC string, payload []byte) error {
+	padding := len(payload) % 2
+	header := make([]byte, 0, riffHeaderSize+riffChunkHeaderSize)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(4+riffChunkHeaderSize+len(payload)+padding)) // #nosec G115
+	header = append(header, "WEBP"...)
+	header = append(header, fourCC...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(payload))) // #nosec G115
+
+	if _, writeErr := w.Write(header); writeErr != nil {
+		return writeErr
+	}
+	if _, writeErr := w.Write(payload); writeErr != nil {
+		return writeErr
+	}
+	if padding != 0 {
+		if _, writeErr := w.Write([]byte{0}); writeErr != nil {
+			return writeErr
+		}
+	}
+	return nil
+}
+
+// prefixCode holds the canonical Huffman code of every symbol of an alphabet.
+type prefixCode struct {
+	lengths []int
+	codes   []uint32
+}
+
+// write writes the code of symbol, most significant bit first as VP8L expects.
+func (c prefixCode) write(bw *bitWriter, symbol int) {
+	length := c.lengths[symbol]
+	code := c.codes[symbol]
+	reversed := uint32(0)
+	for range length {
+		reversed = reversed<<1 | code&1
+		code >>= 1
+	}
+	bw.write(reversed, length)
+}
+
+// writePrefixCode writes a prefix code for the symbol counts and returns it.
+// Alphabets using at most two symbols below 256 are written as simple codes.
+func writePrefixCode(bw *bitWriter, counts []int) prefixCode {
+	var used []int
+	for symbol, count := range counts {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < vp8lLiteralCodes) {
+		if len(used) == 0 {
+			used = []int{0}
+		}
+		code := prefixCode{lengths: make([]int, len(counts)), codes: make([]uint32, len(counts))}
+		bw.writeBool(true)
+		bw.write(uint32(len(used)-1), 1) // #nosec G115
+		if used[0] < 2 {
+			bw.writeBool(false)
+			bw.write(uint32(used[0]), 1) // #nosec G115
+		} else {
+			bw.writeBool(true)
+			bw.write(uint32(used[0]), vp8lWideSymbolBits) // #nosec G115
+		}
+		if len(used) == 2 {
+			bw.write(uint32(used[1]), vp8lWideSymbolBits) // #nosec G115
+		}
+		for i, symbol := range used {
+			code.lengths[symbol] = len(used) - 1
+			code.codes[symbol] = uint32(i) // #nosec G115
+		}
+		return code
+	}
+
+	lengths := huffmanLengths(counts, vp8lMaxCodeLength)
+
+	// The code lengths are themselves Huffman coded, without repeat codes,
+	// and the lengths of that code are stored in this order.
+	codeLengthOrder := [...]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	lengthCounts := make([]int, len(codeLengthOrder))
+	for _, length := range lengths {
+		lengthCounts[length]++
+	}
+	if countNonZero(lengthCounts) == 1 {
+		// A Huffman code needs two symbols; give one to an unused length.
+		lengthCounts[slices.Index(lengthCounts, 0)] = 1
+	}
+	lengthCode := newPrefixCode(huffmanLengths(lengthCounts, vp8lMaxCodeLengthCode))
+
+	numCodes := vp8lMinCodeLengths
+	for i, symbol := range codeLengthOrder {
+		if lengthCode.lengths[symbol] > 0 {
+			numCodes = max(numCodes, i+1)
+		}
+	}
+
+	bw.writeBool(false)
+	bw.write(uint32(numCodes-vp8lMinCodeLengths), vp8lNumCodesBits) // #nosec G115
+	for _, symbol := range codeLengthOrder[:numCodes] {
+		bw.write(uint32(lengthCode.lengths[symbol]), vp8lCodeLengthBits) // #nosec G115
+	}
+	bw.writeBool(false) // code lengths are given for the whole alphabet
+	for _, length := range lengths {
+		lengthCode.write(bw, length)
+	}
+
+	return newPrefixCode(lengths)
+}
+
+func countNonZero(values []int) int {
+	n := 0
+	for _, value := range values {
+		if value != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// newPrefixCode assigns canonical codes to the code lengths.
+func newPrefixCode(lengths []int) prefixCode {
+	maxLength := slices.Max(lengths)
+	lengthCounts := make([]uint32, maxLength+1)
+	for _, length := range lengths {
+		if length > 0 {
+			lengthCounts[length]++
+		}
+	}
+	next := make([]uint32, maxLength+1)
+	code := uint32(0)
+	for length := 1; length <= maxLength; length++ {
+		code = (code + lengthCounts[length-1]) << 1
+		next[length] = code
+	}
+
+	codes := make([]uint32, len(lengths))
+	for symbol, length := range lengths {
+		if length > 0 {
+			codes[symbol] = next[length]
+			next[length]++
+		}
+	}
+	return prefixCode{lengths: lengths, codes: codes}
+}
+
+// huffmanLengths returns Huffman code lengths of at most maxLength bits for
+// the symbol counts, which must include at least two non-zero counts. When
+// the optimal code is too deep, the counts are halved until it fits.
+func huffmanLengths(counts []int, maxLength int) []int {
+	counts = slices.Clone(counts)
+	for {
+		lengths := unlimitedHuffmanLengths(counts)
+		if slices.Max(lengths) <= maxLength {
+			return lengths
+		}
+		for symbol, count := range counts {
+			if count > 0 {
+				counts[symbol] = (count + 1) / 2
+			}
+		}
+	}
+}
+
+func unlimitedHuffmanLengths(counts []int) []int {
+	type node struct {
+		weight int
+		parent int
+	}
+
+	var nodes []node
+	var leaves []int
+	for symbol, count := range counts {
+		if count > 0 {
+			leaves = append(leaves, symbol)
+		}
+	}
+	slices.SortStableFunc(leaves, func(a, b int) int { return counts[a] - counts[b] })
+	for _, symbol := range leaves {
+		nodes = append(nodes, node{weight: counts[symbol], parent: -1})
+	}
+
+	// Two-queue construction: leaves are sorted by weight and merged nodes
+	// are created in increasing weight order.
+	nextLeaf, nextMerged := 0, len(leaves)
+	pop := func() int {
+		if nextLeaf < len(leaves) && (nextMerged >= len(nodes) || nodes[nextLeaf].weight <= nodes[nextMerged].weight) {
+			nextLeaf++
+			return nextLeaf - 1
+		}
+		nextMerged++
+		return nextMerged - 1
+	}
+	for range len(leaves) - 1 {
+		a, b := pop(), pop()
+		nodes = append(nodes, node{weight: nodes[a].weight + nodes[b].weight, parent: -1})
+		nodes[a].parent, nodes[b].parent = len(nodes)-1, len(nodes)-1
+	}
+
+	lengths := make([]int, len(counts))
+	for i, symbol := range leaves {
+		for n := i; nodes[n].parent >= 0; n = nodes[n].parent {
+			lengths[symbol]++
+		}
+	}
+	return lengths
+}
+
+// bitWriter packs values least significant bit first, as VP8L reads them.
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nBits int
+}
+
+func (b *bitWriter) write(value uint32, n int) {
+	b.acc |= uint64(value) << b.nBits
+	b.nBits += n
+	for b.nBits >= 8 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc >>= 8
+		b.nBits -= 8
+	}
+}
+
+func (b *bitWriter) writeBool(value bool) {
+	if value {
+		b.write(1, 1)
+	} else {
+		b.write(0, 1)
+	}
+}
+
+// bytes flushes the pending bits and returns the written data.
+func (b *bitWriter) bytes() []byte {
+	if b.nBits > 0 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc, b.nBits = 0, 0
+	}
+	return b.buf
+}
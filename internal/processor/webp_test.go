@@ -0,0 +1,88 @@
+package processor_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/webp"
+
+	"github.com/vallieres/mx-creative-console-bg-maker/internal/processor"
+)
+
+func TestWebPEncoder_Encode_RoundTrip(t *testing.T) {
+	testCases := []struct {
+		name string
+		img  image.Image
+	}{
+		{"single color", processor.CreateColoredTestImage(16, 9, color.RGBA{R: 10, G: 200, B: 30, A: 255})},
+		{"two colors", createPatchedImage(8, 8, image.Rect(0, 0, 8, 8), checkerboard)},
+		{"gradient", createCoordinateImage(64, 48)},
+		{"noise with alpha", createNoiseImage(37, 23)},
+		{"single pixel", processor.CreateColoredTestImage(1, 1, color.RGBA{R: 255, A: 255})},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			var buf bytes.Buffer
+
+			// Execute
+			encodeErr := (&processor.WebPEncoder{}).Encode(&buf, tc.img)
+			require.NoError(t, encodeErr)
+			decoded, decodeErr := webp.Decode(&buf)
+
+			// Assert - lossless, so every pixel survives
+			require.NoError(t, decodeErr)
+			bounds := tc.img.Bounds()
+			require.Equal(t, bounds.Size(), decoded.Bounds().Size())
+			for y := range bounds.Dy() {
+				for x := range bounds.Dx() {
+					want := color.NRGBAModel.Convert(tc.img.At(bounds.Min.X+x, bounds.Min.Y+y))
+					got := color.NRGBAModel.Convert(decoded.At(decoded.Bounds().Min.X+x, decoded.Bounds().Min.Y+y))
+					require.Equal(t, want, got, "pixel %d,%d", x, y)
+				}
+			}
+		})
+	}
+}
+
+func TestWebPEncoder_Encode_CompressesFlatImages(t *testing.T) {
+	var buf bytes.Buffer
+
+	encodeErr := (&processor.WebPEncoder{}).Encode(&buf, processor.CreateColoredTestImage(200, 200, color.White))
+
+	require.NoError(t, encodeErr)
+	assert.Less(t, buf.Len(), 100)
+	assert.Equal(t, "RIFF", buf.String()[:4])
+	assert.Equal(t, "WEBPVP8L", buf.String()[8:16])
+}
+
+func TestWebPEncoder_Encode_TooLarge(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 16385, 1))
+
+	encodeErr := (&processor.WebPEncoder{}).Encode(&bytes.Buffer{}, img)
+
+	require.Error(t, encodeErr)
+	assert.Contains(t, encodeErr.Error(), "outside 1x1 to 16384x16384")
+}
+
+// createNoiseImage returns an image with pseudo-random colors and alpha, so
+// that every channel uses a deep Huffman code.
+func createNoiseImage(width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	state := uint32(1)
+	next := func() uint8 {
+		state = state*1664525 + 1013904223
+		return uint8(state >> 24)
+	}
+	for y := range height {
+		for x := range width {
+			img.SetNRGBA(x, y, color.NRGBA{R: next(), G: next(), B: next(), A: next()})
+		}
+	}
+	return img
+}